@@ -0,0 +1,9 @@
+// Generate a techfile for GDS3D from PDK files, see pkg/techgen
+
+package main
+
+import "github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/techgen"
+
+func main() {
+	techgen.Main()
+}
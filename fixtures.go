@@ -0,0 +1,11 @@
+// Package build_3d_techfile holds the sg13g2 lyp/LEF pair of the repo root
+// and the techfile generated from them, the one copy the CLI defaults to
+// and the tests and techgentest fixtures read.
+package build_3d_techfile
+
+import "embed"
+
+// Fixtures are sg13g2.lyp, sg13g2_tech.lef and sg13g2.txt
+//
+//go:embed sg13g2.lyp sg13g2_tech.lef sg13g2.txt
+var Fixtures embed.FS
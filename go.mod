@@ -0,0 +1,3 @@
+module github.com/jorgenkraghjakobsen/build_3d_techfile
+
+go 1.22
@@ -0,0 +1,54 @@
+// Package ctxio makes readers and writers stop when a context is done, so
+// parsers and writers that loop over an io.Reader or io.Writer can be
+// cancelled without checking the context themselves. The context is
+// checked before every Read and Write, with buffered parsers that is once
+// per buffer of a few KB.
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// NewReader returns a reader that fails with the context error once ctx
+// is done
+func NewReader(ctx context.Context, r io.Reader) io.Reader {
+	if ctx.Done() == nil {
+		// Never cancelled, e.g. context.Background
+		return r
+	}
+	return &reader{ctx, r}
+}
+
+func (cr *reader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+type writer struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// NewWriter returns a writer that fails with the context error once ctx
+// is done
+func NewWriter(ctx context.Context, w io.Writer) io.Writer {
+	if ctx.Done() == nil {
+		return w
+	}
+	return &writer{ctx, w}
+}
+
+func (cw *writer) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
@@ -0,0 +1,119 @@
+// Package format is the registry of input and output formats kept outside
+// the build_3d_techfile pipeline.
+//
+// A format package registers its Parser or Writer from init, the command
+// picks it up once the package is imported for its side effect, the way
+// image decoders and database/sql drivers work:
+//
+//	package itf
+//
+//	func init() { format.RegisterParser(parser{}) }
+//
+//	import _ "github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/format/itf"
+//
+// Parsers are used with -from name:file, writers with -export name. Both
+// see the stack as the versioned ir.Stack, so they need nothing of the
+// command itself.
+package format
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+)
+
+// Parser reads a stack from an input format, e.g. an ITF or Magic techfile
+type Parser interface {
+	// Name of the format, as given to -from
+	Name() string
+	// Parse reads r, name is the file for the messages
+	Parse(ctx context.Context, r io.Reader, name string) (ir.Stack, error)
+}
+
+// Writer writes a stack in an output format, e.g. a KLayout 2.5D script
+type Writer interface {
+	// Name of the format, as given to -export
+	Name() string
+	// Ext is appended to the techfile path without its extension, e.g. .lyd25
+	Ext() string
+	// Write writes s as drawn, stem is the output file name without
+	// directory and extension, for formats referencing companion files
+	Write(ctx context.Context, w io.Writer, s ir.Stack, stem string) error
+}
+
+var (
+	mu      sync.RWMutex
+	parsers = map[string]Parser{}
+	writers = map[string]Writer{}
+)
+
+// RegisterParser makes p available by its name. It panics if p is nil or
+// the name is taken, both are mistakes in the format package.
+func RegisterParser(p Parser) {
+	mu.Lock()
+	defer mu.Unlock()
+	if p == nil {
+		panic("format: RegisterParser of nil")
+	}
+	if _, dup := parsers[p.Name()]; dup {
+		panic(fmt.Sprintf("format: parser %s registered twice", p.Name()))
+	}
+	parsers[p.Name()] = p
+}
+
+// RegisterWriter makes w available by its name. It panics if w is nil or
+// the name is taken, both are mistakes in the format package.
+func RegisterWriter(w Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if w == nil {
+		panic("format: RegisterWriter of nil")
+	}
+	if _, dup := writers[w.Name()]; dup {
+		panic(fmt.Sprintf("format: writer %s registered twice", w.Name()))
+	}
+	writers[w.Name()] = w
+}
+
+// LookupParser is the parser registered as name
+func LookupParser(name string) (Parser, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := parsers[name]
+	return p, ok
+}
+
+// LookupWriter is the writer registered as name
+func LookupWriter(name string) (Writer, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	w, ok := writers[name]
+	return w, ok
+}
+
+// ParserNames are the registered parsers, sorted
+func ParserNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sortedKeys(parsers)
+}
+
+// WriterNames are the registered writers, sorted
+func WriterNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sortedKeys(writers)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
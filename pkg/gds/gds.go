@@ -0,0 +1,197 @@
+// Package gds reads GDSII streams a record at a time.
+//
+// Nothing of the layout is kept: Reader hands out the records in stream
+// order and reads a payload only when it is asked for, into a buffer that
+// is reused, so a full-chip GDS of many GB is scanned in constant memory.
+// Gzipped streams (.gds.gz) are read as well.
+//
+//	r, err := gds.NewReader(file)
+//	for {
+//		rec, err := r.Next()
+//		if err != nil { ... }
+//		if rec.Type == gds.Layer {
+//			data, err := r.Data()
+//			...
+//		}
+//	}
+//
+// Layers scans a stream for the layer/datatype pairs its shapes are drawn
+// on, enough to check a techfile against a design.
+package gds
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+)
+
+// GDSII record types
+const (
+	EndLib   = 0x04
+	Boundary = 0x08
+	Path     = 0x09
+	Layer    = 0x0d
+	Datatype = 0x0e
+	EndEl    = 0x11
+	Box      = 0x2d
+	BoxType  = 0x2e
+)
+
+// ErrNoEndLib is a stream that ends before its ENDLIB record
+var ErrNoEndLib = errors.New("no ENDLIB record")
+
+// Record is the header of a record
+type Record struct {
+	Type     byte  // record type, e.g. Layer
+	DataType byte  // type of the payload, e.g. 2 for 16 bit integers
+	Length   int   // of the payload, without the header
+	Offset   int64 // of the header in the stream, after decompression
+}
+
+// Reader reads the records of a stream
+type Reader struct {
+	r      *bufio.Reader
+	header [4]byte
+	buf    []byte
+	rec    Record
+	unread int // bytes of the payload of rec not read yet
+	offset int64
+	done   bool // ENDLIB was read
+}
+
+// NewReader reads the stream of r, gunzipping it if it starts with the
+// gzip magic
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gz)
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next is the header of the next record, skipping what is left of the
+// payload of the current one. After ENDLIB it returns io.EOF, a stream
+// ending before it fails with ErrNoEndLib. Records are a whole number of
+// 16 bit words, an odd length fails as a stream that is not GDSII.
+func (r *Reader) Next() (Record, error) {
+	if r.done {
+		return Record{}, io.EOF
+	}
+	if r.unread > 0 {
+		if _, err := r.r.Discard(r.unread); err != nil {
+			return Record{}, r.streamErr(err)
+		}
+		r.offset += int64(r.unread)
+		r.unread = 0
+	}
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		return Record{}, r.streamErr(err)
+	}
+	length := int(binary.BigEndian.Uint16(r.header[:]))
+	if length < 4 || length%2 != 0 {
+		return Record{}, fmt.Errorf("offset %d: bad record length %d, not a GDSII stream?", r.offset, length)
+	}
+	r.rec = Record{Type: r.header[2], DataType: r.header[3], Length: length - 4, Offset: r.offset}
+	r.offset += 4
+	r.unread = r.rec.Length
+	r.done = r.rec.Type == EndLib
+	return r.rec, nil
+}
+
+// Data is the payload of the current record. It is only valid until the
+// next call of Next.
+func (r *Reader) Data() ([]byte, error) {
+	if r.unread < r.rec.Length {
+		return nil, errors.New("gds: Data called twice for a record")
+	}
+	if cap(r.buf) < r.rec.Length {
+		r.buf = make([]byte, r.rec.Length, max(r.rec.Length, 1024))
+	}
+	data := r.buf[:r.rec.Length]
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, r.streamErr(err)
+	}
+	r.offset += int64(r.rec.Length)
+	r.unread = 0
+	return data, nil
+}
+
+// A stream ending anywhere before ENDLIB lacks it
+func (r *Reader) streamErr(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrNoEndLib
+	}
+	return err
+}
+
+// Int16 is the first 16 bit integer of a payload, 0 if there is none.
+// It is read unsigned, layer and datatype numbers go up to 65535.
+func Int16(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(data))
+}
+
+// LayerKey is a GDS layer/datatype pair
+type LayerKey struct {
+	Layer, Datatype int
+}
+
+// Layers counts the shapes of a stream per layer/datatype pair.
+// Boundaries, paths and boxes count as shapes, texts and references are
+// skipped.
+func Layers(r io.Reader) (map[LayerKey]int, error) {
+	return LayersContext(context.Background(), r)
+}
+
+// LayersContext is Layers that stops with the context error once ctx is
+// done
+func LayersContext(ctx context.Context, r io.Reader) (map[LayerKey]int, error) {
+	gr, err := NewReader(ctxio.NewReader(ctx, r))
+	if err != nil {
+		return nil, err
+	}
+	layers := map[LayerKey]int{}
+	shape := false
+	var key LayerKey
+	for {
+		rec, err := gr.Next()
+		if err == io.EOF {
+			return layers, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch rec.Type {
+		case Boundary, Path, Box:
+			shape = true
+			key = LayerKey{}
+		case Layer, Datatype, BoxType:
+			data, err := gr.Data()
+			if err != nil {
+				return nil, err
+			}
+			if rec.Type == Layer {
+				key.Layer = Int16(data)
+			} else {
+				key.Datatype = Int16(data)
+			}
+		case EndEl:
+			if shape {
+				layers[key]++
+			}
+			shape = false
+		}
+	}
+}
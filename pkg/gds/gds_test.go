@@ -0,0 +1,115 @@
+package gds
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A record of type typ with 16 bit integer payload values
+func record(typ byte, values ...int) []byte {
+	rec := binary.BigEndian.AppendUint16(nil, uint16(4+2*len(values)))
+	rec = append(rec, typ, 2)
+	for _, v := range values {
+		rec = binary.BigEndian.AppendUint16(rec, uint16(v))
+	}
+	return rec
+}
+
+// A stream of a boundary on 8/0, a box on 40000/65535 and a path on 8/0
+func testStream() []byte {
+	return bytes.Join([][]byte{
+		record(Boundary), record(Layer, 8), record(Datatype, 0), record(EndEl),
+		record(Box), record(Layer, 40000), record(BoxType, 65535), record(EndEl),
+		record(Path), record(Layer, 8), record(Datatype, 0), record(EndEl),
+		record(EndLib),
+	}, nil)
+}
+
+func TestReaderRecords(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(record(Layer, 8, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Record{Type: Layer, DataType: 2, Length: 4}); rec != want {
+		t.Errorf("Next = %+v, want %+v", rec, want)
+	}
+	data, err := r.Data()
+	if err != nil || !bytes.Equal(data, []byte{0, 8, 0, 1}) {
+		t.Errorf("Data = %v, %v, want [0 8 0 1]", data, err)
+	}
+	if _, err := r.Data(); err == nil {
+		t.Error("second Data of a record succeeded")
+	}
+
+	// Skipped payloads still move the offsets along
+	r, _ = NewReader(bytes.NewReader(testStream()))
+	var offsets []int64
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, rec.Offset)
+	}
+	if want := []int64{0, 4, 10, 16, 20, 24, 30, 36, 40, 44, 50, 56, 60}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("offsets %v, want %v", offsets, want)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next after ENDLIB = %v, want io.EOF", err)
+	}
+}
+
+func TestReaderBadStreams(t *testing.T) {
+	stream := testStream()
+	tests := []struct {
+		name   string
+		stream []byte
+		err    string
+	}{
+		{"empty", nil, ErrNoEndLib.Error()},
+		{"no ENDLIB", stream[:len(stream)-4], ErrNoEndLib.Error()},
+		{"truncated header", stream[:len(stream)-2], ErrNoEndLib.Error()},
+		{"truncated payload", stream[:7], ErrNoEndLib.Error()},
+		{"short record", []byte{0, 2, Layer, 2}, "bad record length 2"},
+		{"odd record", []byte{0, 5, Layer, 2, 0}, "bad record length 5"},
+	}
+	for _, tt := range tests {
+		_, err := Layers(bytes.NewReader(tt.stream))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Layers = %v, want error %s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLayers(t *testing.T) {
+	layers, err := Layers(bytes.NewReader(testStream()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Numbers above 32767 are not negative
+	want := map[LayerKey]int{{8, 0}: 2, {40000, 65535}: 1}
+	if !reflect.DeepEqual(layers, want) {
+		t.Errorf("Layers = %v, want %v", layers, want)
+	}
+}
+
+func TestLayersContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LayersContext(ctx, bytes.NewReader(testStream())); !errors.Is(err, context.Canceled) {
+		t.Errorf("LayersContext with a canceled context = %v, want context.Canceled", err)
+	}
+}
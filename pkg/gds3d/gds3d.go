@@ -0,0 +1,336 @@
+// Package gds3d reads and writes GDS3D techfiles.
+//
+// A techfile is a list of LayerStart ... LayerEnd blocks:
+/*
+
+LayerStart: Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 0.22
+Greeen: 0.75
+Blue: 1.00
+Filter: 0.0
+Metal: 1
+Shortkey: 1
+Show: 1
+LayerEnd
+*/
+// Lengths are kept in micron here and written in the Unit of a Format,
+// nanometer for GDS3D. Lines starting with # are comments, Read hands the
+// ones before a block to the caller with the layer.
+//
+// Write writes a whole techfile, options change units, key spelling,
+// order and which layers are written and add a header:
+//
+//	gds3d.Write(w, layers, gds3d.WithUnit("um", 3), gds3d.WithZOrder())
+package gds3d
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+// Layer is one LayerStart ... LayerEnd block
+type Layer struct {
+	Name      string
+	Layer     int
+	Datatype  int
+	Height    float64 // um
+	Thickness float64 // um
+	Red       float64 // 0.0 to 1.0
+	Green     float64
+	Blue      float64
+	Filter    float64 // transparency, 0.0 opaque to 1.0 invisible
+	Metal     int
+	Shortkey  string // key toggling the layer, "" for none
+	Show      bool
+	Comments  []string // comment lines before LayerStart without the #, "" for an empty line
+	Line      int      // of LayerStart, when read
+}
+
+// Scale factors to micron of the units a techfile can be written in, and
+// the decimals written by default
+var (
+	Units      = map[string]float64{"nm": 1e-3, "um": 1.0}
+	UnitDigits = map[string]int{"nm": 0, "um": 3}
+)
+
+// Keys in the order they are written, differing only in the spelling of
+// Green. The GDS3D builds the first techfiles were made for read the
+// misspelled Greeen, current GDS3D and other parsers want Green.
+var (
+	LegacyKeys = []string{"Layer", "Datatype", "Height", "Thickness", "Red", "Greeen", "Blue", "Filter", "Metal", "Shortkey", "Show"}
+	StrictKeys = []string{"Layer", "Datatype", "Height", "Thickness", "Red", "Green", "Blue", "Filter", "Metal", "Shortkey", "Show"}
+)
+
+// Format is how numbers and keys are written
+type Format struct {
+	Unit      string   // nm or um
+	Precision int      // decimals of Height and Thickness, below zero the default of Unit
+	Minimal   bool     // drop trailing zeros, 0.80 as 0.8 and 1.000 as 1
+	Keys      []string // keys as written, LegacyKeys if nil
+}
+
+// DefaultFormat is what GDS3D expects
+var DefaultFormat = Format{Unit: "nm", Precision: -1}
+
+// Length formats a height or thickness in micron in the unit of f, never "-0"
+func (f Format) Length(um float64) string {
+	return string(f.AppendLength(nil, um))
+}
+
+// AppendLength is Length appending to dst
+func (f Format) AppendLength(dst []byte, um float64) []byte {
+	digits := f.Precision
+	if digits < 0 {
+		digits = UnitDigits[f.Unit]
+	}
+	value := um / Units[f.Unit]
+	scale := math.Pow(10, float64(digits))
+	value = math.Round(value*scale) / scale
+	if value == 0 {
+		value = 0
+	}
+	return f.AppendNumber(dst, value, digits)
+}
+
+// Number formats value with digits decimals. strconv always writes a '.'
+// decimal point, whatever the locale, so the techfile reads the same on
+// every GDS3D install.
+func (f Format) Number(value float64, digits int) string {
+	return string(f.AppendNumber(nil, value, digits))
+}
+
+// AppendNumber is Number appending to dst
+func (f Format) AppendNumber(dst []byte, value float64, digits int) []byte {
+	start := len(dst)
+	dst = strconv.AppendFloat(dst, value, 'f', digits, 64)
+	if f.Minimal && bytes.IndexByte(dst[start:], '.') >= 0 {
+		dst = bytes.TrimRight(bytes.TrimRight(dst, "0"), ".")
+	}
+	return dst
+}
+
+// Decimal is the shortest exact decimal with a decimal point, the way
+// techfiles write Filter
+func Decimal(v float64) string {
+	return string(appendDecimal(nil, v))
+}
+
+func appendDecimal(dst []byte, v float64) []byte {
+	start := len(dst)
+	dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
+	if bytes.IndexByte(dst[start:], '.') < 0 {
+		dst = append(dst, ".0"...)
+	}
+	return dst
+}
+
+// ParseColor splits #rrggbb into the Red, Green and Blue of a block,
+// parseerr.ErrBadColor if it is not such a color
+func ParseColor(color string) (float64, float64, float64, error) {
+	hex, ok := strings.CutPrefix(color, "#")
+	if !ok || len(hex) != 6 {
+		return 0, 0, 0, fmt.Errorf("%w: %q", parseerr.ErrBadColor, color)
+	}
+	var rgb [3]float64
+	for i := range rgb {
+		v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("%w: %q", parseerr.ErrBadColor, color)
+		}
+		rgb[i] = float64(v) / 255.0
+	}
+	return rgb[0], rgb[1], rgb[2], nil
+}
+
+// WriteLayer writes the block of l, without its comments
+func (f Format) WriteLayer(w io.Writer, l Layer) error {
+	_, err := w.Write(f.AppendLayer(make([]byte, 0, 256), l))
+	return err
+}
+
+// AppendLayer appends the block of l, without its comments, to dst. Write
+// reuses one buffer for all layers, so large layer tables cost no
+// allocations per layer.
+func (f Format) AppendLayer(dst []byte, l Layer) []byte {
+	keys := f.Keys
+	if keys == nil {
+		keys = LegacyKeys
+	}
+	dst = append(dst, "LayerStart: "...)
+	dst = append(dst, l.Name...)
+	dst = append(dst, '\n')
+	for _, key := range keys {
+		start := len(dst)
+		dst = append(dst, key...)
+		dst = append(dst, ": "...)
+		value := len(dst)
+		dst = f.appendValue(dst, key, l)
+		if len(dst) == value {
+			// Keys without a value, e.g. no Shortkey, are left out
+			dst = dst[:start]
+			continue
+		}
+		dst = append(dst, '\n')
+	}
+	return append(dst, "LayerEnd\n\n"...)
+}
+
+func (f Format) appendValue(dst []byte, key string, l Layer) []byte {
+	switch key {
+	case "Layer":
+		return strconv.AppendInt(dst, int64(l.Layer), 10)
+	case "Datatype":
+		return strconv.AppendInt(dst, int64(l.Datatype), 10)
+	case "Height":
+		return f.AppendLength(dst, l.Height)
+	case "Thickness":
+		return f.AppendLength(dst, l.Thickness)
+	case "Red":
+		return f.AppendNumber(dst, l.Red, 2)
+	case "Green", "Greeen":
+		return f.AppendNumber(dst, l.Green, 2)
+	case "Blue":
+		return f.AppendNumber(dst, l.Blue, 2)
+	case "Filter":
+		return appendDecimal(dst, l.Filter)
+	case "Metal":
+		return strconv.AppendInt(dst, int64(l.Metal), 10)
+	case "Shortkey":
+		return append(dst, l.Shortkey...)
+	case "Show":
+		if l.Show {
+			return append(dst, '1')
+		}
+		return append(dst, '0')
+	}
+	return dst
+}
+
+// Read returns the blocks of a techfile with Height and Thickness given
+// in unit. Name is used in the errors, problems in the file are a
+// *parseerr.Error and a file without blocks parseerr.ErrMissingSection.
+func Read(r io.Reader, name, unit string) ([]Layer, error) {
+	return ReadContext(context.Background(), r, name, unit)
+}
+
+// ReadContext is Read that stops with the context error once ctx is done
+func ReadContext(ctx context.Context, r io.Reader, name, unit string) ([]Layer, error) {
+	var layers []Layer
+	err := read(ctx, r, name, unit, func(l Layer, end int) error {
+		layers = append(layers, l)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
+// read hands every block to block at its LayerEnd, the line of which is
+// end, and, with other set, every other line, comments and blank lines, as
+// it is in the file
+func read(ctx context.Context, r io.Reader, name, unit string, block func(l Layer, end int) error, other func(line string, inBlock bool)) error {
+	r = ctxio.NewReader(ctx, r)
+	scale, ok := Units[unit]
+	if !ok {
+		return fmt.Errorf("%s: unknown unit %s", name, unit)
+	}
+	blocks := 0
+	var layer *Layer
+	var comments []string
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			if other != nil {
+				other(scanner.Text(), layer != nil)
+			}
+			if comment, ok := strings.CutPrefix(line, "#"); ok {
+				comments = append(comments, strings.TrimSpace(comment))
+			}
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case key == "LayerStart":
+			if layer != nil {
+				return &parseerr.Error{File: name, Line: lineNo, Layer: layer.Name, Err: fmt.Errorf("%w: LayerStart before LayerEnd of %s", parseerr.ErrMissingSection, layer.Name)}
+			}
+			layer = &Layer{Name: value, Show: true, Comments: comments, Line: lineNo}
+			comments = nil
+		case layer == nil:
+			return &parseerr.Error{File: name, Line: lineNo, Err: fmt.Errorf("%s outside LayerStart/LayerEnd", key)}
+		case key == "LayerEnd":
+			if err := block(*layer, lineNo); err != nil {
+				return err
+			}
+			blocks++
+			layer = nil
+		default:
+			if err := setValue(layer, scale, key, value); err != nil {
+				return &parseerr.Error{File: name, Line: lineNo, Layer: layer.Name, Err: err}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if layer != nil {
+		return &parseerr.Error{File: name, Layer: layer.Name, Err: fmt.Errorf("%w: LayerEnd", parseerr.ErrMissingSection)}
+	}
+	if blocks == 0 {
+		return &parseerr.Error{File: name, Err: fmt.Errorf("%w: no LayerStart", parseerr.ErrMissingSection)}
+	}
+	return nil
+}
+
+func setValue(layer *Layer, scale float64, key, value string) error {
+	var err error
+	switch key {
+	case "Layer":
+		layer.Layer, err = strconv.Atoi(value)
+	case "Datatype":
+		layer.Datatype, err = strconv.Atoi(value)
+	case "Height":
+		layer.Height, err = strconv.ParseFloat(value, 64)
+		layer.Height *= scale
+	case "Thickness":
+		layer.Thickness, err = strconv.ParseFloat(value, 64)
+		layer.Thickness *= scale
+	case "Red":
+		layer.Red, err = strconv.ParseFloat(value, 64)
+	case "Green", "Greeen":
+		layer.Green, err = strconv.ParseFloat(value, 64)
+	case "Blue":
+		layer.Blue, err = strconv.ParseFloat(value, 64)
+	case "Filter":
+		layer.Filter, err = strconv.ParseFloat(value, 64)
+	case "Metal":
+		layer.Metal, err = strconv.Atoi(value)
+	case "Shortkey":
+		layer.Shortkey = value
+	case "Show":
+		layer.Show = value != "0"
+	default:
+		return fmt.Errorf("unknown layer key %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("bad value for %s: %s: %w", key, value, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package gds3d
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+const metalsTechFile = `# Metal stack
+LayerStart: Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 0.22
+Greeen: 0.75
+Blue: 1.00
+Filter: 0.0
+Metal: 1
+Shortkey: 1
+Show: 1
+LayerEnd
+
+# Hidden by default
+# no Shortkey
+LayerStart: Via1
+Layer: 19
+Datatype: 0
+Height: 1330
+Thickness: 540
+Red: 0.80
+Greeen: 0.80
+Blue: 0.80
+Filter: 0.5
+Metal: 0
+Show: 0
+LayerEnd
+`
+
+func TestReadWriteRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		unit    string // of src
+		outUnit string // written by opts
+		opts    []Option
+	}{
+		{"default", metalsTechFile, "nm", "nm", nil},
+		{"um", metalsTechFile, "nm", "um", []Option{WithUnit("um", 3)}},
+		{"strict minimal", metalsTechFile, "nm", "nm", []Option{WithLegacySpelling(false), WithMinimalNumbers()}},
+		{"z order", metalsTechFile, "nm", "nm", []Option{WithZOrder()}},
+		{"um input", strings.NewReplacer("Height: 930", "Height: 0.93", "Height: 1330", "Height: 1.330", "Thickness: 400", "Thickness: 0.4", "Thickness: 540", "Thickness: 0.54").Replace(metalsTechFile), "um", "um", []Option{WithUnit("um", 4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layers, err := Read(strings.NewReader(tt.src), tt.name, tt.unit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var first bytes.Buffer
+			if err := Write(&first, layers, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			again, err := Read(bytes.NewReader(first.Bytes()), tt.name, tt.outUnit)
+			if err != nil {
+				t.Fatalf("reading the written techfile: %v\n%s", err, first.String())
+			}
+			if !sameLayers(layers, again) {
+				t.Errorf("read %+v\nwrote and read %+v", layers, again)
+			}
+			var second bytes.Buffer
+			if err := Write(&second, again, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if first.String() != second.String() {
+				t.Errorf("second write differs:\n%s\nfirst:\n%s", second.String(), first.String())
+			}
+		})
+	}
+}
+
+// Layers equal up to the lines they were read from and their order
+func sameLayers(a, b []Layer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	byName := map[string]Layer{}
+	for _, l := range a {
+		l.Line = 0
+		byName[l.Name] = l
+	}
+	for _, l := range b {
+		l.Line = 0
+		if !reflect.DeepEqual(byName[l.Name], l) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+		want string
+		is   error
+	}{
+		{"nested", "LayerStart: Metal1\nLayer: 8\nLayerStart: Metal2\nLayerEnd\n", 3, "LayerStart before LayerEnd of Metal1", parseerr.ErrMissingSection},
+		{"no end", "LayerStart: Metal1\nLayer: 8\n", 0, "LayerEnd", parseerr.ErrMissingSection},
+		{"empty", "# nothing\n", 0, "no LayerStart", parseerr.ErrMissingSection},
+		{"outside", "Layer: 8\n", 1, "Layer outside LayerStart/LayerEnd", nil},
+		{"bad value", "LayerStart: Metal1\nHeight: high\nLayerEnd\n", 2, "bad value for Height", nil},
+		{"unknown key", "LayerStart: Metal1\nDepth: 1\nLayerEnd\n", 2, "unknown layer key Depth", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.src), "t.txt", "nm")
+			var perr *parseerr.Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("got %v, want a *parseerr.Error", err)
+			}
+			if perr.Line != tt.line || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q at line %d, want %q at line %d", err, perr.Line, tt.want, tt.line)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("%v is not %v", err, tt.is)
+			}
+		})
+	}
+}
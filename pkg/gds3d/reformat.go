@@ -0,0 +1,44 @@
+package gds3d
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+)
+
+// Reformat copies the techfile r with Height and Thickness in unit to w,
+// every block written by f: its keys in the order and spelling of f, its
+// numbers with the precision of f. All other lines, comments and blank
+// lines, are copied as they are and where they are, so hand maintained
+// files keep their notes and layer order. Comments inside a block go right
+// before it, blank lines inside a block are dropped. On an error w holds
+// part of the file.
+func Reformat(ctx context.Context, w io.Writer, r io.Reader, name, unit string, f Format) error {
+	bw := bufio.NewWriter(w)
+	var inside []string
+	buf := make([]byte, 0, 512)
+	err := read(ctx, r, name, unit, func(l Layer, end int) error {
+		for _, line := range inside {
+			bw.WriteString(line)
+			bw.WriteByte('\n')
+		}
+		inside = inside[:0]
+		// The blank line AppendLayer ends with is the file's to give
+		buf = f.AppendLayer(buf[:0], l)
+		_, err := bw.Write(buf[:len(buf)-1])
+		return err
+	}, func(line string, inBlock bool) {
+		switch {
+		case !inBlock:
+			bw.WriteString(line)
+			bw.WriteByte('\n')
+		case strings.TrimSpace(line) != "":
+			inside = append(inside, line)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
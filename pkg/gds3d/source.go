@@ -0,0 +1,75 @@
+package gds3d
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+// Source is a techfile kept line by line as it was read, for tools that
+// change a few blocks of a hand maintained file and leave the rest of it,
+// header, comments and layer order, as it is
+type Source struct {
+	Layers []Layer // the blocks in file order
+	lines  []string
+	ends   []int // line of the LayerEnd of each block
+}
+
+// ReadSource reads a techfile like ReadContext and keeps its lines
+func ReadSource(ctx context.Context, r io.Reader, name, unit string) (*Source, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{}
+	err = read(ctx, bytes.NewReader(data), name, unit, func(l Layer, end int) error {
+		s.Layers = append(s.Layers, l)
+		s.ends = append(s.ends, end)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		s.lines = append(s.lines, scanner.Text())
+	}
+	return s, nil
+}
+
+// Write writes the file as read, except that the blocks in replace, by
+// their index in Layers, are written by f in place of the read ones and
+// the blocks of add are written by f after the last line. Replaced and
+// added blocks are written with their Comments before them.
+func (s *Source) Write(w io.Writer, f Format, replace map[int]Layer, add []Layer) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, 512)
+	block := 0
+	for i := 0; i < len(s.lines); i++ {
+		if block < len(s.Layers) && i == s.Layers[block].Line-1 {
+			if l, ok := replace[block]; ok {
+				buf = appendComments(buf[:0], l.Comments)
+				// The blank line AppendLayer ends with is the file's to give
+				buf = f.AppendLayer(buf, l)
+				if _, err := bw.Write(buf[:len(buf)-1]); err != nil {
+					return err
+				}
+				i = s.ends[block] - 1
+				block++
+				continue
+			}
+			block++
+		}
+		bw.WriteString(s.lines[i])
+		bw.WriteByte('\n')
+	}
+	for _, l := range add {
+		buf = appendComments(buf[:0], l.Comments)
+		buf = f.AppendLayer(buf, l)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,142 @@
+package gds3d
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/template"
+)
+
+// Option tunes Write
+type Option func(*writer)
+
+type writer struct {
+	format     Format
+	header     *template.Template
+	headerData any
+	footer     *template.Template
+	footerData any
+	order      func(a, b Layer) int
+	keep       func(Layer) bool
+	comments   bool
+}
+
+// WithFormat writes numbers and keys as f
+func WithFormat(f Format) Option {
+	return func(w *writer) { w.format = f }
+}
+
+// WithUnit writes Height and Thickness in unit, nm or um, with precision
+// decimals, below zero the default of the unit
+func WithUnit(unit string, precision int) Option {
+	return func(w *writer) {
+		w.format.Unit = unit
+		w.format.Precision = precision
+	}
+}
+
+// WithMinimalNumbers drops trailing zeros, 0.80 as 0.8 and 1.000 as 1
+func WithMinimalNumbers() Option {
+	return func(w *writer) { w.format.Minimal = true }
+}
+
+// WithLegacySpelling writes Greeen as the GDS3D builds of the first
+// techfiles want, the default, or Green when false
+func WithLegacySpelling(legacy bool) Option {
+	return func(w *writer) {
+		w.format.Keys = StrictKeys
+		if legacy {
+			w.format.Keys = LegacyKeys
+		}
+	}
+}
+
+// WithHeader runs t with data before the first layer
+func WithHeader(t *template.Template, data any) Option {
+	return func(w *writer) { w.header, w.headerData = t, data }
+}
+
+// WithFooter runs t with data after the last layer
+func WithFooter(t *template.Template, data any) Option {
+	return func(w *writer) { w.footer, w.footerData = t, data }
+}
+
+// WithOrder sorts the layers with cmp, layers that compare equal keep
+// their order
+func WithOrder(cmp func(a, b Layer) int) Option {
+	return func(w *writer) { w.order = cmp }
+}
+
+// WithZOrder sorts the layers bottom up
+func WithZOrder() Option {
+	return WithOrder(func(a, b Layer) int { return cmp.Compare(a.Height, b.Height) })
+}
+
+// WithFilter writes only the layers keep returns true for
+func WithFilter(keep func(Layer) bool) Option {
+	return func(w *writer) { w.keep = keep }
+}
+
+// WithoutComments leaves out the Comments of the layers
+func WithoutComments() Option {
+	return func(w *writer) { w.comments = false }
+}
+
+// Write writes layers as a techfile, by default the way GDS3D expects it:
+// nanometer, the legacy key spelling, in the order given and with the
+// Comments of every layer before its block.
+func Write(w io.Writer, layers []Layer, opts ...Option) error {
+	cfg := writer{format: DefaultFormat, comments: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if _, ok := Units[cfg.format.Unit]; !ok {
+		return fmt.Errorf("unknown unit %s", cfg.format.Unit)
+	}
+	if cfg.keep != nil {
+		layers = slices.DeleteFunc(slices.Clone(layers), func(l Layer) bool { return !cfg.keep(l) })
+	}
+	if cfg.order != nil {
+		layers = slices.Clone(layers)
+		slices.SortStableFunc(layers, cfg.order)
+	}
+
+	bw := bufio.NewWriter(w)
+	if cfg.header != nil {
+		if err := cfg.header.Execute(bw, cfg.headerData); err != nil {
+			return fmt.Errorf("header: %w", err)
+		}
+	}
+	// One buffer for all blocks, bw only copies it
+	buf := make([]byte, 0, 512)
+	for _, l := range layers {
+		buf = buf[:0]
+		if cfg.comments {
+			buf = appendComments(buf, l.Comments)
+		}
+		buf = cfg.format.AppendLayer(buf, l)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	if cfg.footer != nil {
+		if err := cfg.footer.Execute(bw, cfg.footerData); err != nil {
+			return fmt.Errorf("footer: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// Comment lines, "" as an empty line
+func appendComments(dst []byte, comments []string) []byte {
+	for _, c := range comments {
+		if c != "" {
+			dst = append(dst, "# "...)
+			dst = append(dst, c...)
+		}
+		dst = append(dst, '\n')
+	}
+	return dst
+}
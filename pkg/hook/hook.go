@@ -0,0 +1,71 @@
+// Package hook lets Go code change the resolved stack between parsing and
+// writing, for one-off customizations that do not warrant a stack config
+// feature: a custom layer, a color for a demo, a thickness from a test chip.
+//
+// A hook package registers its functions from init, like the format
+// packages, and -hook runs them by name in the order given:
+//
+//	func init() {
+//		hook.Register("demo-colors", func(ctx context.Context, s *ir.Stack) error {
+//			if l := s.Find("Metal1"); l != nil {
+//				l.Color = "#ff0000"
+//			}
+//			return nil
+//		})
+//	}
+//
+// Hooks run for every variant and corner after the stack is resolved and
+// before it is checked, so what they add or change is validated and
+// written like any other layer.
+package hook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+)
+
+// Func changes s in place, an error stops writing the variant
+type Func func(ctx context.Context, s *ir.Stack) error
+
+var (
+	mu    sync.RWMutex
+	hooks = map[string]Func{}
+)
+
+// Register makes fn available as name. It panics if fn is nil or the name
+// is taken, both are mistakes in the hook package.
+func Register(name string, fn Func) {
+	mu.Lock()
+	defer mu.Unlock()
+	if fn == nil {
+		panic("hook: Register of nil")
+	}
+	if _, dup := hooks[name]; dup {
+		panic(fmt.Sprintf("hook: %s registered twice", name))
+	}
+	hooks[name] = fn
+}
+
+// Lookup is the hook registered as name
+func Lookup(name string) (Func, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	fn, ok := hooks[name]
+	return fn, ok
+}
+
+// Names are the registered hooks, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,118 @@
+// Package ir is the resolved layer stack as external tools see it.
+//
+// Stack is what build_3d_techfile writes with -export ir (TOML) and
+// -export ir-json and reads back with -from-ir. The field names below are
+// the keys in every format, lengths are in micron:
+//
+//	{"schema_version": 11, "units": "um", "layers": [{"name": "Metal1", ...}]}
+//
+// Fields are only ever added. SchemaVersion is bumped when that happens
+// and readers refuse stacks newer than they know, older ones read with
+// the zero value of the fields they miss.
+package ir
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SchemaVersion is the version written. Version 2 added filter, 3 show,
+// 4 shortkey, 5 group, 6 z_from, 7 shared_look, 8 dielectric, 9 mask,
+// 10 derived and 11 tolerance, stacks without them are read as opaque,
+// shown, without keys, grouped by type, with z and look of their own,
+// without slabs, masks, derived layers and tolerances.
+const SchemaVersion = 11
+
+// Units of Height, Thickness and Tolerance
+const Units = "um"
+
+// Stack is the resolved stack, bottom to top as the techfile lists it
+type Stack struct {
+	SchemaVersion int     `json:"schema_version" yaml:"schema_version"`
+	Units         string  `json:"units" yaml:"units"`
+	Layers        []Layer `json:"layers" yaml:"layers"`
+}
+
+// Layer is one layer of the stack
+type Layer struct {
+	Name        string   `json:"name" yaml:"name"`
+	AltName     string   `json:"alt_name" yaml:"alt_name"` // name of the layer in the LEF
+	GDSLayer    int      `json:"gds_layer" yaml:"gds_layer"`
+	GDSDatatype int      `json:"gds_datatype" yaml:"gds_datatype"`
+	Color       string   `json:"color" yaml:"color"`         // #rrggbb
+	Height      float64  `json:"height" yaml:"height"`       // bottom, um
+	Thickness   float64  `json:"thickness" yaml:"thickness"` // um
+	Metal       bool     `json:"metal" yaml:"metal"`
+	Filter      float64  `json:"filter" yaml:"filter"`             // transparency, 0.0 opaque to 1.0 invisible
+	Show        bool     `json:"show" yaml:"show"`                 // shown when GDS3D opens the techfile
+	Shortkey    string   `json:"shortkey" yaml:"shortkey"`         // GDS3D key toggling the layer, "" for none
+	Group       string   `json:"group" yaml:"group"`               // techfile section
+	ZFrom       string   `json:"z_from" yaml:"z_from"`             // layer whose z an extra datatype entry shares
+	SharedLook  bool     `json:"shared_look" yaml:"shared_look"`   // also drawn with the look of ZFrom
+	Dielectric  bool     `json:"dielectric" yaml:"dielectric"`     // slab, not drawn in the layout
+	Mask        int      `json:"mask" yaml:"mask"`                 // multi-patterning mask, from 1, 0 for none
+	Derived     string   `json:"derived" yaml:"derived"`           // boolean expression of gds/datatype pairs
+	Tolerance   float64  `json:"tolerance" yaml:"tolerance"`       // thickness tolerance, um, +-
+	LEFConnects []string `json:"lef_connects" yaml:"lef_connects"` // layers a cut layer connects
+	ColorSource string   `json:"color_source" yaml:"color_source"` // where GDS numbers and color came from
+	ZSource     string   `json:"z_source" yaml:"z_source"`         // where height and thickness came from
+}
+
+// New is a Stack of layers at the current SchemaVersion
+func New(layers []Layer) Stack {
+	return Stack{SchemaVersion: SchemaVersion, Units: Units, Layers: layers}
+}
+
+// Find is layer name of s, nil if there is none
+func (s *Stack) Find(name string) *Layer {
+	for i := range s.Layers {
+		if s.Layers[i].Name == name {
+			return &s.Layers[i]
+		}
+	}
+	return nil
+}
+
+// Check refuses stacks this version cannot read, newer or not in micron
+func (s Stack) Check() error {
+	if s.SchemaVersion <= 0 || s.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("unsupported IR version %d (this build reads up to %d)", s.SchemaVersion, SchemaVersion)
+	}
+	if s.Units != Units {
+		return fmt.Errorf("unsupported units %q", s.Units)
+	}
+	return nil
+}
+
+// WriteJSON writes s indented
+func (s Stack) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadJSON reads a Stack written by WriteJSON and Checks it. Layers are
+// shown unless they say otherwise, as in the versions before show.
+func ReadJSON(r io.Reader) (Stack, error) {
+	var raw struct {
+		Stack
+		Layers []json.RawMessage `json:"layers"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return Stack{}, err
+	}
+	s := raw.Stack
+	if err := s.Check(); err != nil {
+		return Stack{}, err
+	}
+	s.Layers = make([]Layer, 0, len(raw.Layers))
+	for i, data := range raw.Layers {
+		l := Layer{Show: true}
+		if err := json.Unmarshal(data, &l); err != nil {
+			return Stack{}, fmt.Errorf("layer %d: %w", i+1, err)
+		}
+		s.Layers = append(s.Layers, l)
+	}
+	return s, nil
+}
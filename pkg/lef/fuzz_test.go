@@ -0,0 +1,33 @@
+package lef
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func FuzzParseBytes(f *testing.F) {
+	if data, err := os.ReadFile("../../sg13g2_tech.lef"); err == nil {
+		f.Add(data)
+	}
+	f.Add([]byte(fmt.Sprintf(viaRuleLEF, "VIARULE")))
+	f.Add([]byte("LAYER Metal1\n  TYPE ROUTING ;\n  THICKNESS abc ;\nEND Metal1\n"))
+	f.Add([]byte("LAYER\nVIA Via1 DEFAULT\n  LAYER Metal1 ;\n"))
+	f.Add([]byte("UNITS\n  DATABASE MICRONS 1000 ;\nEND UNITS\nLAYER Metal1\n  HEIGHT 1e400 ;\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		lefFile, warnings, err := ParseBytes(data)
+		// Lines longer than the buffer are joined, the result does not
+		// depend on its size
+		small, smallWarnings, smallErr := ParseBytes(data, WithBufferSize(16))
+		if (err == nil) != (smallErr == nil) {
+			t.Fatalf("error %v with the default buffer, %v with 16 bytes", err, smallErr)
+		}
+		if err == nil && !reflect.DeepEqual(lefFile, small) {
+			t.Errorf("parsed %+v with the default buffer, %+v with 16 bytes", lefFile, small)
+		}
+		if err == nil && !reflect.DeepEqual(warnings, smallWarnings) {
+			t.Errorf("warnings %v with the default buffer, %v with 16 bytes", warnings, smallWarnings)
+		}
+	})
+}
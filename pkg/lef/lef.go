@@ -0,0 +1,221 @@
+// Package lef reads the layer and via definitions of a technology LEF.
+//
+// Only what a 3D stack needs is kept: TYPE, THICKNESS and HEIGHT of the
+// routing and cut layers, and the layers each fixed via is made of.
+/*
+
+LAYER Metal1
+  TYPE ROUTING ;
+  HEIGHT 0.930 ;
+  THICKNESS 0.40 ;
+END Metal1
+
+VIA Via1_YX DEFAULT
+  LAYER Metal1 ;
+    RECT -0.19 -0.145 0.19 0.145 ;
+  LAYER Via1 ;
+  LAYER Metal2 ;
+END Via1_YX
+*/
+package lef
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+type Layer struct {
+	Name      string
+	Type      string
+	Thickness float64
+	Height    float64
+	HasHeight bool // HEIGHT given, many LEFs only have THICKNESS
+}
+
+// Fixed via definition, the metal and cut layers it is made of
+type Via struct {
+	Name   string
+	Layers []string
+}
+
+type File struct {
+	Layers      []Layer
+	Vias        []Via
+	Version     float64
+	DividerChar string
+}
+
+const (
+	modeIdle = iota
+	modeUnits
+	modeLayer
+	modeLayerIgnore
+	modeVia
+	modeViaIgnore
+)
+
+// Parse reads a LEF. Layers for which keep returns false are skipped, a
+// nil keep keeps all of them. Vias are kept whatever layers they use.
+// Malformed statements are a *parseerr.Error with the line and layer, a
+// layer defined twice is a warning and both definitions are returned.
+// Lines may be of any length up to the limit of WithMaxLineLength.
+func Parse(r io.Reader, keep func(name string) bool, opts ...Option) (*File, []parseerr.Warning, error) {
+	return ParseContext(context.Background(), r, keep, opts...)
+}
+
+// ParseContext is Parse that stops with the context error once ctx is done
+func ParseContext(ctx context.Context, r io.Reader, keep func(name string) bool, opts ...Option) (*File, []parseerr.Warning, error) {
+	mode := modeIdle
+	scanner := newLineReader(ctxio.NewReader(ctx, r), opts)
+	lefFile := &File{}
+	var warnings []parseerr.Warning
+	defined := map[string]int{} // line of each LAYER, kept or not
+
+	currentLayer := Layer{}
+	currentVia := Via{}
+	section := ""
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo = scanner.no
+		tokens := strings.Fields(string(scanner.Bytes()))
+		if len(tokens) == 0 {
+			continue
+		}
+		fail := func(format string, args ...any) error {
+			layer := ""
+			if mode == modeLayer {
+				layer = currentLayer.Name
+			}
+			return &parseerr.Error{Line: lineNo, Layer: layer, Err: fmt.Errorf(format, args...)}
+		}
+		// Every statement the parser reads has a value
+		if mode == modeIdle || mode == modeLayer || mode == modeVia {
+			switch tokens[0] {
+			case "VERSION", "DIVIDERCHAR", "LAYER", "Via", "VIA", "ViaRULE", "VIARULE", "TYPE", "THICKNESS", "HEIGHT":
+				if len(tokens) < 2 {
+					return nil, nil, fail("%s without a value", tokens[0])
+				}
+			}
+		}
+		number := func() (float64, error) {
+			v, err := strconv.ParseFloat(tokens[1], 64)
+			if err != nil {
+				return 0, fail("bad %s %q: %w", tokens[0], tokens[1], err)
+			}
+			return v, nil
+		}
+
+		// Find section and simple key value pairs
+		switch mode {
+		case modeIdle:
+			switch tokens[0] {
+			case "VERSION":
+				version, err := number()
+				if err != nil {
+					return nil, nil, err
+				}
+				lefFile.Version = version
+			case "DIVIDERCHAR":
+				lefFile.DividerChar = tokens[1]
+			case "UNITS":
+				mode, section = modeUnits, "UNITS"
+			case "LAYER":
+				if first, ok := defined[tokens[1]]; ok {
+					warnings = append(warnings, parseerr.Warning{
+						Code:     "duplicate-layer",
+						Severity: parseerr.SeverityWarning,
+						Message:  fmt.Sprintf("layer %s is defined again, first at line %d", tokens[1], first),
+						Layers:   []string{tokens[1]},
+						Line:     lineNo,
+					})
+				} else {
+					defined[tokens[1]] = lineNo
+				}
+				if keep == nil || keep(tokens[1]) {
+					currentLayer = Layer{Name: tokens[1]}
+					mode = modeLayer
+				} else {
+					mode = modeLayerIgnore
+				}
+				section = "LAYER " + tokens[1]
+			case "Via", "VIA":
+				currentVia = Via{Name: tokens[1]}
+				mode, section = modeVia, "VIA "+tokens[1]
+			case "ViaRULE", "VIARULE":
+				// The LAYER statements of a rule are not layer sections
+				mode, section = modeViaIgnore, "VIARULE "+tokens[1]
+			}
+		case modeUnits:
+			if tokens[0] == "END" {
+				mode = modeIdle
+			}
+		case modeLayer:
+			switch tokens[0] {
+			case "TYPE":
+				currentLayer.Type = tokens[1]
+			case "THICKNESS":
+				thickness, err := number()
+				if err != nil {
+					return nil, nil, err
+				}
+				currentLayer.Thickness = thickness
+			case "HEIGHT":
+				height, err := number()
+				if err != nil {
+					return nil, nil, err
+				}
+				currentLayer.Height = height
+				currentLayer.HasHeight = true
+			case "END":
+				lefFile.Layers = append(lefFile.Layers, currentLayer)
+				mode = modeIdle
+			}
+		case modeVia:
+			switch tokens[0] {
+			case "LAYER":
+				if !contains(currentVia.Layers, tokens[1]) {
+					currentVia.Layers = append(currentVia.Layers, tokens[1])
+				}
+			case "END":
+				lefFile.Vias = append(lefFile.Vias, currentVia)
+				mode = modeIdle
+			}
+		case modeLayerIgnore, modeViaIgnore:
+			if tokens[0] == "END" {
+				mode = modeIdle
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if mode != modeIdle {
+		return nil, nil, &parseerr.Error{Line: lineNo, Err: fmt.Errorf("%w: END of %s", parseerr.ErrMissingSection, section)}
+	}
+	return lefFile, warnings, nil
+}
+
+func contains(s []string, str string) bool {
+	for _, v := range s {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBytes is Parse of a LEF in memory, keeping all layers. Malformed
+// input is an error, never a panic, so it can be fuzzed and fed untrusted
+// files.
+func ParseBytes(data []byte, opts ...Option) (*File, []parseerr.Warning, error) {
+	return Parse(bytes.NewReader(data), nil, opts...)
+}
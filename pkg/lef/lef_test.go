@@ -0,0 +1,92 @@
+package lef
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const viaRuleLEF = `VERSION 5.8 ;
+LAYER Metal1
+  TYPE ROUTING ;
+  HEIGHT 0.93 ;
+  THICKNESS 0.4 ;
+END Metal1
+LAYER Via1
+  TYPE CUT ;
+END Via1
+LAYER Metal2
+  TYPE ROUTING ;
+  THICKNESS 0.45 ;
+END Metal2
+%s M2_M1 GENERATE
+  LAYER Metal1 ;
+    ENCLOSURE 0.05 0.005 ;
+  LAYER Metal2 ;
+    ENCLOSURE 0.05 0.005 ;
+  LAYER Via1 ;
+    RECT -0.095 -0.095 0.095 0.095 ;
+    SPACING 0.4 BY 0.4 ;
+END M2_M1
+END LIBRARY
+`
+
+func TestParseViaRule(t *testing.T) {
+	for _, keyword := range []string{"VIARULE", "ViaRULE"} {
+		t.Run(keyword, func(t *testing.T) {
+			f, warnings, err := ParseBytes([]byte(fmt.Sprintf(viaRuleLEF, keyword)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) != 0 {
+				t.Errorf("warnings %v", warnings)
+			}
+			want := []Layer{
+				{Name: "Metal1", Type: "ROUTING", Height: 0.93, Thickness: 0.4, HasHeight: true},
+				{Name: "Via1", Type: "CUT"},
+				{Name: "Metal2", Type: "ROUTING", Thickness: 0.45},
+			}
+			if len(f.Layers) != len(want) {
+				t.Fatalf("got %d layers %+v, want %d", len(f.Layers), f.Layers, len(want))
+			}
+			for i := range want {
+				if f.Layers[i] != want[i] {
+					t.Errorf("layer %d = %+v, want %+v", i, f.Layers[i], want[i])
+				}
+			}
+			if len(f.Vias) != 0 {
+				t.Errorf("via rule read as vias %+v", f.Vias)
+			}
+		})
+	}
+}
+
+func TestParseDuplicateLayer(t *testing.T) {
+	src := `LAYER Metal1
+  TYPE ROUTING ;
+  THICKNESS 0.4 ;
+END Metal1
+LAYER Metal1
+  TYPE ROUTING ;
+  THICKNESS 0.5 ;
+END Metal1
+`
+	f, warnings, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Layers) != 2 {
+		t.Errorf("got layers %+v, want both definitions", f.Layers)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("got warnings %v, want one", warnings)
+	}
+	if w := warnings[0]; w.Code != "duplicate-layer" || w.Line != 5 || w.Message != "layer Metal1 is defined again, first at line 1" {
+		t.Errorf("got %+v", w)
+	}
+	// Skipped layers count too
+	_, warnings, err = Parse(strings.NewReader(src), func(string) bool { return false })
+	if err != nil || len(warnings) != 1 {
+		t.Errorf("with no layers kept got %v, %v, want one warning", warnings, err)
+	}
+}
@@ -0,0 +1,93 @@
+package lef
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+// ErrLineTooLong is a line beyond the limit of WithMaxLineLength
+var ErrLineTooLong = errors.New("line too long")
+
+// Defaults of the reader, a chunk is what is read at once, a line may be
+// assembled from many of them. SPACINGTABLE and PROPERTYDEFINITIONS lines
+// of real LEFs run to megabytes, far beyond the 64 KiB bufio.Scanner stops at.
+const (
+	DefaultBufferSize    = 64 << 10
+	DefaultMaxLineLength = 64 << 20
+)
+
+// Option tunes Parse
+type Option func(*options)
+
+type options struct {
+	bufferSize    int
+	maxLineLength int
+}
+
+// WithBufferSize reads the LEF in chunks of n bytes
+func WithBufferSize(n int) Option {
+	return func(o *options) { o.bufferSize = n }
+}
+
+// WithMaxLineLength fails lines longer than n bytes with ErrLineTooLong
+// instead of holding them in memory, 0 for no limit
+func WithMaxLineLength(n int) Option {
+	return func(o *options) { o.maxLineLength = n }
+}
+
+// Reads the LEF a line at a time, joining the chunks bufio.Reader hands
+// out for lines longer than its buffer. The line is reused, it is only
+// valid until the next call.
+type lineReader struct {
+	r    *bufio.Reader
+	max  int
+	line []byte
+	no   int
+	err  error
+}
+
+func newLineReader(r io.Reader, opts []Option) *lineReader {
+	o := options{bufferSize: DefaultBufferSize, maxLineLength: DefaultMaxLineLength}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &lineReader{r: bufio.NewReaderSize(r, o.bufferSize), max: o.maxLineLength}
+}
+
+// Next line, false at the end of the input or on an error, see Err
+func (lr *lineReader) Scan() bool {
+	if lr.err != nil {
+		return false
+	}
+	lr.line = lr.line[:0]
+	lr.no++
+	for {
+		chunk, more, err := lr.r.ReadLine()
+		if err != nil {
+			// ReadLine never returns data with an error
+			if err != io.EOF {
+				lr.err = err
+			} else if len(lr.line) > 0 {
+				return true
+			}
+			lr.no--
+			return false
+		}
+		if lr.max > 0 && len(lr.line)+len(chunk) > lr.max {
+			lr.err = &parseerr.Error{Line: lr.no, Err: fmt.Errorf("%w: more than %d bytes", ErrLineTooLong, lr.max)}
+			return false
+		}
+		lr.line = append(lr.line, chunk...)
+		if !more {
+			return true
+		}
+	}
+}
+
+func (lr *lineReader) Bytes() []byte { return lr.line }
+
+func (lr *lineReader) Err() error { return lr.err }
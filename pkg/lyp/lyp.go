@@ -0,0 +1,133 @@
+// Package lyp reads KLayout layer properties files (.lyp).
+//
+// Every <properties> entry is returned as a Layer with its name, GDS
+// source, fill color and the line it starts on, e.g.
+/*
+
+<layer-properties>
+  <properties>
+    <fill-color>#39bfff</fill-color>
+    <visible>true</visible>
+    <source>8/0</source>
+    <name>Metal1.drawing</name>
+  </properties>
+</layer-properties>
+*/
+package lyp
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+// Layer is one properties entry of a lyp file
+type Layer struct {
+	Name    string   `xml:"name"`
+	Number  string   `xml:"source"` // gds/datatype, e.g. 8/0
+	Color   string   `xml:"fill-color"`
+	Visible string   `xml:"visible"`
+	Line    int      `xml:"-"` // where the properties start in the lyp file
+	XMLName xml.Name `xml:"properties"`
+}
+
+// A properties entry as read, with the group members Layer leaves out
+type properties struct {
+	Name    string     `xml:"name"`
+	Number  string     `xml:"source"`
+	Color   string     `xml:"fill-color"`
+	Visible string     `xml:"visible"`
+	Members []struct{} `xml:"group-members"`
+}
+
+// Parse returns the top level properties of a lyp file in file order.
+// Groups of properties nested in others are not descended into, each
+// group is a warning.
+func Parse(r io.Reader) ([]Layer, []parseerr.Warning, error) {
+	return ParseContext(context.Background(), r)
+}
+
+// ParseContext is Parse that stops with the context error once ctx is done
+func ParseContext(ctx context.Context, r io.Reader) ([]Layer, []parseerr.Warning, error) {
+	r = ctxio.NewReader(ctx, r)
+	// Decode the properties one by one to know the line each starts on
+	decoder := xml.NewDecoder(r)
+	var layers []Layer
+	var warnings []parseerr.Warning
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 1 && t.Name.Local == "properties" {
+				line, _ := decoder.InputPos()
+				var prop properties
+				if err := decoder.DecodeElement(&prop, &t); err != nil {
+					return nil, nil, err
+				}
+				layers = append(layers, Layer{Name: prop.Name, Number: prop.Number, Color: prop.Color, Visible: prop.Visible, Line: line})
+				if len(prop.Members) > 0 {
+					warnings = append(warnings, parseerr.Warning{
+						Code:     "lyp-group",
+						Severity: parseerr.SeverityWarning,
+						Message:  fmt.Sprintf("group %q: %d nested properties skipped", prop.Name, len(prop.Members)),
+						Line:     line,
+					})
+				}
+				continue
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return layers, warnings, nil
+}
+
+// GDS is the layer number and datatype of the source, a *parseerr.Error
+// with the line of the properties if it is not gds/datatype
+func (l Layer) GDS() (int, int, error) {
+	number, datatype, err := ParseSource(l.Number)
+	if err != nil {
+		return 0, 0, &parseerr.Error{Line: l.Line, Layer: l.Name, Err: err}
+	}
+	return number, datatype, nil
+}
+
+// ParseSource splits a gds/datatype source, e.g. 8/0. A layout index
+// after @ is ignored.
+func ParseSource(source string) (int, int, error) {
+	source, _, _ = strings.Cut(source, "@")
+	n, d, ok := strings.Cut(source, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("bad source %q, expected gds/datatype", source)
+	}
+	number, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad source %q: %w", source, err)
+	}
+	datatype, err := strconv.Atoi(strings.TrimSpace(d))
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad source %q: %w", source, err)
+	}
+	return number, datatype, nil
+}
+
+// ParseBytes is Parse of a lyp in memory. Malformed input is an error,
+// never a panic, so it can be fuzzed and fed untrusted files.
+func ParseBytes(data []byte) ([]Layer, []parseerr.Warning, error) {
+	return Parse(bytes.NewReader(data))
+}
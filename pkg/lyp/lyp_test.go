@@ -0,0 +1,79 @@
+package lyp
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+)
+
+func lypOf(props string) []byte {
+	return []byte("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<layer-properties>\n" + props + "</layer-properties>\n")
+}
+
+func FuzzParseBytes(f *testing.F) {
+	if data, err := os.ReadFile("../../sg13g2.lyp"); err == nil {
+		f.Add(data)
+	}
+	f.Add(lypOf(" <properties>\n  <fill-color>#39bfff</fill-color>\n  <source>8/0@1</source>\n  <name>Metal1.drawing</name>\n </properties>\n"))
+	// Short colors and sources without a datatype
+	f.Add(lypOf(" <properties>\n  <fill-color>#39</fill-color>\n  <source>8/0</source>\n  <name>Metal1.drawing</name>\n </properties>\n"))
+	f.Add(lypOf(" <properties>\n  <fill-color></fill-color>\n  <source>8</source>\n  <name>Metal1.drawing</name>\n </properties>\n"))
+	f.Add(lypOf(" <properties>\n  <source>/</source>\n </properties>\n <group-members>\n  <properties><source>1/0</source></properties>\n </group-members>\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		layers, warnings, err := ParseBytes(data)
+		if err != nil {
+			return
+		}
+		for _, w := range warnings {
+			if w.Line < 1 {
+				t.Errorf("warning without a line: %v", w)
+			}
+		}
+		for _, l := range layers {
+			if l.Line < 1 {
+				t.Errorf("%q: line %d", l.Name, l.Line)
+			}
+			// Malformed values are errors, never panics
+			l.GDS()
+			gds3d.ParseColor(l.Color)
+		}
+	})
+}
+
+func TestParseGroups(t *testing.T) {
+	layers, warnings, err := ParseBytes(lypOf(` <properties>
+  <fill-color>#39bfff</fill-color>
+  <source>8/0</source>
+  <name>Metal1.drawing</name>
+ </properties>
+ <properties>
+  <name>Pins</name>
+  <group-members>
+   <source>8/2</source>
+   <name>Metal1.pin</name>
+  </group-members>
+  <group-members>
+   <source>10/2</source>
+   <name>Metal2.pin</name>
+  </group-members>
+ </properties>
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range layers {
+		names = append(names, l.Name)
+	}
+	if len(names) != 2 || names[0] != "Metal1.drawing" || names[1] != "Pins" {
+		t.Errorf("got layers %q, want Metal1.drawing and Pins", names)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("got warnings %v, want one for the group", warnings)
+	}
+	if w := warnings[0]; w.Code != "lyp-group" || w.Line != 8 || !strings.Contains(w.Message, "2 nested properties") {
+		t.Errorf("got %+v, want lyp-group at line 8 with 2 nested properties", w)
+	}
+}
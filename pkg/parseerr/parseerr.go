@@ -0,0 +1,79 @@
+// Package parseerr has the errors and warnings the input parsers return.
+// Problems at a place in an input are an *Error with as much of file, line
+// and layer as is known, the sentinels tell what kind of problem it is:
+//
+//	var perr *parseerr.Error
+//	if errors.As(err, &perr) {
+//		fmt.Println("look at line", perr.Line)
+//	}
+//	if errors.Is(err, parseerr.ErrBadColor) {
+//		...
+//	}
+//
+// Problems the parsers read past are a Warning, returned next to the
+// result.
+package parseerr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// A color that is not #rrggbb
+	ErrBadColor = errors.New("bad color, expected #rrggbb")
+	// A section the input needs is not there, e.g. a techfile without
+	// LayerStart or a LEF layer without END
+	ErrMissingSection = errors.New("missing section")
+)
+
+// Error is a problem in an input, fields that are not known are left zero
+type Error struct {
+	File  string
+	Line  int
+	Layer string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Line > 0 {
+		if e.File == "" {
+			b.WriteString("line ")
+		} else {
+			b.WriteString(":")
+		}
+		fmt.Fprintf(&b, "%d", e.Line)
+	}
+	if e.Layer != "" {
+		if b.Len() > 0 {
+			b.WriteString(": ")
+		}
+		b.WriteString("layer " + e.Layer)
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// InFile adds the file name to err, for parsers that read from an
+// io.Reader and do not know it
+func InFile(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var perr *Error
+	if errors.As(err, &perr) && perr.File == "" {
+		perr.File = name
+		return err
+	}
+	return &Error{File: name, Err: err}
+}
@@ -0,0 +1,64 @@
+package parseerr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity of a Warning
+type Severity int
+
+const (
+	SeverityInfo    Severity = iota // the input was adjusted as asked, e.g. a clamped sliver
+	SeverityWarning                 // likely a mistake in the inputs, the output is written anyway
+	SeverityError                   // the output will not look right in GDS3D
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Warning is a problem in an input that does not stop parsing it. Parsers
+// return them next to their result, it is up to the caller to print them.
+type Warning struct {
+	Code     string   `json:"code"` // kind of problem, e.g. unknown-layer or overlap
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Layers   []string `json:"layers,omitempty"` // layers involved, if known
+	File     string   `json:"file,omitempty"`   // input and line it is about, if known
+	Line     int      `json:"line,omitempty"`
+}
+
+// String is the warning as a command prints it
+func (w Warning) String() string {
+	prefix := strings.ToUpper(w.Severity.String()[:1]) + w.Severity.String()[1:]
+	switch {
+	case w.File != "" && w.Line > 0:
+		return fmt.Sprintf("%s: %s:%d: %s", prefix, w.File, w.Line, w.Message)
+	case w.File != "":
+		return fmt.Sprintf("%s: %s: %s", prefix, w.File, w.Message)
+	case w.Line > 0:
+		return fmt.Sprintf("%s: line %d: %s", prefix, w.Line, w.Message)
+	}
+	return prefix + ": " + w.Message
+}
+
+// WarningsInFile adds the file name to the warnings that have none, for
+// parsers that read from an io.Reader and do not know it
+func WarningsInFile(name string, warnings []Warning) []Warning {
+	for i := range warnings {
+		if warnings[i].File == "" {
+			warnings[i].File = name
+		}
+	}
+	return warnings
+}
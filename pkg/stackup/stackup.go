@@ -0,0 +1,105 @@
+// Package stackup reads layer stackup XML exported by HFSS/SIwave style
+// tools.
+//
+// Only the Layers section is used, e.g.
+/*
+
+<c:Control xmlns:c="http://www.ansys.com/control" schemaVersion="1.0">
+  <Stackup schemaVersion="1.0">
+    <Layers LengthUnit="um">
+      <Layer Name="TopMetal1" Type="conductor" Material="aluminum" Elevation="6.16" Thickness="2.0"/>
+      <Layer Name="IMD5" Type="dielectric" Material="SiO2" Elevation="5.33" Thickness="0.83"/>
+    </Layers>
+  </Stackup>
+</c:Control>
+*/
+// Exporters list the layers top down. When Elevation is left out the layers are
+// stacked on top of each other starting from z=0 at the last layer.
+package stackup
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+// LengthUnits are the scale factors to micron of the LengthUnit attribute.
+// An empty unit is micron.
+var LengthUnits = map[string]float64{
+	"":      1.0,
+	"um":    1.0,
+	"nm":    1e-3,
+	"mm":    1e3,
+	"m":     1e6,
+	"meter": 1e6,
+	"mil":   25.4,
+}
+
+type Layer struct {
+	Name      string  `xml:"Name,attr"`
+	Type      string  `xml:"Type,attr"`
+	Material  string  `xml:"Material,attr"`
+	Elevation string  `xml:"Elevation,attr"`
+	Thickness string  `xml:"Thickness,attr"`
+	Height    float64 `xml:"-"` // um
+	Thick     float64 `xml:"-"` // um
+}
+
+type Stackup struct {
+	Stackup struct {
+		Layers *struct {
+			LengthUnit string  `xml:"LengthUnit,attr"`
+			Layer      []Layer `xml:"Layer"`
+		} `xml:"Layers"`
+	} `xml:"Stackup"`
+}
+
+// Parse returns the layers top down as in the file, with Height and Thick
+// resolved to micron. Without a Layers section it fails with
+// parseerr.ErrMissingSection, bad numbers are a *parseerr.Error.
+func Parse(r io.Reader) ([]Layer, error) {
+	return ParseContext(context.Background(), r)
+}
+
+// ParseContext is Parse that stops with the context error once ctx is done
+func ParseContext(ctx context.Context, r io.Reader) ([]Layer, error) {
+	var stackup Stackup
+	if err := xml.NewDecoder(ctxio.NewReader(ctx, r)).Decode(&stackup); err != nil {
+		return nil, err
+	}
+	if stackup.Stackup.Layers == nil {
+		return nil, fmt.Errorf("%w: Stackup/Layers", parseerr.ErrMissingSection)
+	}
+
+	unit := strings.ToLower(stackup.Stackup.Layers.LengthUnit)
+	scale, ok := LengthUnits[unit]
+	if !ok {
+		return nil, fmt.Errorf("unknown LengthUnit %q", unit)
+	}
+
+	layers := stackup.Stackup.Layers.Layer
+	z := 0.0
+	for i := len(layers) - 1; i >= 0; i-- {
+		thickness, err := strconv.ParseFloat(layers[i].Thickness, 64)
+		if err != nil {
+			return nil, &parseerr.Error{Layer: layers[i].Name, Err: fmt.Errorf("bad Thickness %q: %w", layers[i].Thickness, err)}
+		}
+		layers[i].Thick = thickness * scale
+		layers[i].Height = z
+		if layers[i].Elevation != "" {
+			elevation, err := strconv.ParseFloat(layers[i].Elevation, 64)
+			if err != nil {
+				return nil, &parseerr.Error{Layer: layers[i].Name, Err: fmt.Errorf("bad Elevation %q: %w", layers[i].Elevation, err)}
+			}
+			layers[i].Height = elevation * scale
+		}
+		z = layers[i].Height + layers[i].Thick
+	}
+	return layers, nil
+}
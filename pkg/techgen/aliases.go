@@ -0,0 +1,87 @@
+// Layer name aliases between the inputs and the stack
+//
+// The lyp, LEF, stackup and PDK dump are matched to the stack by layer
+// name. PDKs whose files use other names are mapped with aliases in the
+// stack config, input name to stack name:
+/*
+
+"aliases": { "met1": "Metal1", "via": "Via1", "li1": "LocalInterconnect" }
+*/
+// Names without an alias go through the regex rename rules, the first rule
+// that matches renames, with $1 for the first group as in Go's regexp:
+/*
+
+"rename": [
+  { "match": "^M(\\d+)$", "to": "Metal$1" },
+  { "match": "^(\\w+)_layer$", "to": "$1" }
+]
+*/
+// The inputs are renamed right after they are read, lyp entries keep
+// their purpose (met1.drawing becomes Metal1.drawing). The config is read
+// before the inputs, so the LEF parser already knows met1 is a stack layer.
+
+package techgen
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type renameRule struct {
+	Match string `json:"match"`
+	To    string `json:"to"`
+	re    *regexp.Regexp
+}
+
+func (r *renameRule) compile() error {
+	re, err := regexp.Compile(r.Match)
+	if err != nil {
+		return fmt.Errorf("rename %q: %w", r.Match, err)
+	}
+	r.re = re
+	return nil
+}
+
+// The stack name of a layer of the inputs, after the aliases and rename
+// rules of the stack config
+func (r *run) stackLayerName(name string) string {
+	if to, ok := r.aliases[name]; ok {
+		return to
+	}
+	for _, rule := range r.rename {
+		if rule.re.MatchString(name) {
+			return rule.re.ReplaceAllString(name, rule.To)
+		}
+	}
+	return name
+}
+
+func (in *stackInputs) applyAliases(r *run) {
+	if len(r.aliases) == 0 && len(r.rename) == 0 {
+		return
+	}
+	rename := r.stackLayerName
+	for i, k := range in.lyp {
+		if name, purpose, ok := splitLayerName(k.Name); ok {
+			in.lyp[i].Name = rename(name) + "." + purpose
+		}
+	}
+	if in.lef != nil {
+		for i, l := range in.lef.Layers {
+			in.lef.Layers[i].Name = rename(l.Name)
+		}
+		for _, via := range in.lef.Vias {
+			for i, name := range via.Layers {
+				via.Layers[i] = rename(name)
+			}
+		}
+	}
+	for i, l := range in.hfss {
+		in.hfss[i].Name = rename(l.Name)
+	}
+	if in.pdk != nil {
+		for i, l := range in.pdk.Layers {
+			in.pdk.Layers[i].Name = rename(l.Name)
+		}
+	}
+}
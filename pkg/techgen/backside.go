@@ -0,0 +1,144 @@
+// Backside metal and through silicon vias
+//
+// 3D-IC and RF flows add layers under the substrate, e.g. a backside
+// metal for the ground plane, and TSVs through it. The stack config lists
+// them:
+/*
+
+"backside": [
+  { "name": "BacksideMetal", "gds": "210/0", "thickness": 2.0 },
+  { "name": "TSV", "gds": "211/0", "connects": ["BacksideMetal", "Metal1"] }
+]
+*/
+// Layers with a thickness are stacked downwards from the bottom of the
+// substrate, each gap um below the one before, unless a height in um puts
+// them elsewhere, e.g. a buried layer at -3.0. They are metal unless
+// "metal" is false. Layers with two connects are vias: like the LEF vias
+// they span from the top of the lower to the bottom of the upper layer,
+// through the substrate in between, after variants and via interpolation
+// have moved the metals.
+
+package techgen
+
+import "fmt"
+
+const defaultBacksideColor = "#b08040"
+
+type backsideConfig struct {
+	Name      string   `json:"name"`
+	GDS       string   `json:"gds"`
+	Height    *float64 `json:"height"`
+	Gap       float64  `json:"gap"`
+	Thickness float64  `json:"thickness"`
+	Connects  []string `json:"connects"`
+	Metal     *bool    `json:"metal"`
+	Color     string   `json:"color"`
+	Filter    *float64 `json:"filter"`
+}
+
+func (b backsideConfig) validate() error {
+	switch {
+	case b.Name == "":
+		return fmt.Errorf("has no name")
+	case b.GDS == "":
+		return fmt.Errorf("%s has no gds layer", b.Name)
+	case b.Connects != nil && len(b.Connects) != 2:
+		return fmt.Errorf("%s needs two layers to connect", b.Name)
+	case b.Connects != nil && (b.Thickness != 0.0 || b.Height != nil):
+		return fmt.Errorf("%s connects layers, its z follows them", b.Name)
+	case b.Connects == nil && b.Thickness <= 0.0:
+		return fmt.Errorf("%s has thickness %g, expected a positive thickness in um", b.Name, b.Thickness)
+	case b.Gap < 0.0:
+		return fmt.Errorf("%s has gap %g, expected a gap in um", b.Name, b.Gap)
+	case b.Color != "" && len(b.Color) != 7:
+		return fmt.Errorf("%s has color %q, expected #rrggbb", b.Name, b.Color)
+	case b.Filter != nil && (*b.Filter < 0.0 || *b.Filter > 1.0):
+		return fmt.Errorf("%s has filter %g, expected 0.0 to 1.0", b.Name, *b.Filter)
+	}
+	return nil
+}
+
+// Backside layers go on top of the stack, existing entries (e.g. read
+// back from an IR) are kept as they are
+func addBacksideLayers(LayerStack []Layer, backside []backsideConfig, lyp []KLayer) ([]Layer, []Warning) {
+	var warnings []Warning
+	added := false
+	for _, b := range backside {
+		if layerIndex(LayerStack, b.Name) >= 0 {
+			continue
+		}
+		layer := Layer{
+			Name:        b.Name,
+			altName:     b.Name,
+			Color:       defaultBacksideColor,
+			Metal:       1,
+			Show:        true,
+			Group:       "metal",
+			LEFConnects: b.Connects,
+			ColorSource: "default",
+			ZSource:     "stack config",
+		}
+		if b.Connects != nil {
+			layer.Metal = 0
+			layer.Group = "via"
+		}
+		if err := setLayerGDS(&layer, b.GDS, lyp); err != nil {
+			warnings = append(warnings, warn("no-lyp-layer", "backside %s: %v", b.Name, err))
+		}
+		if b.Metal != nil {
+			layer.Metal = btoi(*b.Metal)
+		}
+		if b.Color != "" {
+			layer.Color = b.Color
+		}
+		if b.Filter != nil {
+			layer.Filter = *b.Filter
+		}
+		LayerStack = append(LayerStack, layer)
+		added = true
+	}
+	if added {
+		assignShortkeys(LayerStack)
+	}
+	return LayerStack, warnings
+}
+
+// After via interpolation, so the vias through the substrate connect the
+// final metals
+func update_layerstack_backside(LayerStack []Layer, backside []backsideConfig) []Warning {
+	var warnings []Warning
+	if len(backside) == 0 {
+		return nil
+	}
+	s := layerIndex(LayerStack, "Substrate")
+	bottom, below := 0.0, "surface"
+	if s >= 0 {
+		bottom, below = LayerStack[s].Height, "Substrate"
+	}
+	for _, b := range backside {
+		i := layerIndex(LayerStack, b.Name)
+		if i < 0 || b.Connects != nil {
+			continue
+		}
+		if b.Height != nil {
+			LayerStack[i].Height = *b.Height
+			LayerStack[i].Thickness = b.Thickness
+			LayerStack[i].ZSource = "stack config"
+			continue
+		}
+		LayerStack[i].Height = bottom - b.Gap - b.Thickness
+		LayerStack[i].Thickness = b.Thickness
+		LayerStack[i].ZSource = fmt.Sprintf("under %s - %g um", below, b.Gap)
+		bottom, below = LayerStack[i].Height, b.Name
+	}
+	for _, b := range backside {
+		i := layerIndex(LayerStack, b.Name)
+		if i < 0 || b.Connects == nil {
+			continue
+		}
+		if !spanConnects(LayerStack, i, "through") {
+			warnings = append(warnings, warn("no-connect", "backside %s: no layers %s and %s with z data to connect", b.Name, b.Connects[0], b.Connects[1]))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,162 @@
+// Generation of many techfiles at once
+//
+//	build_3d_techfile batch [-j 8] pdks.json
+//
+// The manifest lists one job per techfile, each the flags of a normal run
+// and the directory to run it in:
+//
+//	{"jobs": [
+//		{"name": "sg13g2", "dir": "ihp", "args": ["-lyp", "sg13g2.lyp", "-lef", "sg13g2_tech.lef", "-o", "sg13g2.txt"]},
+//		{"name": "gf180", "dir": "gf180", "args": ["-config", "gf180.json", "-o", "gf180.txt"]}
+//	]}
+//
+// The jobs run in this process, -j at a time, by default one per CPU, each
+// with its own settings, paths taken from its dir. The output and warnings
+// of a job are collected and printed as one block when it is done, they
+// never interleave with the other jobs. The parse cache is shared, so jobs
+// reading the same PDK files parse them once.
+
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type batchJob struct {
+	Name string   `json:"name"`
+	Dir  string   `json:"dir"`  // relative to the manifest, "" for its directory
+	Args []string `json:"args"` // flags of the run
+}
+
+type batchManifest struct {
+	Jobs []batchJob `json:"jobs"`
+}
+
+type batchResult struct {
+	Output   []byte
+	Warnings []Warning
+	Err      error
+	Duration time.Duration
+}
+
+func runBatch(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	workers := flags.Int("j", runtime.GOMAXPROCS(0), "Jobs run at once")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: build_3d_techfile batch [-j n] manifest.json")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || *workers < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	manifest, err := readBatchManifest(flags.Arg(0))
+	if err != nil {
+		fmt.Println("Error reading batch manifest:", err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	results := runBatchJobs(ctx, manifest.Jobs, *workers)
+	failed := 0
+	for i, job := range manifest.Jobs {
+		if results[i].Err != nil {
+			fmt.Printf("FAIL %s: %v\n", job.Name, results[i].Err)
+			failed++
+		}
+	}
+	fmt.Printf("%d of %d jobs done in %.1fs\n", len(manifest.Jobs)-failed, len(manifest.Jobs), time.Since(start).Seconds())
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func readBatchManifest(path string) (batchManifest, error) {
+	var manifest batchManifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("%s: %w", path, err)
+	}
+	names := map[string]bool{}
+	for i, job := range manifest.Jobs {
+		if job.Name == "" {
+			return manifest, fmt.Errorf("%s: job %d has no name", path, i+1)
+		}
+		if names[job.Name] {
+			return manifest, fmt.Errorf("%s: job %s listed twice", path, job.Name)
+		}
+		names[job.Name] = true
+		manifest.Jobs[i].Dir = filepath.Join(filepath.Dir(path), job.Dir)
+	}
+	return manifest, nil
+}
+
+// Runs the jobs on a pool of workers and prints the output of each as it
+// finishes. Results are in the order of jobs.
+func runBatchJobs(ctx context.Context, jobs []batchJob, workers int) []batchResult {
+	results := make([]batchResult, len(jobs))
+	next := make(chan int)
+	var printMu sync.Mutex
+	var wg sync.WaitGroup
+	for range min(workers, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = runBatchJob(ctx, jobs[i])
+				printMu.Lock()
+				printBatchResult(jobs[i], results[i])
+				printMu.Unlock()
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+func runBatchJob(ctx context.Context, job batchJob) batchResult {
+	start := time.Now()
+	var out bytes.Buffer
+	flags := flag.NewFlagSet(job.Name, flag.ContinueOnError)
+	flags.SetOutput(&out)
+	warnings, err := runCommand(ctx, flags, job.Args, job.Dir, &out)
+	return batchResult{Output: out.Bytes(), Warnings: warnings, Err: err, Duration: time.Since(start)}
+}
+
+func printBatchResult(job batchJob, res batchResult) {
+	status := "ok"
+	if res.Err != nil {
+		status = "failed: " + res.Err.Error()
+	}
+	warnings := 0
+	for _, w := range res.Warnings {
+		if w.Severity >= SeverityWarning {
+			warnings++
+		}
+	}
+	if warnings > 0 {
+		status += fmt.Sprintf(", %d warnings", warnings)
+	}
+	fmt.Printf("== %s (%s, %.1fs)\n", job.Name, status, res.Duration.Seconds())
+	os.Stdout.Write(res.Output)
+	if len(res.Output) > 0 && res.Output[len(res.Output)-1] != '\n' {
+		fmt.Println()
+	}
+}
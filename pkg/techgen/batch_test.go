@@ -0,0 +1,76 @@
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Jobs run side by side in their own directories with their own flags, a
+// failing job fails alone
+func TestRunBatchJobs(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	dir := t.TempDir()
+	for _, job := range []string{"nm", "um", "broken"} {
+		if err := os.Mkdir(filepath.Join(dir, job), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"sg13g2.lyp", "sg13g2_tech.lef"} {
+			data, err := os.ReadFile("../../" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job == "broken" && name == "sg13g2_tech.lef" {
+				continue
+			}
+			if err := os.WriteFile(filepath.Join(dir, job, name), data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	jobs := []batchJob{
+		{Name: "nm", Dir: filepath.Join(dir, "nm"), Args: []string{"-parse-cache=false", "-o", "out.txt"}},
+		{Name: "um", Dir: filepath.Join(dir, "um"), Args: []string{"-parse-cache=false", "-unit", "um", "-o", "out.txt"}},
+		{Name: "broken", Dir: filepath.Join(dir, "broken"), Args: []string{"-parse-cache=false", "-o", "out.txt"}},
+	}
+
+	results := runBatchJobs(context.Background(), jobs, 2)
+	for i, res := range results {
+		if failed := res.Err != nil; failed != (jobs[i].Name == "broken") {
+			t.Errorf("job %s: got error %v\n%s", jobs[i].Name, res.Err, res.Output)
+		}
+	}
+	nm, err := os.ReadFile(filepath.Join(dir, "nm", "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	um, err := os.ReadFile(filepath.Join(dir, "um", "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile("../../sg13g2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(withoutDate(nm), withoutDate(golden)) {
+		t.Errorf("techfile of the nm job differs from sg13g2.txt:\n%s", nm)
+	}
+	if bytes.Equal(nm, um) {
+		t.Error("the um job wrote the techfile of the nm job")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "broken", "out.txt")); err == nil {
+		t.Error("the broken job wrote a techfile")
+	}
+}
+
+func withoutDate(techFile []byte) []byte {
+	var kept [][]byte
+	for _, line := range bytes.Split(techFile, []byte("\n")) {
+		if !bytes.Contains(line, []byte("Date")) {
+			kept = append(kept, line)
+		}
+	}
+	return bytes.Join(kept, []byte("\n"))
+}
@@ -0,0 +1,171 @@
+// BEOL options of a process, selected with -beol
+//
+// A process often comes with several metal stacks on the same front end,
+// e.g. 5 thin metals with 2 thick top metals or 7 thin metals. The stack
+// config defines them once, each as edits of the resolved stack:
+/*
+
+"beol": {
+  "5M2TM": {},
+  "7M": {
+    "edits": ["-TopVia1", "-TopMetal1", "-TopVia2", "-TopMetal2", "-MIM"],
+    "layers": [
+      { "name": "Metal6", "gds": "201/0", "height": 5.88, "thickness": 0.45 },
+      { "name": "Via5", "gds": "202/0", "connects": ["Metal5", "Metal6"] },
+      { "name": "Metal7", "gds": "203/0", "height": 6.88, "thickness": 0.45 },
+      { "name": "Via6", "gds": "204/0", "connects": ["Metal6", "Metal7"] }
+    ]
+  }
+}
+*/
+// Edits are variant ops, see variant.go, applied before the -variant ops
+// of a run. Layers are added as metals with height and thickness in um,
+// or as vias spanning the two layers they connect. The gds is a
+// gds/datatype pair or a lyp name. The name of the BEOL goes into the
+// process in the techfile header.
+
+package techgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const defaultBEOLColor = "#8080c0"
+
+type beolConfig struct {
+	Edits  []string          `json:"edits"`
+	Layers []beolLayerConfig `json:"layers"`
+}
+
+type beolLayerConfig struct {
+	Name      string   `json:"name"`
+	GDS       string   `json:"gds"`
+	Height    *float64 `json:"height"`
+	Thickness float64  `json:"thickness"`
+	Connects  []string `json:"connects"`
+	Color     string   `json:"color"`
+	Filter    *float64 `json:"filter"`
+}
+
+// BEOL selected with -beol, its edits parsed as a variant
+type beolOption struct {
+	Name   string
+	Edits  variant
+	Layers []beolLayerConfig
+}
+
+func (b beolConfig) validate(name string) error {
+	if len(b.Edits) > 0 {
+		if _, err := parseVariant(name + ":" + strings.Join(b.Edits, ",")); err != nil {
+			return err
+		}
+	}
+	for _, l := range b.Layers {
+		switch {
+		case l.Name == "":
+			return fmt.Errorf("layer has no name")
+		case l.GDS == "":
+			return fmt.Errorf("%s has no gds layer", l.Name)
+		case l.Connects != nil && len(l.Connects) != 2:
+			return fmt.Errorf("%s needs two layers to connect", l.Name)
+		case l.Connects != nil && (l.Height != nil || l.Thickness != 0.0):
+			return fmt.Errorf("%s connects layers, its z follows them", l.Name)
+		case l.Connects == nil && (l.Height == nil || l.Thickness <= 0.0):
+			return fmt.Errorf("%s needs height and a positive thickness in um", l.Name)
+		case l.Color != "" && len(l.Color) != 7:
+			return fmt.Errorf("%s has color %q, expected #rrggbb", l.Name, l.Color)
+		case l.Filter != nil && (*l.Filter < 0.0 || *l.Filter > 1.0):
+			return fmt.Errorf("%s has filter %g, expected 0.0 to 1.0", l.Name, *l.Filter)
+		}
+	}
+	return nil
+}
+
+func selectBEOL(config *stackConfig, name string) (*beolOption, error) {
+	if config == nil || config.BEOL == nil {
+		return nil, fmt.Errorf("-beol %s needs a stack config with beol options", name)
+	}
+	b, ok := config.BEOL[name]
+	if !ok {
+		var names []string
+		for n := range config.BEOL {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("no beol %s in the stack config (has %s)", name, strings.Join(names, ", "))
+	}
+	option := &beolOption{Name: name, Edits: variant{Name: "beol " + name}, Layers: b.Layers}
+	if len(b.Edits) > 0 {
+		// Checked when the config was read
+		option.Edits, _ = parseVariant("beol " + name + ":" + strings.Join(b.Edits, ","))
+	}
+	return option, nil
+}
+
+// BEOL layers go on top of the stack, existing entries (e.g. read back
+// from an IR) are kept as they are
+func addBEOLLayers(LayerStack []Layer, b *beolOption, lyp []KLayer) ([]Layer, []Warning) {
+	var warnings []Warning
+	if b == nil {
+		return LayerStack, nil
+	}
+	added := false
+	for _, bl := range b.Layers {
+		if layerIndex(LayerStack, bl.Name) >= 0 {
+			continue
+		}
+		layer := Layer{
+			Name:        bl.Name,
+			altName:     bl.Name,
+			Color:       defaultBEOLColor,
+			Metal:       1,
+			Show:        true,
+			Group:       "metal",
+			ColorSource: "default",
+			ZSource:     "beol " + b.Name,
+		}
+		if bl.Connects != nil {
+			layer.Metal = 0
+			layer.Group = "via"
+			layer.LEFConnects = bl.Connects
+		} else {
+			layer.Height = *bl.Height
+			layer.Thickness = bl.Thickness
+		}
+		if err := setLayerGDS(&layer, bl.GDS, lyp); err != nil {
+			warnings = append(warnings, warn("no-lyp-layer", "beol %s: %v for %s", b.Name, err, bl.Name))
+		}
+		if bl.Color != "" {
+			layer.Color = bl.Color
+		}
+		if bl.Filter != nil {
+			layer.Filter = *bl.Filter
+		}
+		LayerStack = append(LayerStack, layer)
+		added = true
+	}
+	if added {
+		assignShortkeys(LayerStack)
+	}
+	return LayerStack, warnings
+}
+
+// After via interpolation, for vias whose name does not say they are one
+func update_layerstack_beol(LayerStack []Layer, b *beolOption) []Warning {
+	var warnings []Warning
+	if b == nil {
+		return nil
+	}
+	for _, bl := range b.Layers {
+		i := layerIndex(LayerStack, bl.Name)
+		if i < 0 || bl.Connects == nil || LayerStack[i].Thickness > 0.0 {
+			continue
+		}
+		if !spanConnects(LayerStack, i, "interpolated") {
+			warnings = append(warnings, warn("no-connect", "beol %s: no layers %s and %s with z data to connect", b.Name, bl.Connects[0], bl.Connects[1]))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,834 @@
+// Generate a techfile for GDS3D from a PDK files 
+
+// Author: Jørgen Kragh Jakobsen
+// Date  : 10 Aug 2024
+
+// Pass klayout lyp file go get a list of layername with gds layer number, datatype and color
+// Pass lef file to get a layer name with height (z-level) and layer thickness 
+// Some layer do not have a height and thickness specified but must be canculated from the stackup
+
+// The techfile is a text file with the following format
+/* 
+
+LayerStart: Substrate
+Layer: 255
+Datatype: 0
+Height: -10000.0
+Thickness: 10000.0
+Red: 0.15
+Green: 0.15
+Blue: 0.15
+Filter: 0.0
+Metal: 0
+Show: 1
+LayerEnd
+*/ 
+
+// Lef file from pdk in   IHP-Open-PDK/ihp-sg13g2/libs.ref/sg13g2_stdcell/lef/sg13g2_tech.lef
+// Klayout config in      IHP-Open-PDK/ihp-sg13g2/libs.tech/klayout/tech/sg13g2.lyp
+
+package techgen 
+
+// 
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os/signal"
+	"fmt"
+	"os"
+	"strings" 
+	"io"
+	"cmp"
+	"slices"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/format"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/hook"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/lef"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/lyp"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+// Layer of the lyp file with its name, number, and color
+type KLayer = lyp.Layer
+
+func parseLypFile(ctx context.Context, r *run, filePath string) ([]KLayer, []Warning, error) {
+	return decodeCached(ctx, r, "lyp", "", filePath, decodeLypFile)
+}
+
+func decodeLypFile(file io.Reader, filePath string) ([]KLayer, []Warning, error) {
+	props, warnings, err := lyp.Parse(file)
+	if err != nil {
+		return nil, nil, parseerr.InFile(filePath, err)
+	}
+
+	// Keep layers named layer.purpose, the stack uses drawing and any extra
+	// datatypes from the stack config
+	var layers []KLayer
+	for _, prop := range props {
+			if _, _, ok := splitLayerName(prop.Name); ok {
+					if _, _, err := prop.GDS(); err != nil {
+						return nil, nil, parseerr.InFile(filePath, err)
+					}
+					layers = append(layers, prop)
+			}
+	}
+
+	return layers, parseerr.WarningsInFile(filePath, warnings), nil
+}
+
+func splitLayerName(name string) (string, string, bool) {
+	parts := strings.Split(name, ".")
+	if len(parts) != 2 {
+			return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Name of a stack layer in the lyp. Extra datatype entries are named
+// layer.purpose already, the main entries are the drawing purpose.
+func lypName(layer Layer) string {
+	if strings.Contains(layer.Name, ".") {
+		return layer.Name
+	}
+	return layer.Name + ".drawing"
+}
+	
+type (
+	LefLayer = lef.Layer
+	LefVia   = lef.Via
+	LEFFile  = lef.File
+)
+
+func contains(s []string, str string) bool {
+    for _, v := range s {
+        if v == str {
+            return true
+        }
+    }
+    return false
+} 
+
+func parseLEF(ctx context.Context, r *run, filePath string) (*LEFFile, []Warning, error) {
+	return decodeCached(ctx, r, "lef", r.lefCacheParams(), filePath, r.decodeLEF)
+}
+
+// Keeps the layers of the default stack, by their names after the aliases
+// and rename rules of r
+func (r *run) decodeLEF(file io.Reader, filePath string) (*LEFFile, []Warning, error) {
+
+	deflayers := []string{"GatPoly", "Cont", "Metal1", "Via1", "Metal2", "Via2", "Metal3", "Via3", "Metal4", "Via4", "Metal5", "TopVia1", "TopMetal1", "TopVia2", "TopMetal2"}
+
+	lefFile, warnings, err := lef.Parse(file, func(name string) bool {
+		return contains(deflayers, r.stackLayerName(name))
+	}, lef.WithMaxLineLength(r.LEFMaxLine))
+	if err != nil {
+		return nil, nil, parseerr.InFile(filePath, err)
+	}
+    return lefFile, parseerr.WarningsInFile(filePath, warnings), nil
+}
+ 
+type Layer struct { 
+	Name string
+	altName string
+	GDSNumber int
+	GDSDatatype int
+	Color string
+	Height float64
+	Thickness float64
+	Metal int
+	Filter float64 // GDS3D transparency, 0.0 opaque to 1.0 invisible
+	Show bool      // shown when GDS3D opens the techfile, else toggled on by hand
+	Shortkey string // GDS3D key toggling the layer, "" for none
+	Group string    // techfile section, see layerGroups
+	ZFrom string    // extra datatype entry sharing the z of this layer, else ""
+	SharedLook bool // merged source, also drawn with the look of ZFrom
+	Dielectric bool // slab from the stack config, not drawn in the layout
+	Mask       int  // multi-patterning mask of ZFrom, from 1, 0 for none
+	Derived    string // boolean expression of gds/datatype pairs, "" if drawn
+	Tolerance  float64 // thickness tolerance in um, +-, for -corner
+	LEFConnects []string // layers a cut layer connects according to the LEF via definitions
+	ColorSource string // where GDS number, datatype and color came from
+	ZSource string     // where height and thickness came from
+}
+
+// Layers drawn see-through by default so the devices below stay visible
+var defaultFilter = map[string]float64{
+	"NWell": 0.5,
+	"PWell": 0.5,
+	"MIM":   0.3,
+}
+
+func newLayer(name string, altName string, gdsNumber int, gdsDatatype int, color string, height float64, thickness float64, metal int) Layer {
+	layer := Layer{
+		Name:        name,
+		altName:     altName,
+		GDSNumber:   gdsNumber,
+		GDSDatatype: gdsDatatype,
+		Color:       color,
+		Height:      height,
+		Thickness:   thickness,
+		Metal:       metal,
+		Filter:      defaultFilter[name],
+		Show:        true,
+		ColorSource: "default",
+		ZSource:     "default",
+	}
+	layer.Group = defaultGroup(layer)
+	return layer
+}
+
+// The layers the lyp, LEF and stackup fill in, bottom up
+func defaultLayerStack() []Layer {
+	return []Layer{ 	newLayer( "Substrate", 	"Substrate", 255, 0, "#FFFFFF", -10.0, 10.0, 0),
+							newLayer( "NWell", 		"NWell",     0, 0, "#000000", 0.0, 0.2,    0),
+							newLayer( "PWell", 		"PWell",     0, 0, "#000000", 0.0, 0.2,    0),
+							newLayer( "Active", 	"Active",    0, 0, "#000000", 0.2, 0.12,   0),
+							newLayer( "ResPoly", 	"ResPoly",   0, 0, "#000000", 0.32, 0.1,   0),
+							newLayer( "GatPoly", 	"GatPoly",   0, 0, "#FF0000", 0.32, 0.1,   0),
+							newLayer( "Cont", 		"Cont",      0, 0, "#00FF00", 0.32, 0.64,  0),
+							newLayer( "Metal1", 	"Metal1",    0, 0, "#0000FF", 0.0, 0.0,    1),
+							newLayer( "Via1", 		"Via1",      0, 0, "#FFFF00", 0.0, 0.0,    0),
+							newLayer( "Metal2", 	"Metal2",    0, 0, "#00FFFF", 0.0, 0.0,    1),
+							newLayer( "Via2", 		"Via2",      0, 0, "#FF00FF", 0.0, 0.0,    0),
+							newLayer( "Metal3", 	"Metal3",    0, 0, "#FF0000", 0.0, 0.0,    1),
+							newLayer( "Via3", 		"Via3",      0, 0, "#00FF00", 0.0, 0.0,    0),
+							newLayer( "Metal4", 	"Metal4",    0, 0, "#0000FF", 0.0, 0.0,    1),
+							newLayer( "Via4", 		"Via4",      0, 0, "#FFFF00", 0.0, 0.0,    0),
+							newLayer( "Metal5", 	"Metal5",   0, 0, "#00FFFF", 0.0, 0.0,    1),
+							newLayer( "TopVia1", 	"TopVia1",  0, 0, "#FF00FF", 0.0, 0.0,    0),
+							newLayer( "TopMetal1",  "TopMetal1",0, 0, "#FF0000", 0.0, 2.0,    1),
+							newLayer( "TopVia2", 	"TopVia2",  0, 0, "#00FF00", 0.0, 0.0,    0),
+							newLayer( "TopMetal2",  "TopMetal2",0, 0, "#0000FF", 0.0, 3.0,    1),
+							newLayer( "MIM", 		"MIM",	    0, 0, "#00FFFF", 5.3, 0.150,  0),
+    }						
+}
+
+// Main is the build_3d_techfile command, flags and subcommands from
+// os.Args. It exits with 1 when an output could not be written.
+func Main() {
+	// Ctrl-C stops reading and writing, outputs are left as they were
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(ctx, os.Args[2:])
+			return
+		case "check":
+			runCheck(ctx, os.Args[2:])
+			return
+		case "merge":
+			runMerge(ctx, os.Args[2:])
+			return
+		case "fmt":
+			runFmt(ctx, os.Args[2:])
+			return
+		case "batch":
+			runBatch(ctx, os.Args[2:])
+			return
+		}
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	if _, err := runCommand(ctx, flags, os.Args[1:], "", os.Stdout); err != nil {
+		fmt.Println("Error:", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// A run of the command with args, its flags without the program name.
+// Relative paths are taken from dir, "" for the working directory. Progress
+// and warnings are printed to out as they come, the warnings are returned
+// as well. A variant or output that fails is reported and the others are
+// written, the run fails at the end.
+func runCommand(ctx context.Context, flags *flag.FlagSet, args []string, dir string, out io.Writer) ([]Warning, error) {
+	opts := DefaultOptions()
+	lypPath := flags.String("lyp", "sg13g2.lyp", "KLayout layer properties file or http(s) URL (gds numbers and colors)")
+	lefPath := flags.String("lef", "sg13g2_tech.lef", "Technology LEF file or http(s) URL (layer height and thickness)")
+	flags.IntVar(&opts.LEFMaxLine, "lef-max-line", opts.LEFMaxLine, "Longest LEF line in bytes, e.g. a SPACINGTABLE, read before failing (0 = no limit)")
+	pdkPath := flags.String("pdkjson", "", "Optional JSON layer/stack dump from a python PDK framework (PDKMaster, hdl21)")
+	hfssPath := flags.String("hfss", "", "Optional HFSS/SIwave layer stackup XML (elevation and thickness)")
+	flags.StringVar(&opts.Archive, "archive", opts.Archive, "Optional PDK release archive (zip, tar, tar.gz) to read the inputs from")
+	flags.BoolVar(&opts.NoTimestamp, "no-timestamp", opts.NoTimestamp, "Leave the date out of the techfile header for byte identical outputs (see also SOURCE_DATE_EPOCH)")
+	flags.BoolVar(&opts.Refresh, "refresh", opts.Refresh, "Download http(s) inputs again and parse the inputs again instead of using the caches")
+	flags.BoolVar(&opts.ParseCache, "parse-cache", true, "Cache parsed lyp, LEF and stackup XML inputs in the user cache directory, keyed by content and build of the tool")
+	beolName := flags.String("beol", "", "BEOL option of the stack config to generate, e.g. 7M (see beol in the stack config)")
+	configPath := flags.String("config", "", "Optional JSON stack config with per-layer overrides (filter, show, shortkey, group, metal)")
+	catchAllPath := flags.String("catch-all", "", "Optional GDS, add hidden placeholders for the layers it uses that are not in the stack")
+	profileName := flags.String("profile", "", "Stack profile to use as -config, read as name.json from -profile-dir")
+	flags.StringVar(&opts.ProfileDir, "profile-dir", opts.ProfileDir, "Directory of the stack profiles")
+	colorsPath := flags.String("colors", "", "Optional text file with per-layer color and filter overrides (layer #rrggbb [filter])")
+	irPath := flags.String("from-ir", "", "Regenerate from a resolved stack IR (TOML or .json, see -export ir and ir-json) instead of the lyp/LEF/stackup inputs")
+	templateName := flags.String("template", "", "Build a generic stack instead of reading lyp/LEF/stackup inputs: "+templateNames())
+	techFilePath := flags.String("from-techfile", "", "Use the layers of an existing GDS3D techfile instead of the lyp/LEF/stackup inputs")
+	fromSpec := flags.String("from", "", "Read the stack as format:file with a registered input format instead of the lyp/LEF/stackup inputs ("+strings.Join(format.ParserNames(), ", ")+")")
+	flags.StringVar(&opts.Heights, "heights", opts.Heights, "Metals with LEF THICKNESS but no HEIGHT: lef (keep height 0) or accumulate (stack them up with -ild)")
+	flags.Float64Var(&opts.ILD, "ild", opts.ILD, "Dielectric thickness in um below accumulated layers the PDK defaults and stack config have no gap for")
+	flags.StringVar(&opts.Unit, "unit", opts.Unit, "Techfile length unit for Height and Thickness: nm (GDS3D) or um")
+	flags.IntVar(&opts.Precision, "precision", opts.Precision, "Decimals of Height and Thickness (default 0 for nm, 3 for um)")
+	flags.StringVar(&opts.ZeroThickness, "zero-thickness", opts.ZeroThickness, "Layers without thickness: warn (write them anyway), drop, or min (use -min-thickness)")
+	flags.Float64Var(&opts.MinThickness, "min-thickness", opts.MinThickness, "Thickness in um given to layers without one with -zero-thickness min")
+	flags.Float64Var(&opts.Sliver, "sliver", opts.Sliver, "Clamp layers thinner than this many um, but not empty, up to it with a warning (0 = off)")
+	flags.StringVar(&opts.Include, "include", opts.Include, "Comma separated layer name globs or /regex/, write only matching layers")
+	flags.StringVar(&opts.Exclude, "exclude", opts.Exclude, "Comma separated layer name globs or /regex/, leave matching layers out")
+	flags.StringVar(&opts.Header, "header", opts.Header, "Optional text/template file replacing the techfile header")
+	flags.StringVar(&opts.Footer, "footer", opts.Footer, "Optional text/template file written after the layers")
+	flags.BoolVar(&opts.ViaMetal, "via-metal", opts.ViaMetal, "Write contacts and vias with Metal: 1, for GDS3D's metal only view")
+	flags.StringVar(&opts.Sort, "sort", opts.Sort, "Layer order within a techfile section: stack (as listed in the stack), z or gds; byte identical reruns also need -no-timestamp or SOURCE_DATE_EPOCH")
+	flags.BoolVar(&opts.Split, "split", opts.Split, "Also write a techfile per section (_feol, _beol, _passive), indexed in the header of the -o techfile")
+	flags.Float64Var(&opts.ZScale, "z-scale", opts.ZScale, "Scale the written heights and thicknesses, e.g. 5 to exaggerate the stack for a presentation")
+	flags.Float64Var(&opts.ZOffset, "z-offset", opts.ZOffset, "Shift the written heights by um after -z-scale, e.g. to put the die surface at another z")
+	flags.StringVar(&opts.Numbers, "numbers", opts.Numbers, "Number style of Height, Thickness and colors: fixed decimals or minimal (no trailing zeros)")
+	flags.StringVar(&opts.Format, "format", opts.Format, "Techfile key spelling: legacy (Greeen, older GDS3D builds) or strict (Green, current GDS3D)")
+	outPath := flags.String("o", "sg13g2.txt", "Output GDS3D techfile")
+	var variants variantList
+	flags.Var(&variants, "variant", "Extra stack variant written with a _name suffix, as name:op,op,... with ops -Layer, Layer.thickness=um, Layer.height=um (repeatable)")
+	flags.StringVar(&opts.Corner, "corner", opts.Corner, "Thickness corner from the stack config tolerances: typ, min, max or all (writes _min, _typ and _max)")
+	bundlePath := flags.String("bundle", "", "Also write a zip with the techfiles, their stack IR, the inputs with hashes and a manifest")
+	flags.StringVar(&opts.Hooks, "hook", opts.Hooks, "Comma separated registered hooks changing the resolved stack before it is written ("+strings.Join(hook.Names(), ", ")+")")
+	exportList := flags.String("export", "", "Comma separated extra outputs written next to the techfile ("+exporterNames()+")")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.Dir = dir
+	r, err := newRun(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.log = out
+
+	if *profileName != "" {
+		if *configPath != "" {
+			return nil, errors.New("use either -config or -profile")
+		}
+		*configPath = profilePath(*profileName, r.ProfileDir)
+	}
+	g, err := r.generate(ctx, Inputs{
+		Lyp:          *lypPath,
+		LEF:          *lefPath,
+		HFSS:         *hfssPath,
+		PDKJSON:      *pdkPath,
+		Config:       *configPath,
+		Colors:       *colorsPath,
+		BEOL:         *beolName,
+		CatchAll:     *catchAllPath,
+		FromIR:       *irPath,
+		FromTechFile: *techFilePath,
+		From:         *fromSpec,
+		Template:     *templateName,
+		Variants:     variants,
+		Exports:      strings.Split(*exportList, ","),
+		Name:         *outPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every output reports the warnings of the inputs along with its own
+	printWarnings(out, g.parseWarnings)
+	allWarnings := slices.Clone(g.parseWarnings)
+	var outputs []bundleOutput
+	failed := 0
+	for _, o := range g.outputs {
+		printWarnings(out, o.warnings)
+		allWarnings = append(allWarnings, o.warnings...)
+		if o.err != nil {
+			fmt.Fprintln(out, "Error in the layer stack of", o.path+":", o.err)
+			failed++
+			continue
+		}
+		var sections []string
+		written := r.writtenStack(o.stack)
+		if r.Split {
+			var sectionsFailed int
+			sections, sectionsFailed = writeSplitTechFiles(ctx, r, o.path, written)
+			failed += sectionsFailed
+		}
+		if err := writeTechFile(ctx, r, o.path, written, sections); err != nil {
+			fmt.Fprintln(out, "Error writing techfile:", err)
+			failed++
+			continue
+		}
+		warnings := append(slices.Clip(g.parseWarnings), o.warnings...)
+		failed += writeExports(ctx, r, g.exports, o.path, o.stack, warnings)
+		outputs = append(outputs, bundleOutput{o.path, o.stack})
+	}
+
+	if *bundlePath != "" {
+		command := append([]string{os.Args[0]}, args...)
+		if err := writeBundle(ctx, r, *bundlePath, command, g.inputs, outputs); err != nil {
+			return allWarnings, fmt.Errorf("writing bundle: %w", err)
+		}
+		fmt.Fprintf(out, "Wrote bundle: %s\n", *bundlePath)
+	}
+	if failed > 0 {
+		return allWarnings, fmt.Errorf("%d outputs not written", failed)
+	}
+	return allWarnings, nil
+}
+
+// Parsed input files the layer stack is resolved from
+type stackInputs struct {
+	lyp  []KLayer
+	lef  *LEFFile
+	hfss []HFSSLayer
+	pdk  *PDKFile
+	config *stackConfig
+	colors []colorOverride
+	beol   *beolOption
+	used   map[gdsLayerKey]int // layers of the -catch-all GDS
+}
+
+func (in stackInputs) feol() *feolConfig {
+	if in.config == nil {
+		return nil
+	}
+	return in.config.FEOL
+}
+
+func (in stackInputs) mim() *mimConfig {
+	if in.config == nil {
+		return nil
+	}
+	return in.config.MIM
+}
+
+func (in stackInputs) beolHeight() *beolHeightConfig {
+	if in.config == nil {
+		return nil
+	}
+	return in.config.BEOLHeight
+}
+
+func (in stackInputs) ild() *ildConfig {
+	if in.config == nil {
+		return nil
+	}
+	return in.config.ILD
+}
+
+// Slabs of the stack config followed by the ILD gap slabs
+func (in stackInputs) dielectrics(LayerStack []Layer) []dielectricConfig {
+	if in.config == nil {
+		return nil
+	}
+	return append(append([]dielectricConfig(nil), in.config.Dielectrics...), ildSlabs(LayerStack, in.config.ILD)...)
+}
+
+// Parse the input files once, every variant is resolved from them
+func parseStackInputs(ctx context.Context, r *run, lypPath, lefPath, hfssPath, pdkPath string) (stackInputs, []Warning, error) {
+	// The inputs are independent, read them at once
+	var in stackInputs
+	var lypWarnings, lefWarnings, hfssWarnings []Warning
+	err := parallel(
+		func() (err error) {
+			if in.lyp, lypWarnings, err = parseLypFile(ctx, r, lypPath); err != nil {
+				return fmt.Errorf("parsing Lyp file: %w", err)
+			}
+			return nil
+		},
+		func() (err error) {
+			if in.lef, lefWarnings, err = parseLEF(ctx, r, lefPath); err != nil {
+				return fmt.Errorf("parsing LEF file: %w", err)
+			}
+			return nil
+		},
+		func() (err error) {
+			if hfssPath == "" {
+				return nil
+			}
+			if in.hfss, hfssWarnings, err = parseHFSSFile(ctx, r, hfssPath); err != nil {
+				return fmt.Errorf("parsing stackup XML file: %w", err)
+			}
+			return nil
+		},
+		func() (err error) {
+			if pdkPath == "" {
+				return nil
+			}
+			if in.pdk, err = parsePDKJSON(ctx, r, pdkPath); err != nil {
+				return fmt.Errorf("parsing PDK JSON file: %w", err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return in, nil, err
+	}
+	if in.pdk != nil {
+		r.progress("Found PDK dump: %s (%d layers)", in.pdk.Name, len(in.pdk.Layers))
+	}
+	return in, slices.Concat(lypWarnings, lefWarnings, hfssWarnings), nil
+}
+
+// The layer stack of variant v and the warnings of resolving it
+func resolveLayerStack(r *run, base []Layer, in stackInputs, v variant) (Stackup, []Warning) {
+	LayerStack := Stackup(slices.Clone(base))
+	var warnings, w []Warning
+	if in.config != nil {
+		LayerStack, w = addDatatypeLayers(LayerStack, in.config.Datatypes)
+		warnings = append(warnings, w...)
+		LayerStack, w = addMergedLayers(LayerStack, in.config.Merge)
+		warnings = append(warnings, w...)
+		LayerStack, w = addMaskLayers(LayerStack, in.config.Masks)
+		warnings = append(warnings, w...)
+		LayerStack, w = addDerivedLayers(LayerStack, in.config.Derived, in.lyp)
+		warnings = append(warnings, w...)
+	}
+	LayerStack, w = addBEOLLayers(LayerStack, in.beol, in.lyp)
+	warnings = append(warnings, w...)
+	dielectrics := in.dielectrics(LayerStack)
+	LayerStack, w = addDielectricLayers(LayerStack, dielectrics, in.lyp)
+	warnings = append(warnings, w...)
+	if in.config != nil {
+		LayerStack, w = addPackagingLayers(LayerStack, in.config.Packaging, in.lyp)
+		warnings = append(warnings, w...)
+		LayerStack, w = addBacksideLayers(LayerStack, in.config.Backside, in.lyp)
+		warnings = append(warnings, w...)
+	}
+
+	update_layerstack_feol(LayerStack, resolveFEOLModel(in.feol()))
+
+	layers := newLayerRegistry(LayerStack)
+	warnings = append(warnings, checkLypMappings(LayerStack, layers, in.lyp)...)
+	for _, layer := range in.lyp {
+		update_layerstack(LayerStack, layers, layer)	 
+	}
+    for _, layer := range in.lef.Layers {
+		update_layerstack_metal(LayerStack, layers, layer)
+		if layer.Thickness > 0.0 {
+			update_layerstack_height(LayerStack, layers, layer)
+		}
+	}
+	if r.Heights == "accumulate" {
+		update_layerstack_accumulate(r, LayerStack, in.lef.Layers, in.ild())
+	}
+	for _, layer := range in.hfss {
+		update_layerstack_hfss(LayerStack, layers, layer)
+	}
+	// A PDK dump is the designer's source of truth, so it goes on top of lyp and LEF data
+	if in.pdk != nil {
+		for _, layer := range in.pdk.Layers {
+			update_layerstack_pdk(LayerStack, layers, layer)
+		}
+	}
+
+	// The stack config holds the designer's overrides, it goes last
+	if in.config != nil {
+		warnings = append(warnings, update_layerstack_config(LayerStack, in.config)...)
+	}
+	warnings = append(warnings, update_layerstack_colors(LayerStack, in.colors)...)
+	update_layerstack_shared_look(LayerStack)
+
+	update_layerstack_connects(LayerStack, in.lef.Vias)
+	if in.beol != nil {
+		LayerStack, w = in.beol.Edits.apply(LayerStack)
+		warnings = append(warnings, w...)
+	}
+	LayerStack, w = v.apply(LayerStack)
+	warnings = append(warnings, w...)
+	update_layerstack_shared_z(LayerStack)
+	LayerStack.SortByZ()
+	warnings = append(warnings, checkNameOrder(LayerStack)...)
+    warnings = append(warnings, update_layerstack_vias( LayerStack )...)
+	if in.config != nil {
+		warnings = append(warnings, update_layerstack_backside(LayerStack, in.config.Backside)...)
+	}
+	warnings = append(warnings, update_layerstack_beol(LayerStack, in.beol)...)
+	warnings = append(warnings, update_layerstack_contacts(LayerStack)...)
+	warnings = append(warnings, update_layerstack_mim(LayerStack, in.mim())...)
+	warnings = append(warnings, update_layerstack_conformal(LayerStack, in.config)...)
+	update_layerstack_shared_z(LayerStack)
+	warnings = append(warnings, update_layerstack_dielectrics(LayerStack, dielectrics)...)
+	if in.config != nil {
+		warnings = append(warnings, update_layerstack_packaging(LayerStack, in.config.Packaging)...)
+	}
+	LayerStack, w = update_layerstack_zero_thickness(r, LayerStack)
+	warnings = append(warnings, w...)
+	LayerStack.SortByZ()
+	LayerStack = addCatchAllLayers(r, LayerStack, in.used, in.lyp)
+	// Checked whole, a layer left out by -include or -exclude is still
+	// there for the ones next to it
+	warnings = append(warnings, checkLayerStack(LayerStack, r.numberFormat())...)
+	warnings = append(warnings, checkBEOLHeight(LayerStack, in.beolHeight())...)
+	return filterLayerStack(r, LayerStack), warnings
+}
+
+// Vias without LEF thickness span the gap between the metals they connect:
+// the ones named in the LEF vias, else the nearest metals with z data in
+// the stack. Vias without two such metals, or with a LEF connected metal
+// missing from the stack, are left alone and reported.
+func update_layerstack_vias(LayerStack []Layer) []Warning {
+	var warnings []Warning
+	for i, l := range LayerStack {
+		if !strings.Contains(l.Name, "Via") || l.Thickness != 0.0 || l.ZFrom != "" {
+			continue
+		}
+		below, above, missing := viaMetals(LayerStack, i)
+		if missing != "" {
+			warnings = append(warnings, warnLayers("not-interpolated", []string{l.Name, missing}, "%s: %s, connected in the LEF, %s, not interpolated", l.Name, missing, missingReason(LayerStack, missing)))
+			continue
+		}
+		if below < 0 || above < 0 {
+			warnings = append(warnings, warn("not-interpolated", "%s: no metals with z data around it, not interpolated", l.Name))
+			continue
+		}
+		bottom, top := layerTop(LayerStack[below]), roundUm(LayerStack[above].Height)
+		if top <= bottom {
+			warnings = append(warnings, warn("not-interpolated", "%s: %s is not above %s, not interpolated", l.Name, LayerStack[above].Name, LayerStack[below].Name))
+			continue
+		}
+		LayerStack[i].Height = LayerStack[below].Height + LayerStack[below].Thickness
+		LayerStack[i].Thickness = LayerStack[above].Height - LayerStack[i].Height
+		LayerStack[i].ZSource = "interpolated " + LayerStack[below].Name + "/" + LayerStack[above].Name
+	}
+	return warnings
+}
+
+// Layer i spans from the top of the lower to the bottom of the upper layer
+// it connects, the z source says how, e.g. "through". False if it connects
+// no two layers with z data.
+func spanConnects(LayerStack []Layer, i int, how string) bool {
+	lower, upper, missing := viaMetals(LayerStack, i)
+	if missing != "" || lower < 0 || upper < 0 || lower == upper {
+		return false
+	}
+	LayerStack[i].Height = layerTop(LayerStack[lower])
+	LayerStack[i].Thickness = LayerStack[upper].Height - LayerStack[i].Height
+	LayerStack[i].ZSource = how + " " + LayerStack[lower].Name + "/" + LayerStack[upper].Name
+	return true
+}
+
+// Indices of the metals below and above via i, -1 if there is none. With
+// LEF connectivity they are the connected layers, and a connected layer
+// that is not in the stack or has no z data is returned as missing, the
+// nearest metals by z only stand in for vias the LEF says nothing about.
+func viaMetals(LayerStack []Layer, i int) (int, int, string) {
+	below, above := -1, -1
+	if len(LayerStack[i].LEFConnects) > 0 {
+		// Lowest and highest of the connected layers, by z
+		for _, name := range LayerStack[i].LEFConnects {
+			j := layerIndex(LayerStack, name)
+			if j < 0 || !usableMetal(LayerStack[j]) {
+				return -1, -1, name
+			}
+			if below < 0 || LayerStack[j].Height < LayerStack[below].Height {
+				below = j
+			}
+			if above < 0 || LayerStack[j].Height > LayerStack[above].Height {
+				above = j
+			}
+		}
+		if below == above {
+			return -1, -1, ""
+		}
+		return below, above, ""
+	}
+	// Nearest metals in stack order, skipping excluded or empty ones
+	for j := i - 1; j >= 0 && below < 0; j-- {
+		if LayerStack[j].Metal == 1 && usableMetal(LayerStack[j]) {
+			below = j
+		}
+	}
+	for j := i + 1; j < len(LayerStack) && above < 0; j++ {
+		if LayerStack[j].Metal == 1 && usableMetal(LayerStack[j]) {
+			above = j
+		}
+	}
+	return below, above, ""
+}
+
+// A layer a via can span to: with z data of its own, not a cut or dielectric
+func usableMetal(l Layer) bool {
+	return l.Thickness > 0.0 && l.ZFrom == "" && !isViaLayer(l) && !l.Dielectric
+}
+
+// Why viaMetals returned name as missing
+func missingReason(LayerStack []Layer, name string) string {
+	if layerIndex(LayerStack, name) < 0 {
+		return "is not in the stack"
+	}
+	return "has no z data"
+}
+
+func update_layerstack(LayerStack []Layer, layers *layerRegistry, layer KLayer) {
+	// Split gdsnumber into gds and layertype, checked by parseLypFile
+	gds, datatype, err := layer.GDS()
+	if err != nil {
+		return
+	}
+	for _, i := range slices.Clone(layers.lyp(layer.Name)) {
+		layers.remove(i, LayerStack[i])
+		LayerStack[i].GDSNumber   = gds
+		LayerStack[i].GDSDatatype = datatype
+		layers.add(i, LayerStack[i])
+			
+		// Copy color string 
+		LayerStack[i].Color = layer.Color
+		LayerStack[i].ColorSource = fmt.Sprintf("lyp %s line %d", layer.Name, layer.Line)
+		// Layers hidden in KLayout start hidden in GDS3D too
+		if layer.Visible == "false" {
+			LayerStack[i].Show = false
+		}
+	}
+}
+
+// Two lyp entries for one stack layer are a mistake in the lyp, the later
+// entry wins as it always did
+func checkLypMappings(LayerStack []Layer, layers *layerRegistry, lyp []KLayer) []Warning {
+	var warnings []Warning
+	claimed := map[int]KLayer{}
+	for _, layer := range lyp {
+		if _, _, err := layer.GDS(); err != nil {
+			continue
+		}
+		for _, i := range layers.lyp(layer.Name) {
+			if first, ok := claimed[i]; ok {
+				warnings = append(warnings, warnLayers("duplicate-mapping", []string{LayerStack[i].Name}, "%s: lyp entries %s at line %d and %s at line %d both map to it, using line %d",
+					LayerStack[i].Name, first.Name, first.Line, layer.Name, layer.Line, layer.Line))
+			}
+			claimed[i] = layer
+		}
+	}
+	return warnings
+}
+
+// Every layer used together with a cut layer in a LEF via is connected by it
+func update_layerstack_connects(LayerStack []Layer, vias []LefVia) {
+	for i, l := range LayerStack {
+		if !isViaLayer(l) {
+			continue
+		}
+		for _, via := range vias {
+			if !contains(via.Layers, l.Name) {
+				continue
+			}
+			for _, name := range via.Layers {
+				if name != l.Name && !contains(LayerStack[i].LEFConnects, name) {
+					LayerStack[i].LEFConnects = append(LayerStack[i].LEFConnects, name)
+				}
+			}
+		}
+	}
+}
+
+// Routing layers are the metals, whatever the stack table says
+func update_layerstack_metal(LayerStack []Layer, layers *layerRegistry, layer LefLayer) {
+	if layer.Type == "" {
+		return
+	}
+	for _, i := range layers.named(layer.Name) {
+		metal := 0
+		if layer.Type == "ROUTING" {
+			metal = 1
+		}
+		LayerStack[i].Metal = metal
+	}
+}
+
+func update_layerstack_height(LayerStack []Layer, layers *layerRegistry, layer LefLayer) {
+	for _, i := range layers.named(layer.Name) {
+		LayerStack[i].Height = layer.Height
+		LayerStack[i].Thickness = layer.Thickness
+		LayerStack[i].ZSource = "lef " + layer.Name
+	}
+}
+
+// Sections lists the section techfiles in the header of a -split master
+func writeTechFile(ctx context.Context, r *run, filePath string, LayerStack []Layer, sections []string) error {
+	return writeFileAtomic(r.path(filePath), func(file io.Writer) error {
+		return encodeTechFile(ctxio.NewWriter(ctx, file), r, LayerStack, sections)
+	})
+}
+
+func encodeTechFile(w io.Writer, r *run, LayerStack []Layer, sections []string) error {
+	// Sections in group order, each behind a banner
+	var layers []gds3d.Layer
+	for _, group := range layerGroups {
+		banner := false
+		for _, layer := range sortLayers(LayerStack, r.Sort) {
+			if layer.Group != group.Name {
+				continue
+			}
+			l, err := r.techFileLayer(layer)
+			if err != nil {
+				return err
+			}
+			if !banner {
+				rule := strings.Repeat("-", 70)
+				l.Comments = append([]string{rule, group.Title, rule, ""}, l.Comments...)
+				banner = true
+			}
+			layers = append(layers, l)
+		}
+	}
+	info := r.templateInfo(LayerStack, sections)
+	return gds3d.Write(w, layers,
+		gds3d.WithFormat(r.numberFormat()),
+		gds3d.WithHeader(r.header, info),
+		gds3d.WithFooter(r.footer, info))
+}
+
+// -via-metal writes cut layers as metal too, so GDS3D's metal only view
+// keeps the vias between the metals
+func (o Options) techFileMetal(layer Layer) int {
+	if o.ViaMetal && isViaLayer(layer) {
+		return 1
+	}
+	return layer.Metal
+}
+
+var techFileSorts = map[string]func(a, b Layer) int{
+	"stack": func(a, b Layer) int { return 0 },
+	"z": func(a, b Layer) int {
+		return cmp.Or(cmp.Compare(roundUm(a.Height), roundUm(b.Height)), cmp.Compare(layerTop(a), layerTop(b)), cmp.Compare(a.Name, b.Name))
+	},
+	"gds": func(a, b Layer) int {
+		return cmp.Or(cmp.Compare(a.GDSNumber, b.GDSNumber), cmp.Compare(a.GDSDatatype, b.GDSDatatype), cmp.Compare(a.Name, b.Name))
+	},
+}
+
+// Order of the layers within a techfile section, from -sort
+func sortLayers(LayerStack []Layer, order string) []Layer {
+	sorted := slices.Clone(LayerStack)
+	slices.SortStableFunc(sorted, techFileSorts[order])
+	return sorted
+}
+
+// The first generated techfiles misspelled Green as Greeen and the GDS3D
+// builds they were made for accept it, current GDS3D wants Green
+var techFileFormats = map[string][]string{
+	"legacy": gds3d.LegacyKeys,
+	"strict": gds3d.StrictKeys,
+}
+
+func (r *run) techFileLayer(layer Layer) (gds3d.Layer, error) {
+	red_float, green_float, blue_float, err := gds3d.ParseColor(layer.Color)
+	if err != nil {
+		return gds3d.Layer{}, &parseerr.Error{Layer: layer.Name, Err: fmt.Errorf("%w, from %s", err, layer.ColorSource)}
+	}
+
+	l := gds3d.Layer{
+		Name:      layer.Name,
+		Layer:     layer.GDSNumber,
+		Datatype:  layer.GDSDatatype,
+		Height:    layer.Height,
+		Thickness: layer.Thickness,
+		Red:       red_float,
+		Green:     green_float,
+		Blue:      blue_float,
+		Filter:    layer.Filter,
+		Metal:     r.techFileMetal(layer),
+		Shortkey:  layer.Shortkey,
+		Show:      layer.Show,
+	}
+	l.Comments = []string{"GDS and color: " + layer.ColorSource + ", z: " + layer.ZSource}
+	return l, nil
+}
+
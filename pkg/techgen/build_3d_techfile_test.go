@@ -0,0 +1,49 @@
+package techgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateLayerstackVias(t *testing.T) {
+	metal := func(name string, height float64) Layer {
+		return Layer{Name: name, Height: height, Thickness: 0.5, Metal: 1}
+	}
+	via := func(name string, connects ...string) Layer {
+		return Layer{Name: name, LEFConnects: connects}
+	}
+	tests := []struct {
+		name  string
+		stack []Layer
+		zfrom string // z source of Via2, "" if not interpolated
+		warn  string
+	}{
+		{"connected", []Layer{metal("Metal2", 2), via("Via2", "Metal2", "Metal3"), metal("Metal3", 3), metal("Metal4", 4)},
+			"interpolated Metal2/Metal3", ""},
+		{"connected metal removed", []Layer{metal("Metal2", 2), via("Via2", "Metal2", "Metal3"), metal("Metal4", 4)},
+			"", "Metal3, connected in the LEF, is not in the stack"},
+		{"connected metal without z", []Layer{metal("Metal2", 2), via("Via2", "Metal2", "Metal3"), {Name: "Metal3", Metal: 1}, metal("Metal4", 4)},
+			"", "Metal3, connected in the LEF, has no z data"},
+		{"no connectivity", []Layer{metal("Metal2", 2), via("Via2"), metal("Metal4", 4)},
+			"interpolated Metal2/Metal4", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := update_layerstack_vias(tt.stack)
+			v := tt.stack[layerIndex(tt.stack, "Via2")]
+			if v.ZSource != tt.zfrom {
+				t.Errorf("Via2 z source %q, want %q", v.ZSource, tt.zfrom)
+			}
+			if tt.zfrom == "" && v.Thickness != 0.0 {
+				t.Errorf("Via2 stretched to %g um", v.Thickness)
+			}
+			var got []string
+			for _, w := range warnings {
+				got = append(got, w.Message)
+			}
+			if tt.warn == "" && len(got) > 0 || tt.warn != "" && (len(got) != 1 || !strings.Contains(got[0], tt.warn)) {
+				t.Errorf("warnings %q, want %q", got, tt.warn)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Bundle of a generated techfile with everything needed to regenerate it
+//
+// -bundle out.zip packs the techfiles of the run, the resolved stack IR of
+// each, a copy of every input with its sha256 and a manifest.json with the
+// command line, so the exact techfile can be traced back and rebuilt later.
+
+package techgen
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Input file of the run, Flag is the command line flag it came from
+type bundleInput struct {
+	Flag, Path string
+}
+
+// Techfile written in the run and the stack it was written from
+type bundleOutput struct {
+	Path  string
+	Stack []Layer
+}
+
+type bundleFile struct {
+	Flag   string `json:"flag,omitempty"`
+	Source string `json:"source,omitempty"`
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+type bundleManifest struct {
+	Generator string       `json:"generator"`
+	IRVersion int          `json:"ir_version"`
+	Created   string       `json:"created"`
+	Command   []string     `json:"command"`
+	Archive   string       `json:"archive,omitempty"`
+	Inputs    []bundleFile `json:"inputs"`
+	Outputs   []bundleFile `json:"outputs"`
+}
+
+// Command is the command line of the run, for the manifest
+func writeBundle(ctx context.Context, r *run, bundlePath string, command []string, inputs []bundleInput, outputs []bundleOutput) error {
+	now := r.now
+	manifest := bundleManifest{
+		Generator: "build_3d_techfile",
+		IRVersion: irVersion,
+		Created:   now.Format(time.RFC3339),
+		Command:   command,
+		Archive:   r.Archive,
+	}
+
+	return writeFileAtomic(r.path(bundlePath), func(file io.Writer) error {
+		return writeBundleZip(ctx, file, r, now, inputs, outputs, manifest)
+	})
+}
+
+func writeBundleZip(ctx context.Context, out io.Writer, r *run, now time.Time, inputs []bundleInput, outputs []bundleOutput, manifest bundleManifest) error {
+	zw := zip.NewWriter(out)
+	add := func(name string, data []byte) (bundleFile, error) {
+		sum := sha256.Sum256(data)
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return bundleFile{}, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return bundleFile{}, err
+		}
+		return bundleFile{File: name, SHA256: hex.EncodeToString(sum[:]), Size: len(data)}, nil
+	}
+
+	err := func() error {
+		for _, in := range inputs {
+			data, err := readInput(ctx, r, in.Path)
+			if err != nil {
+				return err
+			}
+			entry, err := add("inputs/"+path.Base(filepath.ToSlash(in.Path)), data)
+			if err != nil {
+				return err
+			}
+			entry.Flag, entry.Source = in.Flag, in.Path
+			manifest.Inputs = append(manifest.Inputs, entry)
+		}
+		for _, out := range outputs {
+			data, err := os.ReadFile(r.path(out.Path))
+			if err != nil {
+				return err
+			}
+			name := filepath.Base(out.Path)
+			entry, err := add(name, data)
+			if err != nil {
+				return err
+			}
+			manifest.Outputs = append(manifest.Outputs, entry)
+
+			var ir bytes.Buffer
+			stem := strings.TrimSuffix(name, filepath.Ext(name))
+			if err := writeIRExport(&ir, exportStack{Layers: out.Stack, Stem: stem, Time: now}); err != nil {
+				return err
+			}
+			entry, err = add(stem+exporters["ir"].ext, ir.Bytes())
+			if err != nil {
+				return err
+			}
+			manifest.Outputs = append(manifest.Outputs, entry)
+		}
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = add("manifest.json", append(data, '\n'))
+		return err
+	}()
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+func readInput(ctx context.Context, r *run, name string) ([]byte, error) {
+	file, err := openInput(ctx, r, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,122 @@
+// Placeholder entries for design layers the stack does not know
+//
+//	build_3d_techfile -catch-all design.gds
+//
+// Every GDS layer the design draws on without a techfile entry gets a
+// hidden placeholder, so GDS3D can show it when asked instead of leaving
+// it out silently. Its z is a guess: the z of the stack layer on the same
+// GDS number, or of the layer its lyp name starts with, e.g. Metal1.slit
+// on Metal1, else a thin slab in a band above the stack. The color is the
+// lyp color or one picked per layer.
+
+package techgen
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	catchAllGap       = 0.5 // um between the stack top and the band
+	catchAllPitch     = 0.2 // um per placeholder in the band
+	catchAllThickness = 0.1 // um
+)
+
+// After all z updates, so the placeholders see the resolved stack
+func addCatchAllLayers(r *run, LayerStack []Layer, used map[gdsLayerKey]int, lyp []KLayer) []Layer {
+	if len(used) == 0 {
+		return LayerStack
+	}
+	known := map[gdsLayerKey]bool{}
+	top := 0.0
+	for _, l := range LayerStack {
+		known[gdsLayerKey{r.gdsNumber(l), l.GDSDatatype}] = true
+		top = max(top, layerTop(l))
+	}
+	var missing []gdsLayerKey
+	for key := range used {
+		if !known[key] {
+			missing = append(missing, key)
+		}
+	}
+	slices.SortFunc(missing, func(a, b gdsLayerKey) int {
+		return cmp.Or(cmp.Compare(a.Layer, b.Layer), cmp.Compare(a.Datatype, b.Datatype))
+	})
+
+	band := 0
+	for k, key := range missing {
+		layer := Layer{
+			Name:        fmt.Sprintf("GDS_%d_%d", key.Layer, key.Datatype),
+			GDSNumber:   key.Layer,
+			GDSDatatype: key.Datatype,
+			Color:       catchAllColor(k),
+			Group:       "other",
+			ColorSource: "catch-all",
+		}
+		for _, kl := range lyp {
+			if kl.Number == fmt.Sprintf("%d/%d", key.Layer, key.Datatype) {
+				layer.Name = kl.Name
+				layer.Color = kl.Color
+				layer.ColorSource = fmt.Sprintf("lyp %s line %d", kl.Name, kl.Line)
+			}
+		}
+		layer.altName = layer.Name
+		if base := catchAllBase(r, LayerStack, layer); base >= 0 {
+			layer.Height = LayerStack[base].Height
+			layer.Thickness = LayerStack[base].Thickness
+			layer.ZSource = "catch-all, z of " + LayerStack[base].Name
+		} else {
+			layer.Height = top + catchAllGap + float64(band)*catchAllPitch
+			layer.Thickness = catchAllThickness
+			layer.ZSource = "catch-all, above the stack"
+			band++
+		}
+		r.progress("Catch-all %s: %d/%d, %s", layer.Name, key.Layer, key.Datatype, layer.ZSource)
+		LayerStack = append(LayerStack, layer)
+	}
+	return LayerStack
+}
+
+// Stack layer whose z a placeholder takes, -1 for none
+func catchAllBase(r *run, LayerStack []Layer, layer Layer) int {
+	prefix, _, _ := strings.Cut(layer.Name, ".")
+	byName := -1
+	for i, l := range LayerStack {
+		if l.ZFrom != "" || l.Thickness <= 0.0 || l.Dielectric {
+			continue
+		}
+		if r.gdsNumber(l) == layer.GDSNumber {
+			return i
+		}
+		if l.Name == prefix {
+			byName = i
+		}
+	}
+	return byName
+}
+
+// Hues spread by the golden angle, so neighbours differ
+func catchAllColor(k int) string {
+	h := math.Mod(float64(k)*137.508, 360.0) / 60.0
+	x := 1.0 - math.Abs(math.Mod(h, 2.0)-1.0)
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = 1, x
+	case 1:
+		r, g = x, 1
+	case 2:
+		g, b = 1, x
+	case 3:
+		g, b = x, 1
+	case 4:
+		r, b = x, 1
+	default:
+		r, b = 1, x
+	}
+	level := func(v float64) int { return int(math.Round(64 + v*191)) }
+	return fmt.Sprintf("#%02x%02x%02x", level(r), level(g), level(b))
+}
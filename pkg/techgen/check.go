@@ -0,0 +1,93 @@
+// Check of a techfile against a GDS layout
+//
+//	build_3d_techfile check sg13g2.txt design.gds
+//
+// Lists the GDS layers the design draws on that have no techfile entry,
+// which GDS3D silently leaves out ("why is my metal missing?"), and the
+// techfile layers the design does not use. Exits with 1 when design
+// layers are missing from the techfile.
+
+package techgen
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+)
+
+func runCheck(ctx context.Context, args []string) {
+	opts := DefaultOptions()
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.StringVar(&opts.Unit, "unit", opts.Unit, "Length unit of the techfile: nm or um")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: build_3d_techfile check [-unit nm|um] techfile.txt design.gds")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	r, err := newRun(opts, nil)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	LayerStack, err := readTechFile(ctx, r, flags.Arg(0))
+	if err != nil {
+		fmt.Println("Error reading techfile:", err)
+		os.Exit(2)
+	}
+	used, err := readGDSLayers(ctx, r, flags.Arg(1))
+	if err != nil {
+		fmt.Println("Error reading GDS:", err)
+		os.Exit(2)
+	}
+	if checkGDSLayers(LayerStack, used) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Prints the report and returns how many design layers are missing
+func checkGDSLayers(LayerStack []Layer, used map[gdsLayerKey]int) int {
+	inTechFile := map[gdsLayerKey]bool{}
+	for _, l := range LayerStack {
+		inTechFile[gdsLayerKey{l.GDSNumber, l.GDSDatatype}] = true
+	}
+
+	var missing []gdsLayerKey
+	for key := range used {
+		if !inTechFile[key] {
+			missing = append(missing, key)
+		}
+	}
+	slices.SortFunc(missing, func(a, b gdsLayerKey) int {
+		return cmp.Or(cmp.Compare(a.Layer, b.Layer), cmp.Compare(a.Datatype, b.Datatype))
+	})
+	if len(missing) > 0 {
+		fmt.Println("Design layers without techfile entry, not shown in GDS3D:")
+		for _, key := range missing {
+			fmt.Printf("  %d/%d (%d shapes)\n", key.Layer, key.Datatype, used[key])
+		}
+	}
+
+	var unused []Layer
+	for _, l := range LayerStack {
+		if used[gdsLayerKey{l.GDSNumber, l.GDSDatatype}] == 0 {
+			unused = append(unused, l)
+		}
+	}
+	if len(unused) > 0 {
+		fmt.Println("Techfile layers not used by the design:")
+		for _, l := range unused {
+			fmt.Printf("  %s (%s)\n", l.Name, formatGDS(l))
+		}
+	}
+	if len(missing) == 0 {
+		fmt.Printf("All %d design layers are in the techfile\n", len(used))
+	}
+	return len(missing)
+}
@@ -0,0 +1,60 @@
+// Time stamps of the outputs
+//
+// The techfile header, the HTML report, STEP files and bundles carry the
+// time they were generated. For reproducible builds and golden files the
+// time is left out with -no-timestamp, or comes from a Clock given to
+// Generate, else from SOURCE_DATE_EPOCH, in that order.
+
+package techgen
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Clock gives the time stamp of the outputs
+type Clock interface {
+	Now() time.Time
+}
+
+// FixedClock always gives its time, e.g. for golden files
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time { return time.Time(c) }
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Time stamp written into the outputs. -no-timestamp stamps them with the
+// unix epoch, the techfile header leaves the date out. A clock given by the
+// caller comes next, then SOURCE_DATE_EPOCH, as in reproducible builds, so
+// unchanged inputs give byte identical files, and last the system clock.
+func generatedTime(noTimestamp bool, clock Clock) time.Time {
+	if noTimestamp {
+		return time.Unix(0, 0).UTC()
+	}
+	if clock != nil {
+		return clock.Now()
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if seconds, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC()
+		}
+	}
+	return systemClock{}.Now()
+}
+
+// A SOURCE_DATE_EPOCH generatedTime would use but cannot, it takes the
+// current time instead
+func epochWarnings(noTimestamp bool, clock Clock) []Warning {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if noTimestamp || clock != nil || epoch == "" {
+		return nil
+	}
+	if _, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+		return nil
+	}
+	return []Warning{warn("bad-epoch", "SOURCE_DATE_EPOCH %q is not a unix time, using the current time", epoch)}
+}
@@ -0,0 +1,36 @@
+package techgen
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGeneratedTime(t *testing.T) {
+	clock := FixedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name        string
+		noTimestamp bool
+		clock       Clock
+		epoch       string
+		want        time.Time
+		warnings    int
+	}{
+		{"no timestamp over all", true, clock, "1700000000", time.Unix(0, 0).UTC(), 0},
+		{"clock over epoch", false, clock, "1700000000", time.Time(clock), 0},
+		{"clock over bad epoch", false, clock, "yesterday", time.Time(clock), 0},
+		{"epoch", false, nil, "1700000000", time.Unix(1700000000, 0).UTC(), 0},
+		{"bad epoch", false, nil, "yesterday", time.Time{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SOURCE_DATE_EPOCH", tt.epoch)
+			got := generatedTime(tt.noTimestamp, tt.clock)
+			if !tt.want.IsZero() && !got.Equal(tt.want) {
+				t.Errorf("time %v, want %v", got, tt.want)
+			}
+			if warnings := epochWarnings(tt.noTimestamp, tt.clock); len(warnings) != tt.warnings {
+				t.Errorf("warnings %v, want %d", warnings, tt.warnings)
+			}
+		})
+	}
+}
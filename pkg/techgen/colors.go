@@ -0,0 +1,82 @@
+// Color overrides applied when the techfile is written
+//
+// A small text file to tweak the 3D look without touching the PDK owned
+// lyp or the stack config, one layer per line:
+//
+//	# layer     color    filter
+//	Metal1      #ff8800
+//	Metal2      -        0.4
+//	TopMetal2   #c0c0c0  0.2
+//
+// A color of "-" keeps the color, the filter is optional, lines starting
+// with # are comments. The overrides go on top of lyp, PDK dump and stack
+// config.
+
+package techgen
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type colorOverride struct {
+	Name   string
+	Color  string // "" keeps the color
+	Filter *float64
+	Source string
+}
+
+func parseColorFile(ctx context.Context, r *run, name string) ([]colorOverride, error) {
+	return decodeInput(ctx, r, name, decodeColorFile)
+}
+
+func decodeColorFile(file io.Reader, name string) ([]colorOverride, error) {
+
+	var overrides []colorOverride
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		// Colors start with # too, so only whole lines are comments
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: expected layer color [filter]", name, lineNo)
+		}
+		o := colorOverride{Name: fields[0], Source: fmt.Sprintf("colors %s line %d", name, lineNo)}
+		if fields[1] != "-" {
+			if len(fields[1]) != 7 || fields[1][0] != '#' {
+				return nil, fmt.Errorf("%s:%d: color %q, expected #rrggbb or -", name, lineNo, fields[1])
+			}
+			if _, err := strconv.ParseUint(fields[1][1:], 16, 32); err != nil {
+				return nil, fmt.Errorf("%s:%d: color %q, expected #rrggbb or -", name, lineNo, fields[1])
+			}
+			o.Color = strings.ToLower(fields[1])
+		}
+		if len(fields) == 3 {
+			filter, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil || filter < 0.0 || filter > 1.0 {
+				return nil, fmt.Errorf("%s:%d: filter %q, expected 0.0 to 1.0", name, lineNo, fields[2])
+			}
+			o.Filter = &filter
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, scanner.Err()
+}
+
+func update_layerstack_colors(LayerStack Stackup, overrides []colorOverride) []Warning {
+	var warnings []Warning
+	var changes []layerOverride
+	for _, o := range overrides {
+		changes = append(changes, layerOverride{Name: o.Name, Color: o.Color, Filter: o.Filter, Source: o.Source})
+	}
+	for _, o := range LayerStack.ApplyOverrides(changes) {
+		warnings = append(warnings, warn("unknown-layer", "%s: no layer %s", o.Source, o.Name))
+	}
+	return warnings
+}
@@ -0,0 +1,239 @@
+// Extra datatypes of a stack layer as separate techfile entries
+//
+// A GDS layer often carries more than the drawing datatype, e.g. filler
+// and slit shapes on the metals. The stack config lists the ones to show:
+/*
+
+"datatypes": [
+  { "layer": "Metal1", "purpose": "filler" },
+  { "layer": "Metal1", "purpose": "slit" }
+]
+*/
+// Each becomes an entry named layer.purpose, e.g. Metal1.filler, right
+// after its layer. It takes GDS number, datatype and color from the lyp
+// entry of that name and always has the z of its layer. The entries start
+// hidden, "layers" in the stack config can show them or change colors.
+//
+// Merged sources draw more GDS layers as part of one 3D layer, e.g. the
+// metal fill together with the drawn metal:
+/*
+
+"merge": [
+  { "layer": "Metal1", "sources": ["Metal1.filler", "8/23"] }
+]
+*/
+// A source is a lyp name or a gds/datatype pair. Every source becomes an
+// entry like the datatypes above, but it keeps the look of its layer:
+// color, filter, show, shortkey and group follow the layer.
+//
+// Multi-patterning splits a metal over mask datatypes. Each mask becomes
+// an entry layer.maskN on the GDS number of its layer, looking like the
+// layer but in a darker to lighter shade, so the mask assignment shows:
+/*
+
+"masks": [
+  { "layer": "Metal1", "datatypes": [20, 21] }
+]
+*/
+
+package techgen
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/lyp"
+)
+
+type datatypeConfig struct {
+	Layer   string `json:"layer"`
+	Purpose string `json:"purpose"`
+}
+
+type maskConfig struct {
+	Layer     string `json:"layer"`
+	Datatypes []int  `json:"datatypes"`
+}
+
+type mergeConfig struct {
+	Layer   string   `json:"layer"`
+	Sources []string `json:"sources"`
+}
+
+var gdsPair = regexp.MustCompile(`^(\d+)/(\d+)$`)
+
+// Layer number and datatype of a gds/datatype pair from the stack config
+func parseGDSPair(pair string) (int, int, error) {
+	return lyp.ParseSource(pair)
+}
+
+func addDatatypeLayers(LayerStack []Layer, datatypes []datatypeConfig) ([]Layer, []Warning) {
+	var warnings []Warning
+	for _, dt := range datatypes {
+		var w []Warning
+		LayerStack, _, w = addSharedZLayer(LayerStack, dt.Layer, dt.Layer+"."+dt.Purpose)
+		warnings = append(warnings, w...)
+	}
+	return LayerStack, warnings
+}
+
+func addMergedLayers(LayerStack []Layer, merges []mergeConfig) ([]Layer, []Warning) {
+	var warnings []Warning
+	for _, m := range merges {
+		for _, source := range m.Sources {
+			name := source
+			number, datatype := 0, 0
+			pair := gdsPair.MatchString(source)
+			if pair {
+				var err error
+				if number, datatype, err = parseGDSPair(source); err != nil {
+					warnings = append(warnings, warn("config", "stack config: merge into %s: %v", m.Layer, err))
+					continue
+				}
+				name = fmt.Sprintf("%s.%d_%d", m.Layer, number, datatype)
+			}
+			var entry *Layer
+			var w []Warning
+			LayerStack, entry, w = addSharedZLayer(LayerStack, m.Layer, name)
+			warnings = append(warnings, w...)
+			if entry == nil {
+				continue
+			}
+			entry.SharedLook = true
+			if pair {
+				entry.GDSNumber, entry.GDSDatatype = number, datatype
+				entry.ColorSource = "stack config " + source
+			}
+		}
+	}
+	return LayerStack, warnings
+}
+
+func addMaskLayers(LayerStack []Layer, masks []maskConfig) ([]Layer, []Warning) {
+	var warnings []Warning
+	for _, m := range masks {
+		for k, dt := range m.Datatypes {
+			var entry *Layer
+			var w []Warning
+			LayerStack, entry, w = addSharedZLayer(LayerStack, m.Layer, fmt.Sprintf("%s.mask%d", m.Layer, k+1))
+			warnings = append(warnings, w...)
+			if entry == nil {
+				continue
+			}
+			entry.SharedLook = true
+			entry.Mask = k + 1
+			entry.GDSDatatype = dt
+			entry.ColorSource = fmt.Sprintf("stack config mask %d", k+1)
+		}
+	}
+	return LayerStack, warnings
+}
+
+// Adds entry name right after layer and the entries already added for it.
+// Returns the entry, or nil if there is none to add.
+func addSharedZLayer(LayerStack []Layer, layer, name string) ([]Layer, *Layer, []Warning) {
+	base := -1
+	for i, l := range LayerStack {
+		if l.Name == name {
+			// Already there, e.g. read back from an IR
+			return LayerStack, nil, nil
+		}
+		if l.Name == layer {
+			base = i
+		}
+	}
+	if base == -1 {
+		return LayerStack, nil, []Warning{warn("unknown-layer", "stack config: no layer %s for %s", layer, name)}
+	}
+	entry := LayerStack[base]
+	entry.Name = name
+	entry.altName = name
+	entry.ZFrom = layer
+	entry.Show = false
+	entry.Shortkey = ""
+	entry.LEFConnects = nil
+	entry.ColorSource = "default"
+	entry.ZSource = "z of " + layer
+
+	at := base + 1
+	for at < len(LayerStack) && LayerStack[at].ZFrom == layer {
+		at++
+	}
+	LayerStack = append(LayerStack[:at], append([]Layer{entry}, LayerStack[at:]...)...)
+	return LayerStack, &LayerStack[at], nil
+}
+
+func update_layerstack_shared_z(LayerStack []Layer) {
+	for i, l := range LayerStack {
+		if l.ZFrom == "" {
+			continue
+		}
+		for _, base := range LayerStack {
+			if base.Name == l.ZFrom {
+				LayerStack[i].Height = base.Height
+				LayerStack[i].Thickness = base.Thickness
+				LayerStack[i].ZSource = "z of " + base.Name
+			}
+		}
+	}
+}
+
+// Merged sources and masks look like their layer, after all overrides are
+// applied
+func update_layerstack_shared_look(LayerStack []Layer) {
+	masks := map[string]int{}
+	for _, l := range LayerStack {
+		if l.Mask > 0 {
+			masks[l.ZFrom]++
+		}
+	}
+	for i, l := range LayerStack {
+		if !l.SharedLook {
+			continue
+		}
+		b := layerIndex(LayerStack, l.ZFrom)
+		if b < 0 {
+			continue
+		}
+		base := LayerStack[b]
+		LayerStack[i].Color = base.Color
+		LayerStack[i].Filter = base.Filter
+		LayerStack[i].Show = base.Show
+		LayerStack[i].Shortkey = base.Shortkey
+		LayerStack[i].Group = base.Group
+		LayerStack[i].Metal = base.Metal
+		if l.Mask > 0 {
+			// Mask datatypes are on the GDS number of their layer
+			LayerStack[i].GDSNumber = base.GDSNumber
+			LayerStack[i].Color = maskShade(base.Color, l.Mask, masks[base.Name])
+		}
+		suffix := ", color of " + base.Name
+		// Already marked when read back from an IR
+		if !strings.HasPrefix(l.ColorSource, "stack config") && l.ColorSource != "default" &&
+			!strings.HasSuffix(l.ColorSource, suffix) {
+			LayerStack[i].ColorSource = l.ColorSource + suffix
+		}
+	}
+}
+
+// Mask k of n from 30% darker to 30% lighter than the layer color
+func maskShade(color string, k, n int) string {
+	var rgb [3]int
+	if _, err := fmt.Sscanf(color, "#%02x%02x%02x", &rgb[0], &rgb[1], &rgb[2]); err != nil {
+		return color
+	}
+	f := 0.0
+	if n > 1 {
+		f = -0.3 + 0.6*float64(k-1)/float64(n-1)
+	}
+	for i, c := range rgb {
+		if f < 0 {
+			rgb[i] = int(math.Round(float64(c) * (1 + f)))
+		} else {
+			rgb[i] = int(math.Round(float64(c) + (255-float64(c))*f))
+		}
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
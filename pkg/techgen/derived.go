@@ -0,0 +1,172 @@
+// Derived layers computed from the GDS before viewing
+//
+// Some 3D layers are not drawn but follow from other layers, e.g. the
+// transistor gates where poly crosses active. The stack config defines
+// them as boolean expressions of GDS layers:
+/*
+
+"derived": [
+  { "name": "Gate", "expr": "GatPoly.drawing & Activ.drawing", "gds": "250/0", "z": "GatPoly" }
+]
+*/
+// Operands are lyp names or gds/datatype pairs, operators are & (and), |
+// (or), - (not) and ^ (xor), with parentheses where the order matters. The
+// techfile entry is on the scratch gds layer, with the z of the "z" layer.
+// The companion KLayout Python script, written as the derived export,
+// computes the scratch layers into a copy of the GDS:
+//
+//	klayout -b -r sg13g2_derived.py -rd input=chip.gds -rd output=chip_3d.gds
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const defaultDerivedColor = "#e04080"
+
+type derivedConfig struct {
+	Name  string `json:"name"`
+	Expr  string `json:"expr"`
+	GDS   string `json:"gds"`
+	Z     string `json:"z"`
+	Color string `json:"color"`
+}
+
+var derivedToken = regexp.MustCompile(`\s*([()&|^-]|[^\s()&|^-]+)`)
+
+// Operators and operands of an expression, checked to alternate
+func tokenizeDerived(expr string) ([]string, error) {
+	var tokens []string
+	rest := strings.TrimSpace(expr)
+	for rest != "" {
+		m := derivedToken.FindStringSubmatchIndex(rest)
+		if m == nil || m[0] != 0 {
+			return nil, fmt.Errorf("bad expression %q", expr)
+		}
+		tokens = append(tokens, rest[m[2]:m[3]])
+		rest = strings.TrimSpace(rest[m[1]:])
+	}
+	depth, operand := 0, false
+	for _, t := range tokens {
+		switch {
+		case t == "(":
+			if operand {
+				return nil, fmt.Errorf("expression %q: operator expected before (", expr)
+			}
+			depth++
+		case t == ")":
+			if !operand || depth == 0 {
+				return nil, fmt.Errorf("expression %q: unbalanced )", expr)
+			}
+			depth--
+		case strings.Contains("&|^-", t):
+			if !operand {
+				return nil, fmt.Errorf("expression %q: operand expected before %s", expr, t)
+			}
+			operand = false
+		default:
+			if operand {
+				return nil, fmt.Errorf("expression %q: operator expected before %s", expr, t)
+			}
+			operand = true
+		}
+	}
+	if !operand || depth != 0 {
+		return nil, fmt.Errorf("expression %q is incomplete", expr)
+	}
+	return tokens, nil
+}
+
+func (d derivedConfig) validate() error {
+	switch {
+	case d.Name == "":
+		return fmt.Errorf("has no name")
+	case d.GDS == "" || d.Z == "":
+		return fmt.Errorf("%s needs a scratch gds layer and a z layer", d.Name)
+	case d.Color != "" && len(d.Color) != 7:
+		return fmt.Errorf("%s has color %q, expected #rrggbb", d.Name, d.Color)
+	}
+	_, err := tokenizeDerived(d.Expr)
+	return err
+}
+
+// The expression with every operand as a gds/datatype pair
+func resolveDerived(expr string, lyp []KLayer) (string, error) {
+	tokens, err := tokenizeDerived(expr)
+	if err != nil {
+		return "", err
+	}
+	for i, t := range tokens {
+		if strings.Contains("()&|^-", t) || gdsPair.MatchString(t) {
+			continue
+		}
+		var layer Layer
+		if err := setLayerGDS(&layer, t, lyp); err != nil {
+			return "", err
+		}
+		tokens[i] = fmt.Sprintf("%d/%d", layer.GDSNumber, layer.GDSDatatype)
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+// Derived layers go right after their z layer, existing entries (e.g.
+// read back from an IR) are kept as they are
+func addDerivedLayers(LayerStack []Layer, derived []derivedConfig, lyp []KLayer) ([]Layer, []Warning) {
+	var warnings []Warning
+	for _, d := range derived {
+		expr, err := resolveDerived(d.Expr, lyp)
+		if err != nil {
+			warnings = append(warnings, warn("derived", "derived %s: %v", d.Name, err))
+			continue
+		}
+		var entry *Layer
+		var w []Warning
+		LayerStack, entry, w = addSharedZLayer(LayerStack, d.Z, d.Name)
+		warnings = append(warnings, w...)
+		if entry == nil {
+			continue
+		}
+		if err := setLayerGDS(entry, d.GDS, lyp); err != nil {
+			warnings = append(warnings, warn("no-lyp-layer", "derived %s: %v", d.Name, err))
+		}
+		entry.Derived = expr
+		entry.Show = true
+		entry.Metal = 0
+		entry.Color = defaultDerivedColor
+		if d.Color != "" {
+			entry.Color = d.Color
+		}
+	}
+	return LayerStack, warnings
+}
+
+func writeDerivedExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Derived layers of %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "# klayout -b -r %s_derived.py -rd input=chip.gds -rd output=chip_3d.gds\n\n", stack.Stem)
+	fmt.Fprintf(bw, "import pya\n\n")
+	fmt.Fprintf(bw, "layout = pya.Layout()\nlayout.read(input)\ntop = layout.top_cell()\n\n")
+	fmt.Fprintf(bw, "def region(layer, datatype):\n")
+	fmt.Fprintf(bw, "    return pya.Region(top.begin_shapes_rec(layout.layer(layer, datatype)))\n\n")
+	for _, l := range stack.Layers {
+		if l.Derived == "" {
+			continue
+		}
+		var expr []string
+		for _, t := range strings.Fields(l.Derived) {
+			if pair := gdsPair.FindStringSubmatch(t); pair != nil {
+				t = fmt.Sprintf("region(%s, %s)", pair[1], pair[2])
+			}
+			expr = append(expr, t)
+		}
+		fmt.Fprintf(bw, "# %s: %s\n", l.Name, l.Derived)
+		fmt.Fprintf(bw, "top.shapes(layout.layer(%d, %d)).insert(%s)\n\n", l.GDSNumber, l.GDSDatatype, strings.Join(expr, " "))
+	}
+	fmt.Fprintf(bw, "layout.write(output)\n")
+	return bw.Flush()
+}
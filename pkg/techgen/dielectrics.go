@@ -0,0 +1,185 @@
+// Dielectric and passivation slabs
+//
+// The stack config can add semi-transparent slabs for the ILD, nitride or
+// passivation, giving the 3D view an encapsulated look:
+/*
+
+"dielectrics": [
+  { "name": "ILD1", "from": "Metal1", "to": "Via1", "color": "#c8d8f0" },
+  { "name": "Passivation", "height": 11.0, "thickness": 1.5, "filter": 0.9 }
+]
+*/
+// A slab spans from the bottom of the "from" layer to the top of the "to"
+// layer, fills the gap "between" two layers or is given by height and
+// thickness in um. Slabs are not drawn in
+// the layout, GDS3D shows one wherever the layout has shapes on its "gds"
+// layer. That sets the extent of the slab: the default prBoundary.drawing
+// covers the die, a lyp name or gds/datatype pair of another outline layer
+// limits it to e.g. a sealring or a test structure.
+
+package techgen
+
+import (
+	"fmt"
+)
+
+const (
+	defaultDielectricGDS    = "prBoundary.drawing"
+	defaultDielectricColor  = "#c8d8f0"
+	defaultDielectricFilter = 0.8
+)
+
+type dielectricConfig struct {
+	Name      string   `json:"name"`
+	GDS       string   `json:"gds"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Between   []string `json:"between"`
+	Height    *float64 `json:"height"`
+	Thickness *float64 `json:"thickness"`
+	Color     string   `json:"color"`
+	Filter    *float64 `json:"filter"`
+}
+
+func (d dielectricConfig) validate() error {
+	switch {
+	case d.Name == "":
+		return fmt.Errorf("has no name")
+	case (d.From == "") != (d.To == ""):
+		return fmt.Errorf("%s needs both from and to", d.Name)
+	case (d.Height == nil) != (d.Thickness == nil):
+		return fmt.Errorf("%s needs both height and thickness", d.Name)
+	case d.Between != nil && len(d.Between) != 2:
+		return fmt.Errorf("%s needs two layers in between", d.Name)
+	case btoi(d.From != "")+btoi(d.Height != nil)+btoi(d.Between != nil) != 1:
+		return fmt.Errorf("%s needs one of from/to, height/thickness or between", d.Name)
+	case d.Thickness != nil && *d.Thickness <= 0.0:
+		return fmt.Errorf("%s has thickness %g, expected a positive thickness in um", d.Name, *d.Thickness)
+	case d.Color != "" && len(d.Color) != 7:
+		return fmt.Errorf("%s has color %q, expected #rrggbb", d.Name, d.Color)
+	case d.Filter != nil && (*d.Filter < 0.0 || *d.Filter > 1.0):
+		return fmt.Errorf("%s has filter %g, expected 0.0 to 1.0", d.Name, *d.Filter)
+	}
+	return nil
+}
+
+// Slabs go on top of the stack, existing entries (e.g. read back from an
+// IR) are kept as they are
+func addDielectricLayers(LayerStack []Layer, dielectrics []dielectricConfig, lyp []KLayer) ([]Layer, []Warning) {
+	var warnings []Warning
+	added := false
+	for _, d := range dielectrics {
+		if layerIndex(LayerStack, d.Name) >= 0 {
+			continue
+		}
+		layer := Layer{
+			Name:        d.Name,
+			altName:     d.Name,
+			Color:       defaultDielectricColor,
+			Filter:      defaultDielectricFilter,
+			Show:        true,
+			Dielectric:  true,
+			ColorSource: "default",
+			ZSource:     "stack config",
+		}
+		gds := d.GDS
+		if gds == "" {
+			gds = defaultDielectricGDS
+		}
+		if err := setLayerGDS(&layer, gds, lyp); err != nil {
+			warnings = append(warnings, warn("no-lyp-layer", "dielectric %s: %v", d.Name, err))
+		}
+		if d.Color != "" {
+			layer.Color = d.Color
+		}
+		if d.Filter != nil {
+			layer.Filter = *d.Filter
+		}
+		layer.Group = defaultGroup(layer)
+		LayerStack = append(LayerStack, layer)
+		added = true
+	}
+	if added {
+		assignShortkeys(LayerStack)
+	}
+	return LayerStack, warnings
+}
+
+// GDS number and datatype of a layer the stack config adds, from a
+// gds/datatype pair or a lyp name. An error if the pair is out of range or
+// the lyp has no such layer.
+func setLayerGDS(layer *Layer, gds string, lyp []KLayer) error {
+	if gdsPair.MatchString(gds) {
+		number, datatype, err := parseGDSPair(gds)
+		if err != nil {
+			return err
+		}
+		layer.GDSNumber, layer.GDSDatatype = number, datatype
+		layer.ColorSource = "stack config " + gds
+		return nil
+	}
+	found := false
+	for _, k := range lyp {
+		if k.Name == gds {
+			number, datatype, err := k.GDS()
+			if err != nil {
+				continue
+			}
+			layer.GDSNumber, layer.GDSDatatype = number, datatype
+			layer.ColorSource = fmt.Sprintf("lyp %s line %d", k.Name, k.Line)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no lyp layer %s", gds)
+	}
+	return nil
+}
+
+// After via interpolation, so from and to have their final z
+func update_layerstack_dielectrics(LayerStack []Layer, dielectrics []dielectricConfig) []Warning {
+	var warnings []Warning
+	for _, d := range dielectrics {
+		i := layerIndex(LayerStack, d.Name)
+		if i < 0 {
+			continue
+		}
+		if d.Height != nil {
+			LayerStack[i].Height = *d.Height
+			LayerStack[i].Thickness = *d.Thickness
+			LayerStack[i].ZSource = "stack config"
+			continue
+		}
+		if d.Between != nil {
+			below, above := layerIndex(LayerStack, d.Between[0]), layerIndex(LayerStack, d.Between[1])
+			if below < 0 || above < 0 {
+				warnings = append(warnings, warn("unknown-layer", "dielectric %s: no layer %s or %s", d.Name, d.Between[0], d.Between[1]))
+				continue
+			}
+			LayerStack[i].Height = layerTop(LayerStack[below])
+			LayerStack[i].Thickness = LayerStack[above].Height - LayerStack[i].Height
+			LayerStack[i].ZSource = "between " + d.Between[0] + " and " + d.Between[1]
+			continue
+		}
+		from, to := layerIndex(LayerStack, d.From), layerIndex(LayerStack, d.To)
+		if from < 0 || to < 0 {
+			warnings = append(warnings, warn("unknown-layer", "dielectric %s: no layer %s or %s", d.Name, d.From, d.To))
+			continue
+		}
+		LayerStack[i].Height = LayerStack[from].Height
+		LayerStack[i].Thickness = layerTop(LayerStack[to]) - LayerStack[from].Height
+		LayerStack[i].ZSource = d.From + " to " + d.To
+	}
+	return warnings
+}
+
+func layerIndex(LayerStack []Layer, name string) int {
+	return Stackup(LayerStack).Index(name)
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+// Field level diff of two techfiles
+//
+//	build_3d_techfile diff old.txt new.txt
+//
+// Layers are matched by name, so reordering alone is no difference. Each
+// added, removed or changed layer is reported with the fields that differ:
+// GDS number, z range, color, filter and the Metal, Show and Shortkey
+// flags. Exits with 1 when the techfiles differ, as diff(1) does.
+
+package techgen
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type layerField struct {
+	Name  string
+	Value func(Layer) string
+}
+
+var diffFields = []layerField{
+	{"gds", formatGDS},
+	{"z", func(l Layer) string {
+		return formatDecimal(roundUm(l.Height)) + ".." + formatDecimal(layerTop(l)) + " um"
+	}},
+	{"color", func(l Layer) string { return l.Color }},
+	{"filter", func(l Layer) string { return formatDecimal(l.Filter) }},
+	{"metal", func(l Layer) string { return strconv.Itoa(l.Metal) }},
+	{"show", func(l Layer) string { return strconv.FormatBool(l.Show) }},
+	{"shortkey", func(l Layer) string { return l.Shortkey }},
+}
+
+func runDiff(ctx context.Context, args []string) {
+	opts := DefaultOptions()
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.StringVar(&opts.Unit, "unit", opts.Unit, "Length unit of both techfiles: nm or um")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: build_3d_techfile diff [-unit nm|um] old.txt new.txt")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	r, err := newRun(opts, nil)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	oldStack, err := readTechFile(ctx, r, flags.Arg(0))
+	if err != nil {
+		fmt.Println("Error reading techfile:", err)
+		os.Exit(2)
+	}
+	newStack, err := readTechFile(ctx, r, flags.Arg(1))
+	if err != nil {
+		fmt.Println("Error reading techfile:", err)
+		os.Exit(2)
+	}
+	if diffTechFiles(oldStack, newStack) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Prints the differences and returns how many layers differ
+func diffTechFiles(oldStack, newStack []Layer) int {
+	index := func(LayerStack []Layer) map[string]Layer {
+		layers := map[string]Layer{}
+		for _, l := range LayerStack {
+			layers[l.Name] = l
+		}
+		return layers
+	}
+	oldLayers, newLayers := index(oldStack), index(newStack)
+
+	differ := 0
+	for _, l := range oldStack {
+		if _, ok := newLayers[l.Name]; !ok {
+			fmt.Printf("- %s (%s)\n", l.Name, formatGDS(l))
+			differ++
+		}
+	}
+	for _, l := range newStack {
+		old, ok := oldLayers[l.Name]
+		if !ok {
+			fmt.Printf("+ %s (%s)\n", l.Name, formatGDS(l))
+			differ++
+			continue
+		}
+		changed := false
+		for _, field := range diffFields {
+			before, after := field.Value(old), field.Value(l)
+			if before == after {
+				continue
+			}
+			if !changed {
+				fmt.Printf("~ %s\n", l.Name)
+				changed = true
+			}
+			fmt.Printf("    %s: %s -> %s\n", field.Name, before, after)
+		}
+		if changed {
+			differ++
+		}
+	}
+	if differ == 0 {
+		fmt.Println("No differences")
+	}
+	return differ
+}
@@ -0,0 +1,34 @@
+package techgen
+
+import "testing"
+
+func TestDiffTechFiles(t *testing.T) {
+	metal1 := Layer{Name: "Metal1", GDSNumber: 8, Color: "#39bfff", Height: 0.93, Thickness: 0.4, Metal: 1, Show: true}
+	metal2 := Layer{Name: "Metal2", GDSNumber: 10, Color: "#ccccd9", Height: 2.0, Thickness: 0.45, Metal: 1, Show: true}
+	with := func(l Layer, change func(*Layer)) Layer {
+		change(&l)
+		return l
+	}
+	tests := []struct {
+		name     string
+		old, new []Layer
+		differ   int
+	}{
+		{"same", []Layer{metal1, metal2}, []Layer{metal1, metal2}, 0},
+		{"reordered", []Layer{metal1, metal2}, []Layer{metal2, metal1}, 0},
+		{"removed", []Layer{metal1, metal2}, []Layer{metal1}, 1},
+		{"added", []Layer{metal1}, []Layer{metal1, metal2}, 1},
+		{"color", []Layer{metal1}, []Layer{with(metal1, func(l *Layer) { l.Color = "#ff0000" })}, 1},
+		{"two fields of a layer", []Layer{metal1}, []Layer{with(metal1, func(l *Layer) { l.Height, l.Show = 1.0, false })}, 1},
+		{"below the um rounding", []Layer{metal1}, []Layer{with(metal1, func(l *Layer) { l.Height += 1e-9 })}, 0},
+		{"source is no field", []Layer{metal1}, []Layer{with(metal1, func(l *Layer) { l.ZSource = "LEF" })}, 0},
+		{"all kinds", []Layer{metal1, metal2}, []Layer{with(metal1, func(l *Layer) { l.GDSDatatype = 2 }), {Name: "TopMetal1", GDSNumber: 126}}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if differ := diffTechFiles(tt.old, tt.new); differ != tt.differ {
+				t.Errorf("%d layers differ, want %d", differ, tt.differ)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// Extra output formats generated from the resolved layer stack
+//
+// Every exporter writes one file next to the techfile, named after the
+// techfile with the exporter extension, e.g. sg13g2.txt -> sg13g2.json.
+
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/format"
+)
+
+type exporter struct {
+	ext   string
+	write func(w io.Writer, stack exportStack) error
+}
+
+// What an exporter writes: the layers, the output file name without
+// directory and extension, for formats that reference companion files, the
+// warnings of the run, for the reports, and what the stack config sets
+// besides the layers
+type exportStack struct {
+	Layers          []Layer
+	Stem            string
+	Warnings        []Warning
+	Time            time.Time // time stamp of the run
+	SubstrateMargin float64   // um the substrate of the previews reaches past the die
+}
+
+var exporters = map[string]exporter{
+	"json":        {".json", writeJSONExport},
+	"csv":         {".csv", writeCSVExport},
+	"tsv":         {".tsv", writeTSVExport},
+	"svg":         {".svg", writeSVGExport},
+	"html":        {".html", writeHTMLExport},
+	"stl":         {".stl", writeSTLExport},
+	"gltf":        {".gltf", writeGLTFExport},
+	"glb":         {".glb", writeGLBExport},
+	"obj":         {".obj", writeOBJExport},
+	"mtl":         {".mtl", writeMTLExport},
+	"d25":         {".lyd25", writeD25Export},
+	"xs":          {".xs", writeXSExport},
+	"blender":     {"_blender.py", writeBlenderExport},
+	"gdsfactory":  {"_gdsfactory.yaml", writeGdsfactoryExport},
+	"openems":     {"_openems.py", writeOpenEMSExport},
+	"markdown":    {".md", writeMarkdownExport},
+	"legend-svg":  {"_legend.svg", writeLegendSVGExport},
+	"legend-png":  {"_legend.png", writeLegendPNGExport},
+	"vtk":         {".vtu", writeVTKExport},
+	"dot":         {"_connectivity.dot", writeDotExport},
+	"mermaid":     {"_connectivity.mmd", writeMermaidExport},
+	"webgl":       {"_webgl.json", writeWebGLExport},
+	"freecad":     {".FCMacro", writeFreeCADExport},
+	"step":        {".step", writeSTEPExport},
+	"emstack":     {"_emstack.txt", writeEMStackExport},
+	"ir":          {".ir.toml", writeIRExport},
+	"ir-json":     {".ir.json", writeIRJSONExport},
+	"threejs-cdn": {"_3d.html", writeThreeJSCDNExport},
+	"skill":       {"_stack.il", writeSKILLExport},
+	"derived":     {"_derived.py", writeDerivedExport},
+}
+
+// Formats that reference a companion file written along with them
+var exportCompanions = map[string][]string{
+	"obj": {"mtl"},
+}
+
+// Built in and registered with the format package, built in ones win
+func exporterNames() string {
+	names := format.WriterNames()
+	for name := range exporters {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// The exports of list in the order they are written, each followed by
+// the companions it references. Derived layers are empty without the
+// script that computes them, a config with derived layers adds it.
+func exportNames(list []string, config *stackConfig) []string {
+	var names []string
+	for _, name := range list {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if config != nil && len(config.Derived) > 0 && !slices.Contains(names, "derived") {
+		names = append(names, "derived")
+	}
+	for i := 0; i < len(names); i++ {
+		for _, companion := range exportCompanions[names[i]] {
+			if !slices.Contains(names, companion) {
+				names = slices.Insert(names, i+1, companion)
+			}
+		}
+	}
+	return names
+}
+
+// Export name of the stack of the techfile at techFilePath: the exporter,
+// the file it goes to and what it gets. False for an unknown format.
+func (r *run) export(ctx context.Context, name, techFilePath string, LayerStack []Layer, warnings []Warning) (exporter, string, exportStack, bool) {
+	exp, ok := exporters[name]
+	if !ok {
+		exp, ok = registeredExporter(ctx, name)
+	}
+	if !ok {
+		return exporter{}, "", exportStack{}, false
+	}
+	base := strings.TrimSuffix(techFilePath, filepath.Ext(techFilePath))
+	stack := exportStack{
+		Layers:          r.writtenStack(LayerStack),
+		Stem:            filepath.Base(base),
+		Warnings:        warnings,
+		Time:            r.now,
+		SubstrateMargin: r.substrateMargin,
+	}
+	if name == "ir" || name == "ir-json" {
+		// The IR regenerates the stack, it keeps the process heights
+		stack.Layers = LayerStack
+	}
+	return exp, base + exp.ext, stack, true
+}
+
+// Writes the exports of names next to the techfile and reports them to
+// the log of r, returns how many failed
+func writeExports(ctx context.Context, r *run, names []string, techFilePath string, LayerStack []Layer, warnings []Warning) int {
+	failed := 0
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return failed + 1
+		}
+		exp, filePath, stack, ok := r.export(ctx, name, techFilePath, LayerStack, warnings)
+		if !ok {
+			fmt.Fprintf(r.log, "Unknown export format: %s (use %s)\n", name, exporterNames())
+			failed++
+			continue
+		}
+		if err := writeExport(ctx, r.path(filePath), exp, stack); err != nil {
+			fmt.Fprintf(r.log, "Error writing %s export: %v\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(r.log, "Wrote %s export: %s\n", name, filePath)
+	}
+	return failed
+}
+
+// The exports of names as writeExports writes them, by file name
+func renderExports(ctx context.Context, r *run, names []string, techFilePath string, LayerStack []Layer, warnings []Warning) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, name := range names {
+		exp, filePath, stack, ok := r.export(ctx, name, techFilePath, LayerStack, warnings)
+		if !ok {
+			return nil, fmt.Errorf("unknown export format %s (use %s)", name, exporterNames())
+		}
+		var buf bytes.Buffer
+		if err := exp.write(ctxio.NewWriter(ctx, &buf), stack); err != nil {
+			return nil, fmt.Errorf("%s export: %w", name, err)
+		}
+		files[filePath] = buf.Bytes()
+	}
+	return files, nil
+}
+
+// A writer of the format package as an exporter, it gets the stack as IR
+func registeredExporter(ctx context.Context, name string) (exporter, bool) {
+	w, ok := format.LookupWriter(name)
+	if !ok {
+		return exporter{}, false
+	}
+	return exporter{w.Ext(), func(out io.Writer, stack exportStack) error {
+		return w.Write(ctx, out, irStack(stack.Layers), stack.Stem)
+	}}, true
+}
+
+func writeExport(ctx context.Context, filePath string, exp exporter, stack exportStack) error {
+	return writeFileAtomic(filePath, func(file io.Writer) error {
+		return exp.write(ctxio.NewWriter(ctx, file), stack)
+	})
+}
+
+// Layers are in micron in the stack, helpers shared by the exporters
+
+func layerTop(layer Layer) float64 {
+	return roundUm(layer.Height + layer.Thickness)
+}
+
+// Drop float noise from the stack arithmetic, 1e-6 um is far below any process
+func roundUm(value float64) float64 {
+	return math.Round(value*1e6) / 1e6
+}
+
+// Shortest exact decimal with a decimal point, the way GDS3D techfiles
+// write Filter and TOML needs to read a number as float
+func formatDecimal(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func formatGDS(layer Layer) string {
+	return fmt.Sprintf("%d/%d", layer.GDSNumber, layer.GDSDatatype)
+}
+
+// Rough material guess for exporters that need one. IHP metals are
+// aluminum, contacts and vias tungsten.
+func layerMaterial(layer Layer) string {
+	switch {
+	case layer.Dielectric:
+		return "sio2"
+	case layer.Metal == 1:
+		return "aluminum"
+	case isViaLayer(layer):
+		return "tungsten"
+	case strings.Contains(layer.Name, "Poly"):
+		return "polysilicon"
+	case layer.Name == "MIM":
+		return "sin"
+	}
+	return "si"
+}
+
+// Placeholder electrical properties for the EM exporters, conductivity in
+// S/m. The substrate value is for the 20 Ohm cm IHP bulk.
+var materialProperties = map[string]struct {
+	Conductivity float64
+	Permittivity float64
+}{
+	"aluminum":    {3.5e7, 1.0},
+	"tungsten":    {1.8e7, 1.0},
+	"polysilicon": {1.0e5, 11.9},
+	"si":          {5.0, 11.9},
+	"sin":         {0.0, 6.6},
+	"sio2":        {0.0, 4.1},
+}
+
+// Opacity for the mesh exporters, the inverse of the GDS3D Filter
+func layerAlpha(layer Layer) float64 {
+	return 1.0 - layer.Filter
+}
+
+// Split #rrggbb into 0..1 components, the same way the techfile writer does
+func layerRGB(layer Layer) (float64, float64, float64) {
+	var r, g, b int
+	if _, err := fmt.Sscanf(layer.Color, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return 0.5, 0.5, 0.5
+	}
+	return float64(r) / 255.0, float64(g) / 255.0, float64(b) / 255.0
+}
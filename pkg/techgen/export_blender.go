@@ -0,0 +1,89 @@
+// Blender python script that builds the 3D stack preview with one mesh and
+// material per layer, as a starting point for publication renders
+//
+// Run with: blender --python sg13g2_blender.py
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const blenderScriptFunctions = `
+import bpy
+
+SCALE = 0.1  # Blender units per micron
+
+FACES = [%s]
+
+
+def make_material(name, rgba, metal):
+    mat = bpy.data.materials.new(name)
+    mat.use_nodes = True
+    mat.diffuse_color = rgba
+    bsdf = mat.node_tree.nodes["Principled BSDF"]
+    bsdf.inputs["Base Color"].default_value = rgba
+    bsdf.inputs["Metallic"].default_value = 0.8 if metal else 0.0
+    bsdf.inputs["Roughness"].default_value = 0.3 if metal else 0.7
+    bsdf.inputs["Alpha"].default_value = rgba[3]
+    if rgba[3] < 1.0:
+        mat.blend_method = "BLEND"
+    return mat
+
+
+def make_layer(name, rgba, metal, boxes):
+    verts, faces = [], []
+    for x0, y0, z0, x1, y1, z1 in boxes:
+        base = len(verts)
+        for i in range(8):
+            verts.append(((x1 if i & 1 else x0) * SCALE,
+                          (y1 if i & 2 else y0) * SCALE,
+                          (z1 if i & 4 else z0) * SCALE))
+        faces.extend([tuple(base + c for c in face) for face in FACES])
+    mesh = bpy.data.meshes.new(name)
+    mesh.from_pydata(verts, [], faces)
+    mesh.update()
+    obj = bpy.data.objects.new(name, mesh)
+    obj.data.materials.append(make_material(name, rgba, metal))
+    collection = bpy.data.collections.new(name)
+    bpy.context.scene.collection.children.link(collection)
+    collection.objects.link(obj)
+
+`
+
+func writeBlenderExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Layer stack of %s for Blender, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "# Run with: blender --python %s_blender.py\n", stack.Stem)
+
+	faces := ""
+	for i, f := range previewBoxFaces {
+		if i > 0 {
+			faces += ", "
+		}
+		faces += fmt.Sprintf("(%d, %d, %d, %d)", f.Corners[0], f.Corners[1], f.Corners[2], f.Corners[3])
+	}
+	fmt.Fprintf(bw, blenderScriptFunctions, faces)
+
+	boxes := previewBoxes(stack.Layers, stack.SubstrateMargin)
+	for i, l := range stack.Layers {
+		var list string
+		for _, b := range boxes {
+			if b.Layer == i {
+				list += fmt.Sprintf("\n    (%g, %g, %g, %g, %g, %g),", b.X0, b.Y0, b.Z0, b.X1, b.Y1, b.Z1)
+			}
+		}
+		if list == "" {
+			continue
+		}
+		r, g, b := layerRGB(l)
+		metal := "False"
+		if l.Metal == 1 {
+			metal = "True"
+		}
+		fmt.Fprintf(bw, "make_layer(%q, (%.3f, %.3f, %.3f, %.3f), %s, [%s\n])\n", l.Name, r, g, b, layerAlpha(l), metal, list)
+	}
+	return bw.Flush()
+}
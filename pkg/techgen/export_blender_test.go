@@ -0,0 +1,67 @@
+package techgen_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A make_layer call of the Blender and FreeCAD scripts
+type scriptLayer struct {
+	name, color, arg string
+	zMin, zMax       float64
+	boxes            int
+}
+
+func scriptLayers(t *testing.T, script []byte) []scriptLayer {
+	t.Helper()
+	call := regexp.MustCompile(`(?m)^make_layer\("([^"]+)", \(([^)]*)\), (\S+), \[\n((?:    \(.*\),\n)*)\]\)$`)
+	var layers []scriptLayer
+	for _, m := range call.FindAllStringSubmatch(string(script), -1) {
+		l := scriptLayer{name: m[1], color: m[2], arg: m[3]}
+		for i, box := range strings.Split(strings.TrimSuffix(m[4], "\n"), "\n") {
+			// x0, y0, z0, x1, y1, z1
+			f := strings.Split(strings.Trim(strings.TrimSpace(box), "(),"), ", ")
+			if len(f) != 6 {
+				t.Fatalf("%s: box %q", l.name, box)
+			}
+			z0, err0 := strconv.ParseFloat(f[2], 64)
+			z1, err1 := strconv.ParseFloat(f[5], 64)
+			if err0 != nil || err1 != nil {
+				t.Fatalf("%s: box %q", l.name, box)
+			}
+			if i == 0 {
+				l.zMin, l.zMax = z0, z1
+			}
+			l.zMin, l.zMax = min(l.zMin, z0), max(l.zMax, z1)
+			l.boxes++
+		}
+		layers = append(layers, l)
+	}
+	return layers
+}
+
+func TestBlenderExport(t *testing.T) {
+	stack, data := fixtureExport(t, "blender", "sg13g2_blender.py")
+	calls := scriptLayers(t, data)
+	layers := drawnLayers(stack)
+	if len(calls) != len(layers) {
+		t.Fatalf("%d make_layer calls, want one per layer, %d", len(calls), len(layers))
+	}
+	for i, l := range layers {
+		c := calls[i]
+		_, _, _, rgb := parseColor(t, l.Color)
+		rgba := fmt.Sprintf("%s, %.3f", rgb, 1.0-l.Filter)
+		if c.name != l.Name || c.color != rgba {
+			t.Errorf("make_layer %d: %s (%s), want %s (%s)", i, c.name, c.color, l.Name, rgba)
+		}
+		if metal := strconv.FormatBool(l.Metal); c.arg != strings.ToUpper(metal[:1])+metal[1:] {
+			t.Errorf("%s: metal %s, want %t", l.Name, c.arg, l.Metal)
+		}
+		if l.Name != "Substrate" && (!near(c.zMin, l.Height) || !near(c.zMax, top(l))) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, c.zMin, c.zMax, l.Height, top(l))
+		}
+	}
+}
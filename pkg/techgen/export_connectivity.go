@@ -0,0 +1,138 @@
+// Via connectivity diagram as Graphviz dot or Mermaid
+//
+// For each via layer the diagram shows the layers it touches in z next to
+// the layers it connects according to the LEF via definitions:
+//
+//	solid green   touches in z and connected in the LEF
+//	dashed gray   touches in z, the LEF has no via using this layer
+//	dotted orange touches in z but not connected in the LEF
+//	bold red      connected in the LEF but the via does not reach it in z
+//
+// A red edge is a via whose interpolated height does not bridge its metals.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+type viaEdge struct {
+	Via, Layer string
+	Status     string // ok, z, z-only, gap
+}
+
+const connectTolerance = 1e-6
+
+// Layers touching the bottom or the top of a via, or named in its LEF vias
+func viaEdges(LayerStack []Layer) []viaEdge {
+	var edges []viaEdge
+	for _, v := range LayerStack {
+		if !isViaLayer(v) || v.Thickness <= 0.0 {
+			continue
+		}
+		var touching []string
+		for _, l := range LayerStack {
+			if isViaLayer(l) || l.Name == "Substrate" || l.Thickness <= 0.0 {
+				continue
+			}
+			for _, z := range []float64{v.Height, layerTop(v)} {
+				if z >= l.Height-connectTolerance && z <= layerTop(l)+connectTolerance && !contains(touching, l.Name) {
+					touching = append(touching, l.Name)
+				}
+			}
+		}
+		for _, name := range touching {
+			status := "z"
+			if len(v.LEFConnects) > 0 {
+				status = "z-only"
+				if contains(v.LEFConnects, name) {
+					status = "ok"
+				}
+			}
+			edges = append(edges, viaEdge{v.Name, name, status})
+		}
+		for _, name := range v.LEFConnects {
+			if !contains(touching, name) {
+				edges = append(edges, viaEdge{v.Name, name, "gap"})
+			}
+		}
+	}
+	return edges
+}
+
+func connectivityNodes(LayerStack []Layer, edges []viaEdge) []Layer {
+	var nodes []Layer
+	for _, l := range LayerStack {
+		for _, e := range edges {
+			if e.Via == l.Name || e.Layer == l.Name {
+				nodes = append(nodes, l)
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+func nodeID(name string) string {
+	return "n_" + xsIdentifier.ReplaceAllString(name, "_")
+}
+
+func writeDotExport(w io.Writer, stack exportStack) error {
+	edges := viaEdges(stack.Layers)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "// Via connectivity of %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "digraph connectivity {\n  rankdir=BT;\n  node [style=filled, fontname=\"sans-serif\"];\n")
+	for _, l := range connectivityNodes(stack.Layers, edges) {
+		shape := "box"
+		if isViaLayer(l) {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(bw, "  %s [label=\"%s\\n%s..%s um\", shape=%s, fillcolor=\"%s\"];\n", nodeID(l.Name), l.Name, formatUm(l.Height), formatUm(layerTop(l)), shape, l.Color)
+	}
+	styles := map[string]string{
+		"ok":     "color=darkgreen",
+		"z":      "color=gray, style=dashed",
+		"z-only": "color=orange, style=dotted",
+		"gap":    "color=red, penwidth=2, label=\"not bridged\"",
+	}
+	for _, e := range edges {
+		fmt.Fprintf(bw, "  %s -> %s [dir=none, %s];\n", nodeID(e.Via), nodeID(e.Layer), styles[e.Status])
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+func writeMermaidExport(w io.Writer, stack exportStack) error {
+	edges := viaEdges(stack.Layers)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%%%% Via connectivity of %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "graph BT\n")
+	for _, l := range connectivityNodes(stack.Layers, edges) {
+		open, close := "[", "]"
+		if isViaLayer(l) {
+			open, close = "([", "])"
+		}
+		fmt.Fprintf(bw, "  %s%s\"%s<br/>%s..%s um\"%s\n", nodeID(l.Name), open, l.Name, formatUm(l.Height), formatUm(layerTop(l)), close)
+		fmt.Fprintf(bw, "  style %s fill:%s\n", nodeID(l.Name), l.Color)
+	}
+	arrows := map[string]string{"ok": "---", "z": "-.-", "z-only": "-.-", "gap": "-- not bridged ---"}
+	colors := map[string]string{"ok": "darkgreen", "z": "gray", "z-only": "orange", "gap": "red"}
+	for i, e := range edges {
+		fmt.Fprintf(bw, "  %s %s %s\n", nodeID(e.Via), arrows[e.Status], nodeID(e.Layer))
+		fmt.Fprintf(bw, "  linkStyle %d stroke:%s\n", i, colors[e.Status])
+	}
+	return bw.Flush()
+}
+
+// Warn about vias that do not reach a layer their LEF vias connect
+func checkViaConnectivity(LayerStack []Layer) []Warning {
+	var warnings []Warning
+	for _, e := range viaEdges(LayerStack) {
+		if e.Status == "gap" {
+			warnings = append(warnings, warn("via-reach", "%s: does not reach %s in z although the LEF vias connect them", e.Via, e.Layer))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,96 @@
+package techgen_test
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+)
+
+// The z range of a node label, e.g. 0.93..1.33 um
+func zLabel(l ir.Layer) string {
+	return fmt.Sprintf("%s..%s um", trimFloat(l.Height), trimFloat(top(l)))
+}
+
+func trimFloat(f float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.4f", f), "0"), ".")
+}
+
+// Checks the nodes and edges of a connectivity diagram: every node is a
+// layer labeled with its z range and every edge joins a via to a layer
+// its bottom or top touches, or one the LEF connects it to
+func checkConnectivity(t *testing.T, stack ir.Stack, labels map[string]string, edges [][2]string) {
+	t.Helper()
+	layers := map[string]ir.Layer{}
+	for _, l := range stack.Layers {
+		layers[l.Name] = l
+	}
+	for name, label := range labels {
+		l, ok := layers[name]
+		if !ok {
+			t.Errorf("node %s of no layer", name)
+			continue
+		}
+		if want := zLabel(l); label != want {
+			t.Errorf("node %s labeled %s, want %s", name, label, want)
+		}
+	}
+	connected := map[string]int{}
+	for _, e := range edges {
+		for _, name := range e {
+			if _, ok := labels[name]; !ok {
+				t.Errorf("edge %s - %s to no node %s", e[0], e[1], name)
+			}
+		}
+		via, l := layers[e[0]], layers[e[1]]
+		touches := func(z float64) bool { return z >= l.Height-1e-6 && z <= top(l)+1e-6 }
+		if !touches(via.Height) && !touches(top(via)) && !slices.Contains(via.LEFConnects, l.Name) {
+			t.Errorf("edge %s - %s, but %s does not reach %s", e[0], e[1], e[0], e[1])
+		}
+		connected[e[0]]++
+	}
+	// Each via of the metal stack joins the metals below and above it
+	for _, l := range stack.Layers {
+		if strings.HasPrefix(l.Name, "Via") && connected[l.Name] < 2 {
+			t.Errorf("%s with %d edges, want at least 2", l.Name, connected[l.Name])
+		}
+	}
+}
+
+func TestDotExport(t *testing.T) {
+	stack, data := fixtureExport(t, "dot", "sg13g2_connectivity.dot")
+	labels := map[string]string{}
+	for _, m := range regexp.MustCompile(`(?m)^  n_(\w+) \[label="\w+\\n([^"]+)", shape=\w+, fillcolor="(#[0-9a-f]{6})"\];$`).FindAllStringSubmatch(string(data), -1) {
+		labels[m[1]] = m[2]
+		if l := stack.Layers[slices.IndexFunc(stack.Layers, func(l ir.Layer) bool { return l.Name == m[1] })]; m[3] != l.Color {
+			t.Errorf("node %s filled %s, want %s", m[1], m[3], l.Color)
+		}
+	}
+	var edges [][2]string
+	for _, m := range regexp.MustCompile(`(?m)^  n_(\w+) -> n_(\w+) \[dir=none`).FindAllStringSubmatch(string(data), -1) {
+		edges = append(edges, [2]string{m[1], m[2]})
+	}
+	if len(labels) == 0 || len(edges) == 0 {
+		t.Fatalf("%d nodes, %d edges", len(labels), len(edges))
+	}
+	checkConnectivity(t, stack, labels, edges)
+}
+
+func TestMermaidExport(t *testing.T) {
+	stack, data := fixtureExport(t, "mermaid", "sg13g2_connectivity.mmd")
+	labels := map[string]string{}
+	for _, m := range regexp.MustCompile(`(?m)^  n_(\w+)\(?\["\w+<br/>([^"]+)"\]\)?$`).FindAllStringSubmatch(string(data), -1) {
+		labels[m[1]] = m[2]
+	}
+	var edges [][2]string
+	for _, m := range regexp.MustCompile(`(?m)^  n_(\w+) (?:---|-\.-|-- not bridged ---) n_(\w+)$`).FindAllStringSubmatch(string(data), -1) {
+		edges = append(edges, [2]string{m[1], m[2]})
+	}
+	if len(labels) == 0 || len(edges) == 0 {
+		t.Fatalf("%d nodes, %d edges", len(labels), len(edges))
+	}
+	checkConnectivity(t, stack, labels, edges)
+}
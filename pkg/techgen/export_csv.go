@@ -0,0 +1,43 @@
+// Spreadsheet friendly CSV/TSV table of the resolved layer stack
+
+package techgen
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+func formatUm(value float64) string {
+	return strconv.FormatFloat(roundUm(value), 'f', -1, 64)
+}
+
+func writeTableExport(w io.Writer, LayerStack []Layer, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.Write([]string{"name", "gds_layer", "gds_datatype", "z_bottom_um", "z_top_um", "thickness_um", "color", "metal", "color_source", "z_source"})
+	for _, l := range LayerStack {
+		cw.Write([]string{
+			l.Name,
+			strconv.Itoa(l.GDSNumber),
+			strconv.Itoa(l.GDSDatatype),
+			formatUm(l.Height),
+			formatUm(layerTop(l)),
+			formatUm(l.Thickness),
+			l.Color,
+			strconv.Itoa(l.Metal),
+			l.ColorSource,
+			l.ZSource,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCSVExport(w io.Writer, stack exportStack) error {
+	return writeTableExport(w, stack.Layers, ',')
+}
+
+func writeTSVExport(w io.Writer, stack exportStack) error {
+	return writeTableExport(w, stack.Layers, '\t')
+}
@@ -0,0 +1,40 @@
+package techgen_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+)
+
+func TestTableExports(t *testing.T) {
+	for _, tt := range []struct {
+		name, file string
+		comma      rune
+	}{
+		{"csv", "sg13g2.csv", ','},
+		{"tsv", "sg13g2.tsv", '\t'},
+	} {
+		stack, data := fixtureExport(t, tt.name, tt.file)
+		r := csv.NewReader(bytes.NewReader(data))
+		r.Comma = tt.comma
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(rows) != len(stack.Layers)+1 {
+			t.Fatalf("%s: %d rows, want a header and one per layer, %d", tt.name, len(rows), len(stack.Layers)+1)
+		}
+		if h := rows[0]; h[0] != "name" || h[3] != "z_bottom_um" || h[4] != "z_top_um" || h[6] != "color" {
+			t.Fatalf("%s: header %v", tt.name, h)
+		}
+		for i, row := range rows[1:] {
+			l := stack.Layers[i]
+			zBottom, _ := strconv.ParseFloat(row[3], 64)
+			zTop, _ := strconv.ParseFloat(row[4], 64)
+			if row[0] != l.Name || !near(zBottom, l.Height) || !near(zTop, top(l)) || row[6] != l.Color {
+				t.Errorf("%s: row %v, want %s from %g to %g in %s", tt.name, row, l.Name, l.Height, top(l), l.Color)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// KLayout 2.5D view script (.lyd25 macro) built from the resolved stack, so
+// the GDS3D and KLayout 3D views are generated from the same data
+
+package techgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func colorHex(layer Layer) string {
+	return "0x" + strings.ToLower(strings.TrimPrefix(layer.Color, "#"))
+}
+
+func writeD25Export(w io.Writer, stack exportStack) error {
+	var script bytes.Buffer
+	fmt.Fprintf(&script, "# 2.5D view of %s, generated by build_3d_techfile\n\n", stack.Stem)
+	for _, l := range stack.Layers {
+		if l.Thickness <= 0.0 {
+			continue
+		}
+		source := fmt.Sprintf("input(%d, %d)", l.GDSNumber, l.GDSDatatype)
+		if l.Name == "Substrate" {
+			// GDS3D makes the substrate from the layout extent, do the same here
+			source = "extent"
+		}
+		fmt.Fprintf(&script, "z(%s, zstart: %s, zstop: %s, name: %q, color: %s)\n",
+			source, formatUm(l.Height), formatUm(layerTop(l)), l.Name, colorHex(l))
+	}
+
+	// Keep the script readable in the macro editor, only escape what XML needs
+	text := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(script.String())
+
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<klayout-macro>
+ <description>%s 2.5D view</description>
+ <version/>
+ <category>d25</category>
+ <prolog/>
+ <epilog/>
+ <doc/>
+ <autorun>false</autorun>
+ <autorun-early>false</autorun-early>
+ <shortcut/>
+ <show-in-menu>true</show-in-menu>
+ <group-name>d25_scripts</group-name>
+ <menu-path>tools_menu.d25.end</menu-path>
+ <interpreter>dsl</interpreter>
+ <dsl-interpreter-name>d25-dsl-xml</dsl-interpreter-name>
+ <text>%s</text>
+</klayout-macro>
+`, stack.Stem, text)
+	return err
+}
@@ -0,0 +1,39 @@
+package techgen_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestD25Export(t *testing.T) {
+	stack, data := fixtureExport(t, "d25", "sg13g2.lyd25")
+	re := regexp.MustCompile(`(?m)^z\((extent|input\((\d+), (\d+)\)), zstart: (\S+), zstop: (\S+), name: "([^"]+)", color: 0x([0-9a-f]{6})\)$`)
+	matches := re.FindAllStringSubmatch(string(data), -1)
+
+	layers := drawnLayers(stack)
+	if len(matches) != len(layers) {
+		t.Fatalf("%d z() lines, want one per layer, %d", len(matches), len(layers))
+	}
+	for i, l := range layers {
+		m := matches[i]
+		zStart, _ := strconv.ParseFloat(m[4], 64)
+		zStop, _ := strconv.ParseFloat(m[5], 64)
+		if m[6] != l.Name || "#"+m[7] != l.Color {
+			t.Errorf("z() %d of %s colored #%s, want %s colored %s", i, m[6], m[7], l.Name, l.Color)
+		}
+		if l.Name == "Substrate" {
+			if m[1] != "extent" {
+				t.Errorf("substrate of %s, want the extent of the layout", m[1])
+			}
+			continue
+		}
+		if input := fmt.Sprintf("input(%d, %d)", l.GDSLayer, l.GDSDatatype); m[1] != input {
+			t.Errorf("%s of %s, want %s", l.Name, m[1], input)
+		}
+		if !near(zStart, l.Height) || !near(zStop, top(l)) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, zStart, zStop, l.Height, top(l))
+		}
+	}
+}
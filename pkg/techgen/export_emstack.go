@@ -0,0 +1,98 @@
+// Generic layered stackup for planar EM solvers (Sonnet, HFSS, ADS style)
+//
+// The stack is split into homogeneous dielectric layers, top to bottom, with
+// a boundary at every conductor bottom and top. Conductors are listed with
+// the z range they occupy, so a solver setup can place thin metals on an
+// interface and thick metals across the dielectric layers they span.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const emAirThickness = 100.0 // um of air above the passivation
+
+func writeEMStackExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# EM stackup of %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "# Units are micron and S/m. Permittivities and conductivities are\n")
+	fmt.Fprintf(bw, "# placeholders, check them against the foundry documentation.\n\n")
+
+	var conductors []Layer
+	var substrate *Layer
+	boundaries := []float64{0.0}
+	for i, l := range stack.Layers {
+		if l.Thickness <= 0.0 {
+			continue
+		}
+		switch {
+		case l.Name == "Substrate":
+			substrate = &stack.Layers[i]
+		case l.Metal == 1 || isViaLayer(l):
+			conductors = append(conductors, l)
+			boundaries = append(boundaries, roundUm(l.Height), layerTop(l))
+		}
+	}
+	sort.Float64s(boundaries)
+	sort.SliceStable(conductors, func(i, j int) bool { return conductors[i].Height < conductors[j].Height })
+	var levels []float64
+	for _, z := range boundaries {
+		if z >= 0.0 && (len(levels) == 0 || z > levels[len(levels)-1]) {
+			levels = append(levels, z)
+		}
+	}
+
+	oxide := materialProperties["sio2"]
+	table := func(rows [][]string) {
+		widths := make([]int, len(rows[0]))
+		for _, row := range rows {
+			for i, cell := range row {
+				widths[i] = max(widths[i], len(cell))
+			}
+		}
+		for _, row := range rows {
+			var line string
+			for i, cell := range row {
+				line += fmt.Sprintf("%-*s  ", widths[i], cell)
+			}
+			fmt.Fprintln(bw, strings.TrimRight(line, " "))
+		}
+	}
+
+	fmt.Fprintf(bw, "# Dielectric layers from top to bottom\n")
+	fmt.Fprintf(bw, "DIELECTRICS\n")
+	rows := [][]string{{"# name", "z_bottom", "thickness", "erel", "conductivity"}}
+	top := levels[len(levels)-1]
+	rows = append(rows, []string{"Air", formatUm(top), formatUm(emAirThickness), "1", "0"})
+	for i := len(levels) - 1; i > 0; i-- {
+		rows = append(rows, []string{fmt.Sprintf("Oxide%d", i), formatUm(levels[i-1]), formatUm(roundUm(levels[i] - levels[i-1])),
+			fmt.Sprintf("%g", oxide.Permittivity), fmt.Sprintf("%g", oxide.Conductivity)})
+	}
+	if substrate != nil {
+		props := materialProperties[layerMaterial(*substrate)]
+		rows = append(rows, []string{substrate.Name, formatUm(substrate.Height), formatUm(substrate.Thickness),
+			fmt.Sprintf("%g", props.Permittivity), fmt.Sprintf("%g", props.Conductivity)})
+	}
+	table(rows)
+	fmt.Fprintf(bw, "END\n\n")
+
+	fmt.Fprintf(bw, "# Conductors from bottom to top\n")
+	fmt.Fprintf(bw, "CONDUCTORS\n")
+	rows = [][]string{{"# name", "kind", "gds", "z_bottom", "z_top", "thickness", "conductivity"}}
+	for _, l := range conductors {
+		kind := "metal"
+		if isViaLayer(l) {
+			kind = "via"
+		}
+		rows = append(rows, []string{l.Name, kind, formatGDS(l), formatUm(l.Height), formatUm(layerTop(l)), formatUm(l.Thickness),
+			fmt.Sprintf("%g", materialProperties[layerMaterial(l)].Conductivity)})
+	}
+	table(rows)
+	fmt.Fprintf(bw, "END\n")
+	return bw.Flush()
+}
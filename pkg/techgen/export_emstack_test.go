@@ -0,0 +1,79 @@
+package techgen_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEMStackExport(t *testing.T) {
+	stack, data := fixtureExport(t, "emstack", "sg13g2_emstack.txt")
+
+	// The rows of the sections, comments left out
+	sections := map[string][][]string{}
+	section := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		switch {
+		case len(f) == 0 || strings.HasPrefix(f[0], "#"):
+		case len(f) == 1 && f[0] == "END":
+			section = ""
+		case len(f) == 1:
+			section = f[0]
+		case section != "":
+			sections[section] = append(sections[section], f)
+		}
+	}
+	num := func(s string) float64 {
+		t.Helper()
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// Conductors with their kind, GDS and z
+	conductors := map[string][]string{}
+	zTop := 0.0
+	for _, row := range sections["CONDUCTORS"] {
+		if len(row) != 7 {
+			t.Fatalf("conductor %v, want 7 columns", row)
+		}
+		conductors[row[0]] = row[1:]
+		zTop = max(zTop, num(row[4]))
+	}
+	for _, l := range metalLayers(stack) {
+		c, ok := conductors[l.Name]
+		if !ok {
+			t.Errorf("metal %s not a conductor", l.Name)
+			continue
+		}
+		if gds := fmt.Sprintf("%d/%d", l.GDSLayer, l.GDSDatatype); c[0] != "metal" || c[1] != gds {
+			t.Errorf("%s: %s on %s, want metal on %s", l.Name, c[0], c[1], gds)
+		}
+		if !near(num(c[2]), l.Height) || !near(num(c[3]), top(l)) || !near(num(c[4]), l.Thickness) {
+			t.Errorf("%s: z %s..%s, thickness %s, want %g..%g, %g", l.Name, c[2], c[3], c[4], l.Height, top(l), l.Thickness)
+		}
+	}
+
+	// Dielectrics from the air down to the substrate, each on the one
+	// below it, the oxide from 0 to the top of the conductors
+	dielectrics := sections["DIELECTRICS"]
+	if len(dielectrics) < 3 || dielectrics[0][0] != "Air" || dielectrics[len(dielectrics)-1][0] != "Substrate" {
+		t.Fatalf("dielectrics %v, want air, oxides and substrate", dielectrics)
+	}
+	if air := num(dielectrics[0][1]); !near(air, zTop) {
+		t.Errorf("air from %g, want the top of the conductors, %g", air, zTop)
+	}
+	for i := 1; i < len(dielectrics); i++ {
+		above, d := dielectrics[i-1], dielectrics[i]
+		if !near(num(d[1])+num(d[2]), num(above[1])) {
+			t.Errorf("%s from %s, %s thick, below %s from %s", d[0], d[1], d[2], above[0], above[1])
+		}
+	}
+}
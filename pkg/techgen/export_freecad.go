@@ -0,0 +1,60 @@
+// FreeCAD macro building the 3D stack preview as parametric box solids,
+// one Part feature per layer, for combining the chip stack with package
+// and interposer models
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const freecadMacroFunctions = `
+import FreeCAD as App
+import Part
+
+SCALE = 0.001  # mm per micron
+
+doc = App.ActiveDocument or App.newDocument(DOC_NAME)
+
+
+def make_layer(name, rgb, transparency, boxes):
+    group = doc.addObject("App::DocumentObjectGroup", name)
+    for n, (x0, y0, z0, x1, y1, z1) in enumerate(boxes):
+        box = doc.addObject("Part::Box", f"{name}_{n}")
+        box.Length = (x1 - x0) * SCALE
+        box.Width = (y1 - y0) * SCALE
+        box.Height = (z1 - z0) * SCALE
+        box.Placement.Base = App.Vector(x0 * SCALE, y0 * SCALE, z0 * SCALE)
+        if App.GuiUp:
+            box.ViewObject.ShapeColor = rgb
+            box.ViewObject.Transparency = transparency
+        group.addObject(box)
+
+`
+
+func writeFreeCADExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# FreeCAD macro with the layer stack of %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "DOC_NAME = %q\n", xsIdentifier.ReplaceAllString(stack.Stem, "_"))
+	io.WriteString(bw, freecadMacroFunctions)
+
+	boxes := previewBoxes(stack.Layers, stack.SubstrateMargin)
+	for i, l := range stack.Layers {
+		var list string
+		for _, b := range boxes {
+			if b.Layer == i {
+				list += fmt.Sprintf("\n    (%g, %g, %g, %g, %g, %g),", b.X0, b.Y0, b.Z0, b.X1, b.Y1, b.Z1)
+			}
+		}
+		if list == "" {
+			continue
+		}
+		r, g, b := layerRGB(l)
+		transparency := int((1.0 - layerAlpha(l)) * 100)
+		fmt.Fprintf(bw, "make_layer(%q, (%.3f, %.3f, %.3f), %d, [%s\n])\n", xsIdentifier.ReplaceAllString(l.Name, "_"), r, g, b, transparency, list)
+	}
+	fmt.Fprintf(bw, "\ndoc.recompute()\n")
+	return bw.Flush()
+}
@@ -0,0 +1,29 @@
+package techgen_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestFreeCADExport(t *testing.T) {
+	stack, data := fixtureExport(t, "freecad", "sg13g2.FCMacro")
+	calls := scriptLayers(t, data)
+	layers := drawnLayers(stack)
+	if len(calls) != len(layers) {
+		t.Fatalf("%d make_layer calls, want one per layer, %d", len(calls), len(layers))
+	}
+	for i, l := range layers {
+		c := calls[i]
+		if _, _, _, rgb := parseColor(t, l.Color); c.name != l.Name || c.color != rgb {
+			t.Errorf("make_layer %d: %s (%s), want %s (%s)", i, c.name, c.color, l.Name, rgb)
+		}
+		// Transparency in percent
+		if transparency := strconv.Itoa(int(math.Round(l.Filter * 100))); c.arg != transparency {
+			t.Errorf("%s: transparency %s, want %s", l.Name, c.arg, transparency)
+		}
+		if l.Name != "Substrate" && (!near(c.zMin, l.Height) || !near(c.zMax, top(l))) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, c.zMin, c.zMax, l.Height, top(l))
+		}
+	}
+}
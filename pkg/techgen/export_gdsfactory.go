@@ -0,0 +1,33 @@
+// gdsfactory LayerStack YAML export, so photonic designers get the same
+// stack in gdsfactory 3D tooling as in GDS3D
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func writeGdsfactoryExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# gdsfactory LayerStack for %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "layers:\n")
+	for i, l := range stack.Layers {
+		if l.Thickness <= 0.0 {
+			continue
+		}
+		fmt.Fprintf(bw, "  %s:\n", strings.ToLower(xsIdentifier.ReplaceAllString(l.Name, "_")))
+		fmt.Fprintf(bw, "    layer: [%d, %d]\n", l.GDSNumber, l.GDSDatatype)
+		fmt.Fprintf(bw, "    thickness: %s\n", formatUm(l.Thickness))
+		fmt.Fprintf(bw, "    zmin: %s\n", formatUm(l.Height))
+		fmt.Fprintf(bw, "    material: %s\n", layerMaterial(l))
+		fmt.Fprintf(bw, "    sidewall_angle: 0\n")
+		fmt.Fprintf(bw, "    mesh_order: %d\n", i+1)
+		fmt.Fprintf(bw, "    info:\n")
+		fmt.Fprintf(bw, "      color: \"%s\"\n", l.Color)
+		fmt.Fprintf(bw, "      gds3d_name: \"%s\"\n", l.Name)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,63 @@
+package techgen_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGdsfactoryExport(t *testing.T) {
+	stack, data := fixtureExport(t, "gdsfactory", "sg13g2_gdsfactory.yaml")
+
+	// The fields of the layers by their key, "info" ones by info.<field>
+	layers := map[string]map[string]string{}
+	var keys []string
+	var cur map[string]string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		field, value, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch indent := len(line) - len(strings.TrimLeft(line, " ")); indent {
+		case 2:
+			cur = map[string]string{}
+			layers[field] = cur
+			keys = append(keys, field)
+		case 4:
+			cur[field] = strings.TrimSpace(value)
+		case 6:
+			cur["info."+field] = strings.TrimSpace(value)
+		}
+	}
+
+	drawn := drawnLayers(stack)
+	if len(keys) != len(drawn) {
+		t.Fatalf("%d layers, want %d", len(keys), len(drawn))
+	}
+	for i, l := range drawn {
+		if key := strings.ToLower(l.Name); keys[i] != key {
+			t.Errorf("layer %d: %s, want %s", i, keys[i], key)
+			continue
+		}
+		f := layers[keys[i]]
+		if name := strconv.Quote(l.Name); f["info.gds3d_name"] != name {
+			t.Errorf("%s: gds3d_name %s, want %s", keys[i], f["info.gds3d_name"], name)
+		}
+		if color := strconv.Quote(l.Color); f["info.color"] != color {
+			t.Errorf("%s: color %s, want %s", l.Name, f["info.color"], color)
+		}
+		if l.Name == "Substrate" {
+			continue
+		}
+		if layer := fmt.Sprintf("[%d, %d]", l.GDSLayer, l.GDSDatatype); f["layer"] != layer {
+			t.Errorf("%s: layer %s, want %s", l.Name, f["layer"], layer)
+		}
+		thickness, _ := strconv.ParseFloat(f["thickness"], 64)
+		zmin, _ := strconv.ParseFloat(f["zmin"], 64)
+		if !near(zmin, l.Height) || !near(thickness, l.Thickness) {
+			t.Errorf("%s: zmin %g, thickness %g, want %g, %g", l.Name, zmin, thickness, l.Height, l.Thickness)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// glTF 2.0 export of the 3D stack preview, as .gltf with an embedded
+// buffer or as binary .glb
+//
+// Each layer is a named node with its own material in the techfile color.
+// glTF is y-up, so the stack z axis becomes y. Units stay micron.
+
+package techgen
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+)
+
+type gltfDoc struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name string `json:"name"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   int            `json:"material"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor [4]float64 `json:"baseColorFactor"`
+	MetallicFactor  float64    `json:"metallicFactor"`
+	RoughnessFactor float64    `json:"roughnessFactor"`
+}
+
+type gltfMaterial struct {
+	Name                 string  `json:"name"`
+	PbrMetallicRoughness gltfPBR `json:"pbrMetallicRoughness"`
+	AlphaMode            string  `json:"alphaMode,omitempty"`
+	DoubleSided          bool    `json:"doubleSided"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri,omitempty"`
+}
+
+const (
+	gltfFloat        = 5126
+	gltfUnsignedInt  = 5125
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+)
+
+// Build the glTF document and its binary buffer
+func buildGLTF(LayerStack []Layer, margin float64) (*gltfDoc, []byte) {
+	doc := &gltfDoc{
+		Asset:  gltfAsset{Version: "2.0", Generator: "build_3d_techfile"},
+		Scenes: []gltfScene{{}},
+	}
+	var bin bytes.Buffer
+	addView := func(data any, target int) int {
+		offset := bin.Len()
+		binary.Write(&bin, binary.LittleEndian, data)
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{ByteOffset: offset, ByteLength: bin.Len() - offset, Target: target})
+		return len(doc.BufferViews) - 1
+	}
+
+	boxes := previewBoxes(LayerStack, margin)
+	for i, l := range LayerStack {
+		var positions, normals []float32
+		var indices []uint32
+		lo := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+		hi := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+		for _, b := range boxes {
+			if b.Layer != i {
+				continue
+			}
+			c := b.corners()
+			for _, f := range previewBoxFaces {
+				base := uint32(len(positions) / 3)
+				for _, k := range f.Corners {
+					p := toYUp(c[k])
+					for a := 0; a < 3; a++ {
+						lo[a] = math.Min(lo[a], p[a])
+						hi[a] = math.Max(hi[a], p[a])
+					}
+					positions = append(positions, float32(p[0]), float32(p[1]), float32(p[2]))
+					n := toYUp(f.Normal)
+					normals = append(normals, float32(n[0]), float32(n[1]), float32(n[2]))
+				}
+				indices = append(indices, base, base+1, base+2, base, base+2, base+3)
+			}
+		}
+		if len(indices) == 0 {
+			continue
+		}
+
+		count := len(positions) / 3
+		doc.Accessors = append(doc.Accessors,
+			gltfAccessor{BufferView: addView(positions, gltfArrayBuffer), ComponentType: gltfFloat, Count: count, Type: "VEC3", Min: lo, Max: hi},
+			gltfAccessor{BufferView: addView(normals, gltfArrayBuffer), ComponentType: gltfFloat, Count: count, Type: "VEC3"},
+			gltfAccessor{BufferView: addView(indices, gltfElementArray), ComponentType: gltfUnsignedInt, Count: len(indices), Type: "SCALAR"},
+		)
+		a := len(doc.Accessors) - 3
+
+		r, g, b := layerRGB(l)
+		mat := gltfMaterial{
+			Name:                 l.Name,
+			PbrMetallicRoughness: gltfPBR{BaseColorFactor: [4]float64{r, g, b, layerAlpha(l)}, MetallicFactor: 0.0, RoughnessFactor: 0.8},
+		}
+		if l.Metal == 1 {
+			mat.PbrMetallicRoughness.MetallicFactor = 0.7
+			mat.PbrMetallicRoughness.RoughnessFactor = 0.4
+		}
+		if layerAlpha(l) < 1.0 {
+			mat.AlphaMode = "BLEND"
+			mat.DoubleSided = true
+		}
+		doc.Materials = append(doc.Materials, mat)
+
+		doc.Meshes = append(doc.Meshes, gltfMesh{
+			Name: l.Name,
+			Primitives: []gltfPrimitive{{
+				Attributes: map[string]int{"POSITION": a, "NORMAL": a + 1},
+				Indices:    a + 2,
+				Material:   len(doc.Materials) - 1,
+			}},
+		})
+		doc.Nodes = append(doc.Nodes, gltfNode{Name: l.Name, Mesh: len(doc.Meshes) - 1})
+		doc.Scenes[0].Nodes = append(doc.Scenes[0].Nodes, len(doc.Nodes)-1)
+	}
+	doc.Buffers = []gltfBuffer{{ByteLength: bin.Len()}}
+	return doc, bin.Bytes()
+}
+
+func writeGLTFExport(w io.Writer, stack exportStack) error {
+	doc, bin := buildGLTF(stack.Layers, stack.SubstrateMargin)
+	doc.Buffers[0].URI = "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(bin)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(doc)
+}
+
+// GLB is a 12 byte header followed by a JSON and a BIN chunk, both padded
+// to 4 bytes
+func writeGLBExport(w io.Writer, stack exportStack) error {
+	doc, bin := buildGLTF(stack.Layers, stack.SubstrateMargin)
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+
+	total := 12 + 8 + len(js) + 8 + len(bin)
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, []uint32{0x46546C67, 2, uint32(total)})
+	binary.Write(&out, binary.LittleEndian, []uint32{uint32(len(js)), 0x4E4F534A})
+	out.Write(js)
+	binary.Write(&out, binary.LittleEndian, []uint32{uint32(len(bin)), 0x004E4942})
+	out.Write(bin)
+	_, err = w.Write(out.Bytes())
+	return err
+}
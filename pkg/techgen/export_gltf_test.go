@@ -0,0 +1,91 @@
+package techgen_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+)
+
+type gltfDoc struct {
+	Nodes []struct {
+		Name string `json:"name"`
+		Mesh int    `json:"mesh"`
+	} `json:"nodes"`
+	Meshes []struct {
+		Name       string `json:"name"`
+		Primitives []struct {
+			Attributes struct {
+				Position int `json:"POSITION"`
+			} `json:"attributes"`
+			Material int `json:"material"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Materials []struct {
+		PBR struct {
+			BaseColor [4]float64 `json:"baseColorFactor"`
+		} `json:"pbrMetallicRoughness"`
+	} `json:"materials"`
+	Accessors []struct {
+		Min []float64 `json:"min"`
+		Max []float64 `json:"max"`
+	} `json:"accessors"`
+}
+
+func TestGLTFExport(t *testing.T) {
+	stack, data := fixtureExport(t, "gltf", "sg13g2.gltf")
+	var doc gltfDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	checkGLTF(t, "gltf", stack, doc)
+}
+
+func TestGLBExport(t *testing.T) {
+	stack, data := fixtureExport(t, "glb", "sg13g2.glb")
+	// Header, then the JSON chunk: length, type and the document
+	if len(data) < 20 || string(data[:4]) != "glTF" || string(data[16:20]) != "JSON" {
+		t.Fatalf("glb starts with %q, want the glTF header and a JSON chunk", data[:min(len(data), 20)])
+	}
+	if n := binary.LittleEndian.Uint32(data[8:12]); int(n) != len(data) {
+		t.Errorf("glb header length %d, file has %d bytes", n, len(data))
+	}
+	chunk := binary.LittleEndian.Uint32(data[12:16])
+	var doc gltfDoc
+	if err := json.Unmarshal(bytes.TrimRight(data[20:20+chunk], " "), &doc); err != nil {
+		t.Fatal(err)
+	}
+	checkGLTF(t, "glb", stack, doc)
+}
+
+// A node and mesh per drawn layer, metals from their bottom to their top
+// on the y axis, which is up in glTF
+func checkGLTF(t *testing.T, name string, stack ir.Stack, doc gltfDoc) {
+	t.Helper()
+	layers := drawnLayers(stack)
+	if len(doc.Nodes) != len(layers) || len(doc.Meshes) != len(layers) {
+		t.Fatalf("%s: %d nodes and %d meshes, want one per layer, %d", name, len(doc.Nodes), len(doc.Meshes), len(layers))
+	}
+	for i, l := range layers {
+		node := doc.Nodes[i]
+		if node.Name != l.Name || doc.Meshes[node.Mesh].Name != l.Name {
+			t.Errorf("%s: node %d is %s with mesh %s, want %s", name, i, node.Name, doc.Meshes[node.Mesh].Name, l.Name)
+			continue
+		}
+		if !l.Metal {
+			continue
+		}
+		prim := doc.Meshes[node.Mesh].Primitives[0]
+		pos := doc.Accessors[prim.Attributes.Position]
+		if !near(pos.Min[1], l.Height) || !near(pos.Max[1], top(l)) {
+			t.Errorf("%s: %s from y %g to %g, want %g to %g", name, l.Name, pos.Min[1], pos.Max[1], l.Height, top(l))
+		}
+		r, g, b, _ := parseColor(t, l.Color)
+		c := doc.Materials[prim.Material].PBR.BaseColor
+		if !near3(c[:3], r, g, b) {
+			t.Errorf("%s: %s base color %v, want %s", name, l.Name, c, l.Color)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Single file HTML report of the resolved stack with the layer table,
+// provenance, warnings and the SVG cross-section, for attaching to PDK
+// merge requests as review evidence.
+
+package techgen
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} layer stack</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: left; }
+td.num { text-align: right; font-family: monospace; }
+.swatch { display: inline-block; width: 2em; height: 1em; border: 1px solid #000; vertical-align: middle; }
+.src { color: #666; font-size: 90%; }
+.warn { color: #a00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} layer stack</h1>
+<p>Generated {{.Date}}</p>
+<h2>Warnings</h2>
+{{if .Warnings}}<ul>{{range .Warnings}}<li class="warn">{{.Message}}</li>{{end}}</ul>{{else}}<p>None</p>{{end}}
+<h2>Layers</h2>
+<table>
+<tr><th>Color</th><th>Layer</th><th>GDS</th><th>z bottom (µm)</th><th>z top (µm)</th><th>Thickness (µm)</th><th>Metal</th><th>GDS/color from</th><th>z from</th></tr>
+{{range .Layers}}<tr>
+<td><span class="swatch" style="background: {{.Color}}"></span> {{.Color}}</td>
+<td>{{.Name}}</td>
+<td class="num">{{.GDS}}</td>
+<td class="num">{{.Bottom}}</td>
+<td class="num">{{.Top}}</td>
+<td class="num">{{.Thickness}}</td>
+<td>{{if .Metal}}yes{{end}}</td>
+<td class="src">{{.ColorSource}}</td>
+<td class="src">{{.ZSource}}</td>
+</tr>
+{{end}}</table>
+<h2>Cross-section</h2>
+{{.SVG}}
+</body>
+</html>
+`))
+
+type htmlReportLayer struct {
+	Name, GDS, Bottom, Top, Thickness string
+	Color                             template.CSS
+	Metal                             bool
+	ColorSource, ZSource              string
+}
+
+func writeHTMLExport(w io.Writer, stack exportStack) error {
+	var svg strings.Builder
+	if err := writeSVGCrossSection(&svg, stack.Layers); err != nil {
+		return err
+	}
+
+	data := struct {
+		Title, Date string
+		Warnings    []Warning
+		Layers      []htmlReportLayer
+		SVG         template.HTML
+	}{
+		Title:    stack.Stem,
+		Date:     stack.Time.Format("2006-01-02 15:04:05"),
+		Warnings: stack.Warnings,
+		SVG:      template.HTML(svg.String()),
+	}
+	for _, l := range stack.Layers {
+		data.Layers = append(data.Layers, htmlReportLayer{
+			Name:        l.Name,
+			GDS:         formatGDS(l),
+			Bottom:      formatUm(l.Height),
+			Top:         formatUm(layerTop(l)),
+			Thickness:   formatUm(l.Thickness),
+			Color:       template.CSS(l.Color),
+			Metal:       l.Metal == 1,
+			ColorSource: l.ColorSource,
+			ZSource:     l.ZSource,
+		})
+	}
+	return htmlReportTemplate.Execute(w, data)
+}
@@ -0,0 +1,26 @@
+package techgen_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHTMLExport(t *testing.T) {
+	stack, data := fixtureExport(t, "html", "sg13g2.html")
+	page := string(data)
+	// A row per layer after the header, the name in its second cell
+	rows := regexp.MustCompile(`(?s)<tr>\s*<td>.*?</td>\s*<td>([^<]*)</td>`).FindAllStringSubmatch(page, -1)
+	if len(rows) != len(stack.Layers) {
+		t.Fatalf("%d layer rows, want %d", len(rows), len(stack.Layers))
+	}
+	for i, row := range rows {
+		if row[1] != stack.Layers[i].Name {
+			t.Errorf("row %d is %s, want %s", i, row[1], stack.Layers[i].Name)
+		}
+	}
+	// The cross-section is inlined, not linked
+	if !strings.Contains(page, "<svg") {
+		t.Error("report without the cross-section SVG")
+	}
+}
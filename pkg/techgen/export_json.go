@@ -0,0 +1,24 @@
+// JSON export of the resolved layer stack for scripts, web viewers and
+// documentation generators
+//
+// The layers are those of the IR, with the same keys -export ir-json
+// writes and -from-ir reads, the issues of the run are added to them.
+
+package techgen
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+)
+
+func writeJSONExport(w io.Writer, stack exportStack) error {
+	doc := struct {
+		ir.Stack
+		Issues []stackIssue `json:"issues,omitempty"`
+	}{irStack(stack.Layers), stackIssues(stack.Warnings)}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
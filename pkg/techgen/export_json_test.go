@@ -0,0 +1,36 @@
+package techgen_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+)
+
+func TestJSONExport(t *testing.T) {
+	stack, data := fixtureExport(t, "json", "sg13g2.json")
+	var doc struct {
+		ir.Stack
+		Issues []json.RawMessage `json:"issues"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Check(); err != nil {
+		t.Fatal(err)
+	}
+	// The IR as written, but for the substrate on the GDS number GDS3D draws
+	if len(doc.Layers) != len(stack.Layers) {
+		t.Fatalf("%d layers, want %d", len(doc.Layers), len(stack.Layers))
+	}
+	for i, l := range doc.Layers {
+		want := stack.Layers[i]
+		if l.Name == "Substrate" {
+			want.GDSLayer, want.ColorSource = l.GDSLayer, l.ColorSource
+		}
+		if !reflect.DeepEqual(l, want) {
+			t.Errorf("layer %d = %+v, want %+v", i, l, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Color legend of the layers as SVG or PNG, in the colors GDS3D will show
+//
+// The techfile stores colors with two decimals, so the legend uses the same
+// rounded values. Layers with nearly identical colors are marked, they are
+// hard to tell apart in the 3D view.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+const legendSimilarColor = 0.06 // RGB distance below which colors are flagged
+
+// Color components as written to the techfile
+func techFileRGB(layer Layer) (float64, float64, float64) {
+	r, g, b := layerRGB(layer)
+	round := func(c float64) float64 { return math.Round(c*100) / 100 }
+	return round(r), round(g), round(b)
+}
+
+type legendEntry struct {
+	Name    string
+	GDS     string
+	R, G, B float64
+	Similar []string
+}
+
+func legendEntries(LayerStack []Layer) []legendEntry {
+	var entries []legendEntry
+	for _, l := range LayerStack {
+		r, g, b := techFileRGB(l)
+		entries = append(entries, legendEntry{Name: l.Name, GDS: formatGDS(l), R: r, G: g, B: b})
+	}
+	for i := range entries {
+		for j := range entries {
+			a, b := entries[i], entries[j]
+			d := math.Sqrt((a.R-b.R)*(a.R-b.R) + (a.G-b.G)*(a.G-b.G) + (a.B-b.B)*(a.B-b.B))
+			if i != j && d < legendSimilarColor {
+				entries[i].Similar = append(entries[i].Similar, b.Name)
+			}
+		}
+	}
+	return entries
+}
+
+func (e legendEntry) label() string {
+	text := fmt.Sprintf("%s  %s  %.2f %.2f %.2f", e.Name, e.GDS, e.R, e.G, e.B)
+	if len(e.Similar) > 0 {
+		text += "  ~ " + strings.Join(e.Similar, ", ")
+	}
+	return text
+}
+
+func (e legendEntry) rgba() color.RGBA {
+	return color.RGBA{uint8(math.Round(e.R * 255)), uint8(math.Round(e.G * 255)), uint8(math.Round(e.B * 255)), 255}
+}
+
+const (
+	legendRow    = 24
+	legendSwatch = 40
+	legendMargin = 10
+)
+
+func writeLegendSVGExport(w io.Writer, stack exportStack) error {
+	entries := legendEntries(stack.Layers)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"520\" height=\"%d\" font-family=\"sans-serif\" font-size=\"12\">\n", len(entries)*legendRow+2*legendMargin)
+	fmt.Fprintf(bw, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	for i, e := range entries {
+		y := legendMargin + i*legendRow
+		c := e.rgba()
+		fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\" stroke=\"black\"/>\n", legendMargin, y+3, legendSwatch, legendRow-6, c.R, c.G, c.B)
+		fill := "black"
+		if len(e.Similar) > 0 {
+			fill = "#a00"
+		}
+		fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\" fill=\"%s\">%s</text>\n", 2*legendMargin+legendSwatch, y+legendRow/2+4, fill, html.EscapeString(e.label()))
+	}
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
+
+func writeLegendPNGExport(w io.Writer, stack exportStack) error {
+	entries := legendEntries(stack.Layers)
+	const scale = 2 // font pixel size
+	width := 0
+	for _, e := range entries {
+		width = max(width, len(e.label())*6*scale)
+	}
+	width += 3*legendMargin + legendSwatch
+	height := len(entries)*legendRow + 2*legendMargin
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(img, 0, 0, width, height, color.RGBA{255, 255, 255, 255})
+	black := color.RGBA{0, 0, 0, 255}
+	red := color.RGBA{170, 0, 0, 255}
+	for i, e := range entries {
+		y := legendMargin + i*legendRow
+		fillRect(img, legendMargin, y+3, legendSwatch, legendRow-6, black)
+		fillRect(img, legendMargin+1, y+4, legendSwatch-2, legendRow-8, e.rgba())
+		ink := black
+		if len(e.Similar) > 0 {
+			ink = red
+		}
+		drawText(img, 2*legendMargin+legendSwatch, y+(legendRow-7*scale)/2, scale, e.label(), ink)
+	}
+	return png.Encode(w, img)
+}
+
+func fillRect(img *image.RGBA, x, y, w, h int, c color.RGBA) {
+	for yy := y; yy < y+h; yy++ {
+		for xx := x; xx < x+w; xx++ {
+			img.SetRGBA(xx, yy, c)
+		}
+	}
+}
+
+// Upper case 5x7 bitmap font, one byte per row with bit 4 the left column.
+// Lower case letters are drawn as upper case, unknown runes as blanks.
+var legendFont = map[rune][7]uint8{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	',': {0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'_': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+	'~': {0x00, 0x00, 0x08, 0x15, 0x02, 0x00, 0x00},
+}
+
+func drawText(img *image.RGBA, x, y, scale int, text string, c color.RGBA) {
+	for _, r := range strings.ToUpper(text) {
+		glyph := legendFont[r]
+		for row, bits := range glyph {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>col) != 0 {
+					fillRect(img, x+col*scale, y+row*scale, scale, scale, c)
+				}
+			}
+		}
+		x += 6 * scale
+	}
+}
@@ -0,0 +1,71 @@
+package techgen_test
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Channels of #rrggbb
+func rgb8(t *testing.T, color string) [3]int {
+	t.Helper()
+	var c [3]int
+	if _, err := fmt.Sscanf(color, "#%02x%02x%02x", &c[0], &c[1], &c[2]); err != nil {
+		t.Fatalf("color %q: %v", color, err)
+	}
+	return c
+}
+
+func TestLegendExport(t *testing.T) {
+	stack, svg := fixtureExport(t, "legend-svg", "sg13g2_legend.svg")
+	_, pngData := fixtureExport(t, "legend-png", "sg13g2_legend.png")
+
+	// A swatch and a label per layer
+	entry := regexp.MustCompile(`<rect x="\d+" y="(\d+)" width="\d+" height="\d+" fill="(#[0-9a-f]{6})" stroke="black"/>\n<text [^>]* fill="([^"]+)">([^<]*)</text>`)
+	entries := entry.FindAllStringSubmatch(string(svg), -1)
+	if len(entries) != len(stack.Layers) {
+		t.Fatalf("%d legend entries, want one per layer, %d", len(entries), len(stack.Layers))
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`<svg [^>]* height="(\d+)"`).FindSubmatch(svg)
+	if height, _ := strconv.Atoi(string(m[1])); img.Bounds().Dy() != height {
+		t.Errorf("png %d pixels high, svg %d", img.Bounds().Dy(), height)
+	}
+
+	for i, l := range stack.Layers {
+		e := entries[i]
+		if !strings.HasPrefix(e[4], l.Name+"  ") {
+			t.Errorf("legend entry %d: %q, want %s", i, e[4], l.Name)
+		}
+		// The color as the techfile writes it, two decimals a channel
+		want, got := rgb8(t, l.Color), rgb8(t, e[2])
+		for c := range want {
+			if d := got[c] - want[c]; d < -2 || d > 2 {
+				t.Errorf("%s: swatch %s, want %s", l.Name, e[2], l.Color)
+				break
+			}
+		}
+		// Layers of the same color are flagged
+		similar := false
+		for j, o := range stack.Layers {
+			similar = similar || (j != i && o.Color == l.Color)
+		}
+		if flagged := e[3] != "black"; similar && !flagged {
+			t.Errorf("%s: %s shared with another layer, not flagged", l.Name, l.Color)
+		}
+
+		// The png draws the same swatch in the middle of its row
+		y, _ := strconv.Atoi(e[1])
+		r, g, b, _ := img.At(30, y+9).RGBA()
+		if px := fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8); px != e[2] {
+			t.Errorf("%s: png swatch %s, svg %s", l.Name, px, e[2])
+		}
+	}
+}
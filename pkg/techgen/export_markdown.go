@@ -0,0 +1,19 @@
+// Markdown stackup table for PDK documentation and READMEs
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+func writeMarkdownExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "| Layer | GDS | z bottom (µm) | z top (µm) | Thickness (µm) | Color |\n")
+	fmt.Fprintf(bw, "|---|---|---:|---:|---:|---|\n")
+	for _, l := range stack.Layers {
+		fmt.Fprintf(bw, "| %s | %s | %s | %s | %s | `%s` |\n", l.Name, formatGDS(l), formatUm(l.Height), formatUm(layerTop(l)), formatUm(l.Thickness), l.Color)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,30 @@
+package techgen_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownExport(t *testing.T) {
+	stack, data := fixtureExport(t, "markdown", "sg13g2.md")
+	var rows [][]string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "| ") {
+			cells := strings.Split(strings.Trim(line, "|"), "|")
+			for i := range cells {
+				cells[i] = strings.TrimSpace(cells[i])
+			}
+			rows = append(rows, cells)
+		}
+	}
+	// The header, the layers, and nothing else in the table
+	if len(rows) != len(stack.Layers)+1 || rows[0][0] != "Layer" {
+		t.Fatalf("%d table rows starting with %v, want a header and %d layers", len(rows), rows[0], len(stack.Layers))
+	}
+	for i, row := range rows[1:] {
+		l := stack.Layers[i]
+		if row[0] != l.Name || row[5] != "`"+l.Color+"`" {
+			t.Errorf("row %v, want %s in %s", row, l.Name, l.Color)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Wavefront OBJ/MTL export of the 3D stack preview
+//
+// Every layer is an object group with its own material, so layers can be
+// toggled individually in the viewer. Like glTF the stack z axis becomes y.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func objName(layer Layer) string {
+	return strings.ReplaceAll(layer.Name, " ", "_")
+}
+
+func writeOBJExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Layer stack preview generated by build_3d_techfile, units micron\n")
+	fmt.Fprintf(bw, "mtllib %s.mtl\n", stack.Stem)
+	for _, f := range previewBoxFaces {
+		n := toYUp(f.Normal)
+		fmt.Fprintf(bw, "vn %g %g %g\n", n[0], n[1], n[2])
+	}
+
+	boxes := previewBoxes(stack.Layers, stack.SubstrateMargin)
+	vertices := 0
+	for i, l := range stack.Layers {
+		first := true
+		for _, b := range boxes {
+			if b.Layer != i {
+				continue
+			}
+			if first {
+				fmt.Fprintf(bw, "o %s\ng %s\nusemtl %s\n", objName(l), objName(l), objName(l))
+				first = false
+			}
+			c := b.corners()
+			for _, p := range c {
+				p = toYUp(p)
+				fmt.Fprintf(bw, "v %g %g %g\n", p[0], p[1], p[2])
+			}
+			for n, f := range previewBoxFaces {
+				fmt.Fprintf(bw, "f")
+				for _, k := range f.Corners {
+					fmt.Fprintf(bw, " %d//%d", vertices+k+1, n+1)
+				}
+				fmt.Fprintf(bw, "\n")
+			}
+			vertices += len(c)
+		}
+	}
+	return bw.Flush()
+}
+
+func writeMTLExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	for _, l := range stack.Layers {
+		r, g, b := layerRGB(l)
+		fmt.Fprintf(bw, "newmtl %s\n", objName(l))
+		fmt.Fprintf(bw, "Ka %.3f %.3f %.3f\n", r*0.2, g*0.2, b*0.2)
+		fmt.Fprintf(bw, "Kd %.3f %.3f %.3f\n", r, g, b)
+		if l.Metal == 1 {
+			fmt.Fprintf(bw, "Ks 0.500 0.500 0.500\nNs 50\n")
+		} else {
+			fmt.Fprintf(bw, "Ks 0.000 0.000 0.000\n")
+		}
+		fmt.Fprintf(bw, "d %.3f\nillum 2\n\n", layerAlpha(l))
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,85 @@
+package techgen_test
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/techgen/techgentest"
+)
+
+func TestOBJExport(t *testing.T) {
+	out := techgentest.Fixtures[0].Output(t, "obj")
+	stack, obj, mtl := out.Stack, out.Exports["sg13g2.obj"], out.Exports["sg13g2.mtl"]
+	if obj == nil || mtl == nil {
+		t.Fatal("-export obj without the obj and its mtl")
+	}
+
+	// Objects by name with their material and extent on the y axis, up
+	type object struct {
+		material   string
+		yMin, yMax float64
+	}
+	objects := map[string]*object{}
+	var names []string
+	var cur *object
+	sc := bufio.NewScanner(bytes.NewReader(obj))
+	mtllib := ""
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		switch {
+		case len(f) == 2 && f[0] == "mtllib":
+			mtllib = f[1]
+		case len(f) == 2 && f[0] == "o":
+			cur = &object{yMin: math.Inf(1), yMax: math.Inf(-1)}
+			objects[f[1]] = cur
+			names = append(names, f[1])
+		case len(f) == 2 && f[0] == "usemtl" && cur != nil:
+			cur.material = f[1]
+		case len(f) == 4 && f[0] == "v" && cur != nil:
+			y, err := strconv.ParseFloat(f[2], 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur.yMin, cur.yMax = min(cur.yMin, y), max(cur.yMax, y)
+		}
+	}
+	if mtllib != "sg13g2.mtl" {
+		t.Errorf("mtllib %q, want the companion sg13g2.mtl", mtllib)
+	}
+
+	// Materials by name with their diffuse color
+	kd := map[string]string{}
+	material := ""
+	sc = bufio.NewScanner(bytes.NewReader(mtl))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		switch {
+		case len(f) == 2 && f[0] == "newmtl":
+			material = f[1]
+		case len(f) == 4 && f[0] == "Kd":
+			kd[material] = strings.Join(f[1:], ", ")
+		}
+	}
+
+	layers := drawnLayers(stack)
+	if len(names) != len(layers) {
+		t.Fatalf("%d objects, want one per layer, %d", len(names), len(layers))
+	}
+	for i, l := range layers {
+		o := objects[l.Name]
+		if names[i] != l.Name || o.material != l.Name {
+			t.Errorf("object %d is %s with material %s, want %s", i, names[i], o.material, l.Name)
+			continue
+		}
+		if _, _, _, color := parseColor(t, l.Color); kd[l.Name] != color {
+			t.Errorf("material %s Kd %s, want %s", l.Name, kd[l.Name], color)
+		}
+		if l.Metal && (!near(o.yMin, l.Height) || !near(o.yMax, top(l))) {
+			t.Errorf("%s from y %g to %g, want %g to %g", l.Name, o.yMin, o.yMax, l.Height, top(l))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// openEMS/CSXCAD python snippet with the substrate and metal stack, so EM
+// simulations start from the stack shown in GDS3D
+//
+// Only the substrate, oxide and conductor layers matter for EM, wells and
+// implants are left out.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+const openEMSFunctions = `
+
+def add_stack(CSX, x0, y0, x1, y1, oxide_epsr=OXIDE_EPSR):
+    """Add the substrate and a homogeneous oxide over [x0, x1] x [y0, y1] and
+    create one material per conductor layer. Add the layout shapes of a
+    conductor with props[name].AddBox()/AddPolygon() between its z values.
+    Coordinates are micron, use CSX.GetGrid().SetDeltaUnit(1e-6)."""
+    props = {}
+    oxide = CSX.AddMaterial("Oxide", epsilon=oxide_epsr)
+    oxide.AddBox([x0, y0, 0], [x1, y1, OXIDE_TOP], priority=1)
+    for name, zmin, zmax, kind, kappa, epsr in STACK:
+        if kind == "substrate":
+            prop = CSX.AddMaterial(name, epsilon=epsr, kappa=kappa)
+            prop.AddBox([x0, y0, zmin], [x1, y1, zmax], priority=2)
+        else:
+            prop = CSX.AddMaterial(name, kappa=kappa)
+        props[name] = prop
+    return props
+`
+
+func writeOpenEMSExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# openEMS/CSXCAD stack of %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "# Conductivities and permittivities are placeholders, check them\n")
+	fmt.Fprintf(bw, "# against the foundry documentation.\n\n")
+
+	top := 0.0
+	fmt.Fprintf(bw, "# name, zmin, zmax, kind, conductivity S/m, relative permittivity\n")
+	fmt.Fprintf(bw, "STACK = [\n")
+	for _, l := range stack.Layers {
+		if l.Thickness <= 0.0 {
+			continue
+		}
+		kind := ""
+		switch {
+		case l.Name == "Substrate":
+			kind = "substrate"
+		case l.Metal == 1 || isViaLayer(l):
+			kind = "conductor"
+			top = math.Max(top, layerTop(l))
+		default:
+			continue
+		}
+		props := materialProperties[layerMaterial(l)]
+		fmt.Fprintf(bw, "    (%q, %s, %s, %q, %g, %g),\n", l.Name, formatUm(l.Height), formatUm(layerTop(l)), kind, props.Conductivity, props.Permittivity)
+	}
+	fmt.Fprintf(bw, "]\n\n")
+	fmt.Fprintf(bw, "OXIDE_EPSR = %g\n", materialProperties["sio2"].Permittivity)
+	fmt.Fprintf(bw, "OXIDE_TOP = %s\n", formatUm(top))
+	fmt.Fprint(bw, openEMSFunctions)
+	return bw.Flush()
+}
@@ -0,0 +1,64 @@
+package techgen_test
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestOpenEMSExport(t *testing.T) {
+	stack, data := fixtureExport(t, "openems", "sg13g2_openems.py")
+	entry := regexp.MustCompile(`(?m)^    \("([^"]+)", (\S+), (\S+), "(\w+)", (\S+), (\S+)\),$`)
+	type material struct {
+		zMin, zMax, conductivity float64
+		kind                     string
+	}
+	materials := map[string]material{}
+	for _, m := range entry.FindAllStringSubmatch(string(data), -1) {
+		var v [4]float64
+		for i, s := range []string{m[2], m[3], m[5], m[6]} {
+			var err error
+			if v[i], err = strconv.ParseFloat(s, 64); err != nil {
+				t.Fatalf("%s: %v", m[0], err)
+			}
+		}
+		materials[m[1]] = material{v[0], v[1], v[2], m[4]}
+	}
+
+	oxideTop := 0.0
+	for _, l := range drawnLayers(stack) {
+		m, ok := materials[l.Name]
+		switch {
+		case l.Name == "Substrate":
+			if m.kind != "substrate" {
+				t.Errorf("substrate of kind %q", m.kind)
+			}
+			continue
+		case l.Metal:
+			if !ok || m.kind != "conductor" || m.conductivity <= 0.0 {
+				t.Errorf("metal %s as %+v, want a conductor", l.Name, m)
+				continue
+			}
+		case l.Name == "NWell" || l.Name == "PWell" || l.Name == "Active":
+			if ok {
+				t.Errorf("%s in the EM stack", l.Name)
+			}
+		}
+		if !ok {
+			continue
+		}
+		if !near(m.zMin, l.Height) || !near(m.zMax, top(l)) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, m.zMin, m.zMax, l.Height, top(l))
+		}
+		oxideTop = max(oxideTop, m.zMax)
+	}
+
+	// The oxide covers all conductors
+	m := regexp.MustCompile(`(?m)^OXIDE_TOP = (\S+)$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no OXIDE_TOP")
+	}
+	if top, _ := strconv.ParseFloat(string(m[1]), 64); !near(top, oxideTop) {
+		t.Errorf("OXIDE_TOP = %g, want the top of the conductors, %g", top, oxideTop)
+	}
+}
@@ -0,0 +1,59 @@
+// Cadence Virtuoso SKILL script with the layer heights of the stack, so the
+// Virtuoso cross-section and 3D views can be set up from the same data as
+// GDS3D
+//
+// Load with: load("sg13g2_stack.il"). The script defines the stack as a
+// list and procedures to look a layer up and to store the heights as
+// technology file parameters.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+func writeSKILLExport(w io.Writer, stack exportStack) error {
+	prefix := xsIdentifier.ReplaceAllString(stack.Stem, "_")
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "; Layer stack of %s for Virtuoso, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "; Load with: load(\"%s_stack.il\")\n", stack.Stem)
+	fmt.Fprintf(bw, "; Heights are micron, entries are\n")
+	fmt.Fprintf(bw, "; (layer purpose zBottom zTop thickness gdsLayer gdsDatatype color metal)\n\n")
+
+	fmt.Fprintf(bw, "%sStack = list(\n", prefix)
+	for _, l := range stack.Layers {
+		if l.Thickness <= 0.0 {
+			continue
+		}
+		metal := "nil"
+		if l.Metal == 1 {
+			metal = "t"
+		}
+		fmt.Fprintf(bw, "  list(%q \"drawing\" %s %s %s %d %d %q %s)\n", l.Name,
+			skillFloat(l.Height), skillFloat(layerTop(l)), skillFloat(l.Thickness),
+			l.GDSNumber, l.GDSDatatype, l.Color, metal)
+	}
+	fmt.Fprintf(bw, ")\n\n")
+
+	fmt.Fprintf(bw, "; Stack entry of a layer, nil if the layer is not in the stack\n")
+	fmt.Fprintf(bw, "procedure(%sStackLayer(layer)\n", prefix)
+	fmt.Fprintf(bw, "  assoc(layer %sStack)\n", prefix)
+	fmt.Fprintf(bw, ")\n\n")
+
+	fmt.Fprintf(bw, "; Store <layer>_zBottom and <layer>_thickness as technology parameters\n")
+	fmt.Fprintf(bw, "procedure(%sStackApply(techFile)\n", prefix)
+	fmt.Fprintf(bw, "  foreach(entry %sStack\n", prefix)
+	fmt.Fprintf(bw, "    techSetParam(techFile strcat(car(entry) \"_zBottom\") nth(2 entry))\n")
+	fmt.Fprintf(bw, "    techSetParam(techFile strcat(car(entry) \"_thickness\") nth(4 entry))\n")
+	fmt.Fprintf(bw, "  )\n")
+	fmt.Fprintf(bw, "  t\n")
+	fmt.Fprintf(bw, ")\n")
+	return bw.Flush()
+}
+
+// SKILL reads numbers without a decimal point as integers
+func skillFloat(v float64) string {
+	return formatDecimal(roundUm(v))
+}
@@ -0,0 +1,38 @@
+package techgen_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestSKILLExport(t *testing.T) {
+	stack, data := fixtureExport(t, "skill", "sg13g2_stack.il")
+	entry := regexp.MustCompile(`(?m)^  list\("([^"]+)" "drawing" (\S+) (\S+) (\S+) (\d+) (\d+) "(#[0-9a-f]{6})" (t|nil)\)$`)
+	entries := entry.FindAllStringSubmatch(string(data), -1)
+	layers := drawnLayers(stack)
+	if len(entries) != len(layers) {
+		t.Fatalf("%d entries, want one per layer, %d", len(entries), len(layers))
+	}
+	for i, l := range layers {
+		e := entries[i]
+		metal := "nil"
+		if l.Metal {
+			metal = "t"
+		}
+		if e[1] != l.Name || e[7] != l.Color || e[8] != metal {
+			t.Errorf("entry %d: %s %s metal %s, want %s %s %s", i, e[1], e[7], e[8], l.Name, l.Color, metal)
+		}
+		var z [3]float64
+		for k := range z {
+			z[k], _ = strconv.ParseFloat(e[2+k], 64)
+		}
+		if !near(z[0], l.Height) || !near(z[1], top(l)) || !near(z[2], l.Thickness) {
+			t.Errorf("%s: %v, want %g %g %g", l.Name, z, l.Height, top(l), l.Thickness)
+		}
+		if gds := fmt.Sprintf("%d/%d", l.GDSLayer, l.GDSDatatype); l.Name != "Substrate" && e[5]+"/"+e[6] != gds {
+			t.Errorf("%s on %s/%s, want %s", l.Name, e[5], e[6], gds)
+		}
+	}
+}
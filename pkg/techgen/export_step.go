@@ -0,0 +1,156 @@
+// STEP (AP214) export of the 3D stack preview for mechanical CAD
+//
+// Every preview box is a colored manifold solid named after its layer, so
+// CAD tools list the stack per layer. Via pillars of one layer are separate
+// solids with the same name. Units are millimeter.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const stepScale = 0.001 // mm per micron
+
+type stepWriter struct {
+	lines []string
+}
+
+func (s *stepWriter) add(format string, args ...any) int {
+	s.lines = append(s.lines, fmt.Sprintf(format, args...))
+	return len(s.lines)
+}
+
+func (s *stepWriter) point(p [3]float64) int {
+	return s.add("CARTESIAN_POINT('',(%s,%s,%s))", stepReal(p[0]*stepScale), stepReal(p[1]*stepScale), stepReal(p[2]*stepScale))
+}
+
+func (s *stepWriter) direction(d [3]float64) int {
+	return s.add("DIRECTION('',(%s,%s,%s))", stepReal(d[0]), stepReal(d[1]), stepReal(d[2]))
+}
+
+// STEP reals always need a decimal point
+func stepReal(v float64) string {
+	str := fmt.Sprintf("%.9g", v+0)
+	if !strings.ContainsAny(str, ".e") {
+		str += "."
+	}
+	return str
+}
+
+func stepRef(ids []int) string {
+	var refs []string
+	for _, id := range ids {
+		refs = append(refs, fmt.Sprintf("#%d", id))
+	}
+	return strings.Join(refs, ",")
+}
+
+func stepString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// Manifold solid of one box, returns the solid id
+func (s *stepWriter) box(name string, b previewBox) int {
+	c := b.corners()
+	var vertices [8]int
+	for i, p := range c {
+		vertices[i] = s.add("VERTEX_POINT('',#%d)", s.point(p))
+	}
+
+	// The 12 edges join corners that differ in one coordinate bit
+	type edgeKey struct{ from, to int }
+	edges := map[edgeKey]int{}
+	for i := 0; i < 8; i++ {
+		for bit := 1; bit < 8; bit <<= 1 {
+			if i&bit != 0 {
+				continue
+			}
+			j := i | bit
+			d := [3]float64{c[j][0] - c[i][0], c[j][1] - c[i][1], c[j][2] - c[i][2]}
+			length := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+			dir := s.direction([3]float64{d[0] / length, d[1] / length, d[2] / length})
+			vector := s.add("VECTOR('',#%d,%s)", dir, stepReal(length*stepScale))
+			line := s.add("LINE('',#%d,#%d)", s.point(c[i]), vector)
+			edges[edgeKey{i, j}] = s.add("EDGE_CURVE('',#%d,#%d,#%d,.T.)", vertices[i], vertices[j], line)
+		}
+	}
+
+	var faces []int
+	for _, f := range previewBoxFaces {
+		var oriented []int
+		for k := 0; k < 4; k++ {
+			from, to := f.Corners[k], f.Corners[(k+1)%4]
+			if e, ok := edges[edgeKey{from, to}]; ok {
+				oriented = append(oriented, s.add("ORIENTED_EDGE('',*,*,#%d,.T.)", e))
+			} else {
+				oriented = append(oriented, s.add("ORIENTED_EDGE('',*,*,#%d,.F.)", edges[edgeKey{to, from}]))
+			}
+		}
+		loop := s.add("EDGE_LOOP('',(%s))", stepRef(oriented))
+		bound := s.add("FACE_OUTER_BOUND('',#%d,.T.)", loop)
+		a, b := c[f.Corners[0]], c[f.Corners[1]]
+		ref := [3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+		length := math.Sqrt(ref[0]*ref[0] + ref[1]*ref[1] + ref[2]*ref[2])
+		placement := s.add("AXIS2_PLACEMENT_3D('',#%d,#%d,#%d)", s.point(a), s.direction(f.Normal), s.direction([3]float64{ref[0] / length, ref[1] / length, ref[2] / length}))
+		plane := s.add("PLANE('',#%d)", placement)
+		faces = append(faces, s.add("ADVANCED_FACE('',(#%d),#%d,.T.)", bound, plane))
+	}
+	shell := s.add("CLOSED_SHELL('',(%s))", stepRef(faces))
+	return s.add("MANIFOLD_SOLID_BREP('%s',#%d)", stepString(name), shell)
+}
+
+func writeSTEPExport(w io.Writer, stack exportStack) error {
+	s := &stepWriter{}
+
+	appContext := s.add("APPLICATION_CONTEXT('core data for automotive mechanical design processes')")
+	s.add("APPLICATION_PROTOCOL_DEFINITION('international standard','automotive_design',2000,#%d)", appContext)
+	productContext := s.add("PRODUCT_CONTEXT('',#%d,'mechanical')", appContext)
+	product := s.add("PRODUCT('%s','%s','layer stack preview',(#%d))", stepString(stack.Stem), stepString(stack.Stem), productContext)
+	formation := s.add("PRODUCT_DEFINITION_FORMATION('','',#%d)", product)
+	defContext := s.add("PRODUCT_DEFINITION_CONTEXT('part definition',#%d,'design')", appContext)
+	definition := s.add("PRODUCT_DEFINITION('design','',#%d,#%d)", formation, defContext)
+	shapeDef := s.add("PRODUCT_DEFINITION_SHAPE('','',#%d)", definition)
+
+	lengthUnit := s.add("(LENGTH_UNIT() NAMED_UNIT(*) SI_UNIT(.MILLI.,.METRE.))")
+	angleUnit := s.add("(NAMED_UNIT(*) PLANE_ANGLE_UNIT() SI_UNIT($,.RADIAN.))")
+	solidUnit := s.add("(NAMED_UNIT(*) SI_UNIT($,.STERADIAN.) SOLID_ANGLE_UNIT())")
+	uncertainty := s.add("UNCERTAINTY_MEASURE_WITH_UNIT(LENGTH_MEASURE(1.E-07),#%d,'distance_accuracy_value','confusion accuracy')", lengthUnit)
+	context := s.add("(GEOMETRIC_REPRESENTATION_CONTEXT(3) GLOBAL_UNCERTAINTY_ASSIGNED_CONTEXT((#%d)) GLOBAL_UNIT_ASSIGNED_CONTEXT((#%d,#%d,#%d)) REPRESENTATION_CONTEXT('',''))",
+		uncertainty, lengthUnit, angleUnit, solidUnit)
+
+	origin := s.add("AXIS2_PLACEMENT_3D('',#%d,#%d,#%d)", s.point([3]float64{}), s.direction([3]float64{0, 0, 1}), s.direction([3]float64{1, 0, 0}))
+	items := []int{origin}
+	var styled []int
+	for _, b := range previewBoxes(stack.Layers, stack.SubstrateMargin) {
+		l := stack.Layers[b.Layer]
+		solid := s.box(l.Name, b)
+		items = append(items, solid)
+
+		r, g, bl := layerRGB(l)
+		colour := s.add("COLOUR_RGB('',%s,%s,%s)", stepReal(r), stepReal(g), stepReal(bl))
+		fill := s.add("FILL_AREA_STYLE('',(#%d))", s.add("FILL_AREA_STYLE_COLOUR('',#%d)", colour))
+		side := s.add("SURFACE_SIDE_STYLE('',(#%d))", s.add("SURFACE_STYLE_FILL_AREA(#%d)", fill))
+		usage := s.add("SURFACE_STYLE_USAGE(.BOTH.,#%d)", side)
+		assignment := s.add("PRESENTATION_STYLE_ASSIGNMENT((#%d))", usage)
+		styled = append(styled, s.add("STYLED_ITEM('color',(#%d),#%d)", assignment, solid))
+	}
+	shapeRep := s.add("ADVANCED_BREP_SHAPE_REPRESENTATION('%s',(%s),#%d)", stepString(stack.Stem), stepRef(items), context)
+	s.add("SHAPE_DEFINITION_REPRESENTATION(#%d,#%d)", shapeDef, shapeRep)
+	s.add("MECHANICAL_DESIGN_GEOMETRIC_PRESENTATION_REPRESENTATION('',(%s),#%d)", stepRef(styled), context)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ISO-10303-21;\nHEADER;\n")
+	fmt.Fprintf(bw, "FILE_DESCRIPTION(('layer stack preview'),'2;1');\n")
+	fmt.Fprintf(bw, "FILE_NAME('%s.step','%s',(''),(''),'build_3d_techfile','build_3d_techfile','');\n", stepString(stack.Stem), stack.Time.Format("2006-01-02T15:04:05"))
+	fmt.Fprintf(bw, "FILE_SCHEMA(('AUTOMOTIVE_DESIGN { 1 0 10303 214 1 1 1 1 }'));\nENDSEC;\nDATA;\n")
+	for i, line := range s.lines {
+		fmt.Fprintf(bw, "#%d=%s;\n", i+1, line)
+	}
+	fmt.Fprintf(bw, "ENDSEC;\nEND-ISO-10303-21;\n")
+	return bw.Flush()
+}
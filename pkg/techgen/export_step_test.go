@@ -0,0 +1,84 @@
+package techgen_test
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSTEPExport(t *testing.T) {
+	stack, data := fixtureExport(t, "step", "sg13g2.step")
+	if !bytes.HasPrefix(data, []byte("ISO-10303-21;")) || !bytes.Contains(data, []byte("END-ISO-10303-21;")) {
+		t.Fatal("not a STEP file")
+	}
+
+	// The points of a solid come before it, its color right after
+	type solid struct {
+		zMin, zMax float64
+		color      []float64
+	}
+	point := regexp.MustCompile(`^#\d+=CARTESIAN_POINT\('',\(([^)]*)\)\);$`)
+	brep := regexp.MustCompile(`^#\d+=MANIFOLD_SOLID_BREP\('([^']+)',#\d+\);$`)
+	colour := regexp.MustCompile(`^#\d+=COLOUR_RGB\('',([^)]*)\);$`)
+	solids := map[string]*solid{}
+	count := 0
+	zMin, zMax := math.Inf(1), math.Inf(-1)
+	var last *solid
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if m := point.FindStringSubmatch(line); m != nil {
+			xyz := strings.Split(m[1], ",")
+			z, err := strconv.ParseFloat(xyz[len(xyz)-1], 64)
+			if err != nil {
+				t.Fatalf("%s: %v", line, err)
+			}
+			zMin, zMax = min(zMin, z), max(zMax, z)
+		} else if m := brep.FindStringSubmatch(line); m != nil {
+			count++
+			last = solids[m[1]]
+			if last == nil {
+				last = &solid{zMin: math.Inf(1), zMax: math.Inf(-1)}
+				solids[m[1]] = last
+			}
+			last.zMin, last.zMax = min(last.zMin, zMin), max(last.zMax, zMax)
+			zMin, zMax = math.Inf(1), math.Inf(-1)
+		} else if m := colour.FindStringSubmatch(line); m != nil && last != nil {
+			last.color = nil
+			for _, f := range strings.Split(m[1], ",") {
+				c, err := strconv.ParseFloat(f, 64)
+				if err != nil {
+					t.Fatalf("%s: %v", line, err)
+				}
+				last.color = append(last.color, c)
+			}
+			last = nil
+		}
+	}
+
+	layers := drawnLayers(stack)
+	if len(solids) != len(layers) {
+		t.Errorf("solids of %d layers, want %d", len(solids), len(layers))
+	}
+	if count < len(layers) {
+		t.Errorf("%d solids, want at least one per layer", count)
+	}
+	for _, l := range layers {
+		s, ok := solids[l.Name]
+		if !ok {
+			t.Errorf("no solid of %s", l.Name)
+			continue
+		}
+		if r, g, b, _ := parseColor(t, l.Color); !near3(s.color, r, g, b) {
+			t.Errorf("%s colored %v, want %s", l.Name, s.color, l.Color)
+		}
+		// Millimeter
+		if l.Name != "Substrate" && (!near(s.zMin*1000, l.Height) || !near(s.zMax*1000, top(l))) {
+			t.Errorf("%s from z %g to %g mm, want %g to %g um", l.Name, s.zMin, s.zMax, l.Height, top(l))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// ASCII STL of the 3D stack preview, one solid per layer
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func writeSTLExport(w io.Writer, stack exportStack) error {
+	bw := bufio.NewWriter(w)
+	boxes := previewBoxes(stack.Layers, stack.SubstrateMargin)
+	for i, l := range stack.Layers {
+		name := strings.ReplaceAll(l.Name, " ", "_")
+		fmt.Fprintf(bw, "solid %s\n", name)
+		for _, b := range boxes {
+			if b.Layer != i {
+				continue
+			}
+			c := b.corners()
+			for _, f := range previewBoxFaces {
+				for _, tri := range [2][3]int{{0, 1, 2}, {0, 2, 3}} {
+					fmt.Fprintf(bw, "  facet normal %g %g %g\n    outer loop\n", f.Normal[0], f.Normal[1], f.Normal[2])
+					for _, k := range tri {
+						p := c[f.Corners[k]]
+						fmt.Fprintf(bw, "      vertex %g %g %g\n", p[0], p[1], p[2])
+					}
+					fmt.Fprintf(bw, "    endloop\n  endfacet\n")
+				}
+			}
+		}
+		fmt.Fprintf(bw, "endsolid %s\n", name)
+	}
+	return bw.Flush()
+}
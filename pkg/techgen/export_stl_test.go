@@ -0,0 +1,57 @@
+package techgen_test
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSTLExport(t *testing.T) {
+	stack, data := fixtureExport(t, "stl", "sg13g2.stl")
+	type solid struct {
+		name       string
+		facets     int
+		zMin, zMax float64
+		ended      bool
+	}
+	var solids []*solid
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		switch {
+		case len(f) == 2 && f[0] == "solid":
+			solids = append(solids, &solid{name: f[1], zMin: math.Inf(1), zMax: math.Inf(-1)})
+		case len(solids) == 0:
+			t.Fatalf("%q before the first solid", sc.Text())
+		case f[0] == "facet":
+			solids[len(solids)-1].facets++
+		case f[0] == "vertex" && len(f) == 4:
+			z, err := strconv.ParseFloat(f[3], 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := solids[len(solids)-1]
+			s.zMin, s.zMax = min(s.zMin, z), max(s.zMax, z)
+		case f[0] == "endsolid":
+			solids[len(solids)-1].ended = f[1] == solids[len(solids)-1].name
+		}
+	}
+
+	layers := drawnLayers(stack)
+	if len(solids) != len(layers) {
+		t.Fatalf("%d solids, want one per layer, %d", len(solids), len(layers))
+	}
+	for i, l := range layers {
+		s := solids[i]
+		// Boxes of two triangles a side
+		if s.name != l.Name || !s.ended || s.facets == 0 || s.facets%12 != 0 {
+			t.Errorf("solid %d: %s with %d facets, ended %t, want %s of whole boxes", i, s.name, s.facets, s.ended, l.Name)
+		}
+		if l.Name != "Substrate" && (!near(s.zMin, l.Height) || !near(s.zMax, top(l))) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, s.zMin, s.zMax, l.Height, top(l))
+		}
+	}
+}
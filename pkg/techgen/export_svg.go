@@ -0,0 +1,123 @@
+// To scale SVG cross-section of the resolved layer stack
+//
+// Metals are drawn as full width slabs and vias as columns between them.
+// The remaining layers (wells, active, poly, MIM, ...) overlap in z, so each
+// gets its own lane to keep all of them visible. Only the top of the
+// substrate is drawn.
+
+package techgen
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	svgScale          = 40.0 // px per micron
+	svgStackWidth     = 480.0
+	svgMargin         = 50.0
+	svgLabelWidth     = 220.0
+	svgSubstrateDepth = 1.0 // micron of substrate shown
+	svgViaColumns     = 6
+	svgLabelSpacing   = 12.0
+)
+
+func isViaLayer(layer Layer) bool {
+	return strings.Contains(layer.Name, "Via") || layer.Name == "Cont"
+}
+
+func writeSVGExport(w io.Writer, stack exportStack) error {
+	return writeSVGCrossSection(w, stack.Layers)
+}
+
+type svgLabel struct {
+	text string
+	y    float64 // wanted position
+	ly   float64 // placed position
+}
+
+func writeSVGCrossSection(w io.Writer, LayerStack []Layer) error {
+	zMin, zMax := 0.0, 0.0
+	for _, l := range LayerStack {
+		if l.Name == "Substrate" {
+			continue
+		}
+		zMin = math.Min(zMin, l.Height)
+		zMax = math.Max(zMax, layerTop(l))
+	}
+	zMin -= svgSubstrateDepth
+
+	height := (zMax-zMin)*svgScale + 2*svgMargin
+	width := svgStackWidth + svgLabelWidth + 2*svgMargin
+	yOf := func(z float64) float64 { return svgMargin + (zMax-z)*svgScale }
+
+	var lanes []Layer
+	for _, l := range LayerStack {
+		if l.Name != "Substrate" && l.Metal == 0 && !isViaLayer(l) {
+			lanes = append(lanes, l)
+		}
+	}
+	laneWidth := svgStackWidth / float64(max(len(lanes), 1))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" font-family=\"sans-serif\" font-size=\"10\">\n", width, height)
+	fmt.Fprintf(&sb, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+
+	// z axis with a tick per micron
+	fmt.Fprintf(&sb, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"black\"/>\n", svgMargin-5, yOf(zMax), svgMargin-5, yOf(zMin))
+	for z := math.Ceil(zMin); z <= zMax; z++ {
+		fmt.Fprintf(&sb, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"black\"/>\n", svgMargin-10, yOf(z), svgMargin-5, yOf(z))
+		fmt.Fprintf(&sb, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"end\">%g µm</text>\n", svgMargin-12, yOf(z)+3, z)
+	}
+
+	var labels []svgLabel
+	slab := func(l Layer, x, w, z0, z1 float64) {
+		fmt.Fprintf(&sb, "<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" fill=\"%s\" fill-opacity=\"0.85\" stroke=\"black\" stroke-width=\"0.3\"><title>%s</title></rect>\n",
+			x, yOf(z1), w, (z1-z0)*svgScale, l.Color, html.EscapeString(l.Name))
+	}
+
+	lane := 0
+	for _, l := range LayerStack {
+		z0, z1 := l.Height, layerTop(l)
+		switch {
+		case l.Name == "Substrate":
+			z0 = zMin
+			slab(l, svgMargin, svgStackWidth, z0, z1)
+		case l.Metal == 1:
+			slab(l, svgMargin, svgStackWidth, z0, z1)
+		case isViaLayer(l):
+			pitch := svgStackWidth / svgViaColumns
+			for c := 0; c < svgViaColumns; c++ {
+				slab(l, svgMargin+pitch*(float64(c)+0.35), pitch*0.3, z0, z1)
+			}
+		default:
+			slab(l, svgMargin+laneWidth*float64(lane), laneWidth, z0, z1)
+			lane++
+		}
+		text := fmt.Sprintf("%s  %g–%g µm", l.Name, roundUm(l.Height), layerTop(l))
+		labels = append(labels, svgLabel{text: text, y: yOf((z0 + z1) / 2)})
+	}
+
+	// Spread labels of thin and overlapping layers apart and draw leader lines
+	sort.Slice(labels, func(i, j int) bool { return labels[i].y > labels[j].y })
+	for i := range labels {
+		labels[i].ly = labels[i].y
+		if i > 0 && labels[i].ly > labels[i-1].ly-svgLabelSpacing {
+			labels[i].ly = labels[i-1].ly - svgLabelSpacing
+		}
+	}
+	x0 := svgMargin + svgStackWidth
+	for _, lb := range labels {
+		fmt.Fprintf(&sb, "<polyline points=\"%.1f,%.1f %.1f,%.1f %.1f,%.1f\" fill=\"none\" stroke=\"gray\" stroke-width=\"0.5\"/>\n",
+			x0, lb.y, x0+20, lb.y, x0+40, lb.ly)
+		fmt.Fprintf(&sb, "<text x=\"%.1f\" y=\"%.1f\">%s</text>\n", x0+44, lb.ly+3, html.EscapeString(lb.text))
+	}
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,70 @@
+package techgen_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+)
+
+// The rects of an SVG by their title, the layer they draw
+func svgRects(t *testing.T, data []byte) map[string][]map[string]string {
+	t.Helper()
+	rects := map[string][]map[string]string{}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var attrs map[string]string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return rects
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		se, ok := tok.(xml.StartElement)
+		switch {
+		case ok && se.Name.Local == "rect":
+			attrs = map[string]string{}
+			for _, a := range se.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+		case ok && se.Name.Local == "title" && attrs != nil:
+			var title string
+			if err := dec.DecodeElement(&title, &se); err != nil {
+				t.Fatal(err)
+			}
+			rects[title] = append(rects[title], attrs)
+			attrs = nil
+		}
+	}
+}
+
+func TestSVGExport(t *testing.T) {
+	stack, data := fixtureExport(t, "svg", "sg13g2.svg")
+	rects := svgRects(t, data)
+	for _, l := range drawnLayers(stack) {
+		if len(rects[l.Name]) == 0 {
+			t.Errorf("no rect of %s", l.Name)
+			continue
+		}
+		for _, r := range rects[l.Name] {
+			if r["fill"] != l.Color {
+				t.Errorf("%s drawn in %s, want %s", l.Name, r["fill"], l.Color)
+			}
+		}
+	}
+	// Metals are as wide as the stack, their rects ordered bottom to top
+	prevY := math.Inf(1)
+	for _, l := range metalLayers(stack) {
+		var y float64
+		if _, err := fmt.Sscan(rects[l.Name][0]["y"], &y); err != nil {
+			t.Fatal(err)
+		}
+		if y >= prevY {
+			t.Errorf("%s at y %g is not above the metal below it at %g", l.Name, y, prevY)
+		}
+		prevY = y
+	}
+}
@@ -0,0 +1,67 @@
+package techgen_test
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/techgen/techgentest"
+)
+
+// The resolved stack of the sg13g2 fixture and its file of -export name,
+// e.g. sg13g2.gltf of gltf
+func fixtureExport(t *testing.T, name, file string) (ir.Stack, []byte) {
+	t.Helper()
+	out := techgentest.Fixtures[0].Output(t, name)
+	data, ok := out.Exports[file]
+	if !ok {
+		var files []string
+		for f := range out.Exports {
+			files = append(files, f)
+		}
+		sort.Strings(files)
+		t.Fatalf("-export %s wrote %v, no %s", name, files, file)
+	}
+	return out.Stack, data
+}
+
+// Lengths of the exports are micron with a few decimals
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-4
+}
+
+func top(l ir.Layer) float64 {
+	return l.Height + l.Thickness
+}
+
+// The layers of the fixture drawn in the 3D previews, one box or a grid
+// of vias each
+func drawnLayers(stack ir.Stack) []ir.Layer {
+	return slices.DeleteFunc(slices.Clone(stack.Layers), func(l ir.Layer) bool {
+		return l.Thickness <= 0.0 || l.ZFrom != ""
+	})
+}
+
+// Metals as the exports tell them apart, by their z of their own
+func metalLayers(stack ir.Stack) []ir.Layer {
+	return slices.DeleteFunc(drawnLayers(stack), func(l ir.Layer) bool { return !l.Metal })
+}
+
+// Red, green, blue of #rrggbb from 0.0 to 1.0, and the color again as
+// written by the exports that take the numbers with three decimals
+func parseColor(t *testing.T, color string) (r, g, b float64, decimals string) {
+	t.Helper()
+	r, g, b, err := gds3d.ParseColor(color)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, g, b, fmt.Sprintf("%.3f, %.3f, %.3f", r, g, b)
+}
+
+func near3(c []float64, r, g, b float64) bool {
+	return len(c) == 3 && near(c[0], r) && near(c[1], g) && near(c[2], b)
+}
@@ -0,0 +1,158 @@
+// HTML page with a three.js scene of the 3D stack preview, so the stack
+// can be inspected in a browser without GDS3D installed
+//
+// The scene data is embedded in the page, three.js itself is not: the page
+// loads a pinned version from cdn.jsdelivr.net, so it only shows the stack
+// on a machine with access to the CDN, hence the name threejs-cdn. Offline
+// the gltf export opens in any glTF viewer instead.
+
+package techgen
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+)
+
+const threeVersion = "0.160.0"
+
+var threeViewerTemplate = template.Must(template.New("viewer").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} layer stack 3D</title>
+<style>
+body { margin: 0; overflow: hidden; font-family: sans-serif; }
+#layers { position: absolute; top: 8px; left: 8px; background: rgba(255,255,255,0.85); padding: 6px 10px; font-size: 13px; }
+#layers label { display: block; }
+.swatch { display: inline-block; width: 1.5em; height: 0.9em; border: 1px solid #000; vertical-align: middle; margin-right: 4px; }
+</style>
+<script type="importmap">
+{ "imports": {
+  "three": "https://cdn.jsdelivr.net/npm/three@{{.Version}}/build/three.module.js",
+  "three/addons/": "https://cdn.jsdelivr.net/npm/three@{{.Version}}/examples/jsm/"
+} }
+</script>
+</head>
+<body>
+<div id="layers"><b>{{.Title}}</b></div>
+<script type="module">
+import * as THREE from "three";
+import { OrbitControls } from "three/addons/controls/OrbitControls.js";
+
+const stack = {{.Stack}};
+
+const renderer = new THREE.WebGLRenderer({ antialias: true });
+renderer.setPixelRatio(window.devicePixelRatio);
+renderer.setSize(window.innerWidth, window.innerHeight);
+document.body.appendChild(renderer.domElement);
+
+const scene = new THREE.Scene();
+scene.background = new THREE.Color(0xf0f0f0);
+scene.add(new THREE.AmbientLight(0xffffff, 0.6));
+const sun = new THREE.DirectionalLight(0xffffff, 1.2);
+sun.position.set(20, -30, 40);
+scene.add(sun);
+
+// Stack coordinates are micron with z up
+const camera = new THREE.PerspectiveCamera(45, window.innerWidth / window.innerHeight, 0.1, 1000);
+camera.up.set(0, 0, 1);
+camera.position.set(25, -20, 20);
+const controls = new OrbitControls(camera, renderer.domElement);
+controls.target.set(stack.center[0], stack.center[1], stack.center[2]);
+controls.update();
+
+const groups = stack.layers.map((layer) => {
+  const group = new THREE.Group();
+  group.name = layer.name;
+  const material = new THREE.MeshStandardMaterial({
+    color: layer.color,
+    metalness: layer.metal ? 0.6 : 0.0,
+    roughness: layer.metal ? 0.4 : 0.8,
+    transparent: layer.opacity < 1,
+    opacity: layer.opacity,
+  });
+  for (const [x0, y0, z0, x1, y1, z1] of layer.boxes) {
+    const mesh = new THREE.Mesh(new THREE.BoxGeometry(x1 - x0, y1 - y0, z1 - z0), material);
+    mesh.position.set((x0 + x1) / 2, (y0 + y1) / 2, (z0 + z1) / 2);
+    group.add(mesh);
+  }
+  group.visible = layer.show;
+  scene.add(group);
+
+  const label = document.createElement("label");
+  const box = document.createElement("input");
+  box.type = "checkbox";
+  box.checked = layer.show;
+  box.onchange = () => { group.visible = box.checked; };
+  const swatch = document.createElement("span");
+  swatch.className = "swatch";
+  swatch.style.background = layer.color;
+  label.append(box, swatch, layer.name + " (" + layer.gds + ")");
+  document.getElementById("layers").appendChild(label);
+  return group;
+});
+
+window.addEventListener("resize", () => {
+  camera.aspect = window.innerWidth / window.innerHeight;
+  camera.updateProjectionMatrix();
+  renderer.setSize(window.innerWidth, window.innerHeight);
+});
+
+renderer.setAnimationLoop(() => {
+  controls.update();
+  renderer.render(scene, camera);
+});
+</script>
+</body>
+</html>
+`))
+
+type threeLayer struct {
+	Name    string       `json:"name"`
+	GDS     string       `json:"gds"`
+	Color   string       `json:"color"`
+	Opacity float64      `json:"opacity"`
+	Metal   bool         `json:"metal"`
+	Show    bool         `json:"show"`
+	Boxes   [][6]float64 `json:"boxes"`
+}
+
+type threeStack struct {
+	Center [3]float64   `json:"center"`
+	Layers []threeLayer `json:"layers"`
+}
+
+func writeThreeJSCDNExport(w io.Writer, stack exportStack) error {
+	scene := threeStack{Center: [3]float64{previewSize / 2, previewSize / 2, 0}}
+	index := map[int]int{}
+	top := 0.0
+	for _, b := range previewBoxes(stack.Layers, stack.SubstrateMargin) {
+		l := stack.Layers[b.Layer]
+		i, ok := index[b.Layer]
+		if !ok {
+			i = len(scene.Layers)
+			index[b.Layer] = i
+			scene.Layers = append(scene.Layers, threeLayer{
+				Name:    l.Name,
+				GDS:     formatGDS(l),
+				Color:   l.Color,
+				Opacity: layerAlpha(l),
+				Metal:   l.Metal == 1,
+				Show:    l.Show,
+			})
+		}
+		scene.Layers[i].Boxes = append(scene.Layers[i].Boxes, [6]float64{b.X0, b.Y0, b.Z0, b.X1, b.Y1, b.Z1})
+		top = max(top, b.Z1)
+	}
+	scene.Center[2] = top / 2
+
+	data, err := json.Marshal(scene)
+	if err != nil {
+		return err
+	}
+	return threeViewerTemplate.Execute(w, struct {
+		Title, Version string
+		Stack          template.JS
+	}{stack.Stem, threeVersion, template.JS(data)})
+}
@@ -0,0 +1,54 @@
+package techgen_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"testing"
+)
+
+func TestThreeJSCDNExport(t *testing.T) {
+	stack, data := fixtureExport(t, "threejs-cdn", "sg13g2_3d.html")
+	m := regexp.MustCompile(`(?m)^const stack = (.*);$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no stack in the page")
+	}
+	var scene struct {
+		Layers []struct {
+			Name    string       `json:"name"`
+			GDS     string       `json:"gds"`
+			Color   string       `json:"color"`
+			Opacity float64      `json:"opacity"`
+			Metal   bool         `json:"metal"`
+			Boxes   [][6]float64 `json:"boxes"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(m[1], &scene); err != nil {
+		t.Fatal(err)
+	}
+	layers := drawnLayers(stack)
+	if len(scene.Layers) != len(layers) {
+		t.Fatalf("%d layers, want %d", len(scene.Layers), len(layers))
+	}
+	for i, l := range layers {
+		got := scene.Layers[i]
+		if got.Name != l.Name || got.Color != l.Color || !near(got.Opacity, 1.0-l.Filter) || got.Metal != l.Metal {
+			t.Errorf("layer %d: %s %s, opacity %g, metal %t, want %s %s", i, got.Name, got.Color, got.Opacity, got.Metal, l.Name, l.Color)
+		}
+		if gds := fmt.Sprintf("%d/%d", l.GDSLayer, l.GDSDatatype); l.Name != "Substrate" && got.GDS != gds {
+			t.Errorf("%s on %s, want %s", l.Name, got.GDS, gds)
+		}
+		if len(got.Boxes) == 0 {
+			t.Errorf("no boxes of %s", l.Name)
+			continue
+		}
+		zMin, zMax := math.Inf(1), math.Inf(-1)
+		for _, b := range got.Boxes {
+			zMin, zMax = min(zMin, b[2]), max(zMax, b[5])
+		}
+		if l.Name != "Substrate" && (!near(zMin, l.Height) || !near(zMax, top(l))) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, zMin, zMax, l.Height, top(l))
+		}
+	}
+}
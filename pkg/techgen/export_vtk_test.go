@@ -0,0 +1,100 @@
+package techgen_test
+
+import (
+	"encoding/xml"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type vtkArray struct {
+	Name string `xml:",attr"`
+	Data string `xml:",chardata"`
+}
+
+func (a vtkArray) values(t *testing.T) []float64 {
+	t.Helper()
+	var values []float64
+	for _, f := range strings.Fields(a.Data) {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			t.Fatalf("%s: %v", a.Name, err)
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func TestVTKExport(t *testing.T) {
+	stack, data := fixtureExport(t, "vtk", "sg13g2.vtu")
+	var vtu struct {
+		Piece struct {
+			NumberOfPoints int        `xml:",attr"`
+			NumberOfCells  int        `xml:",attr"`
+			Points         vtkArray   `xml:"Points>DataArray"`
+			CellData       []vtkArray `xml:"CellData>DataArray"`
+		} `xml:"UnstructuredGrid>Piece"`
+	}
+	if err := xml.Unmarshal(data, &vtu); err != nil {
+		t.Fatal(err)
+	}
+	piece := vtu.Piece
+	cells := piece.NumberOfCells
+	points := piece.Points.values(t)
+	if cells == 0 || piece.NumberOfPoints != 8*cells || len(points) != 3*piece.NumberOfPoints {
+		t.Fatalf("%d cells of %d points, %d coordinates, want hexahedra", cells, piece.NumberOfPoints, len(points))
+	}
+	arrays := map[string][]float64{}
+	for _, a := range piece.CellData {
+		arrays[a.Name] = a.values(t)
+	}
+	for name, n := range map[string]int{"layer": cells, "gds_layer": cells, "metal": cells, "color": 3 * cells} {
+		if len(arrays[name]) != n {
+			t.Fatalf("cell data %s of %d values, want %d", name, len(arrays[name]), n)
+		}
+	}
+
+	// The z extent of the cells of each layer
+	zMin, zMax := map[int]float64{}, map[int]float64{}
+	for i := 0; i < cells; i++ {
+		index := int(arrays["layer"][i])
+		if index < 0 || index >= len(stack.Layers) {
+			t.Fatalf("cell %d of layer %d", i, index)
+		}
+		l := stack.Layers[index]
+		// The substrate is written with the GDS3D substrate number
+		if int(arrays["gds_layer"][i]) != l.GDSLayer && l.Name != "Substrate" {
+			t.Errorf("cell %d of %s: gds_layer %g, want %d", i, l.Name, arrays["gds_layer"][i], l.GDSLayer)
+		}
+		if metal := arrays["metal"][i] == 1; metal != l.Metal {
+			t.Errorf("cell %d of %s: metal %t, want %t", i, l.Name, metal, l.Metal)
+		}
+		c := rgb8(t, l.Color)
+		for k := range c {
+			if int(arrays["color"][3*i+k]) != c[k] {
+				t.Errorf("cell %d of %s: color %v, want %s", i, l.Name, arrays["color"][3*i:3*i+3], l.Color)
+				break
+			}
+		}
+		if _, ok := zMin[index]; !ok {
+			zMin[index], zMax[index] = math.Inf(1), math.Inf(-1)
+		}
+		for k := 0; k < 8; k++ {
+			z := points[3*(8*i+k)+2]
+			zMin[index], zMax[index] = min(zMin[index], z), max(zMax[index], z)
+		}
+	}
+	for i, l := range stack.Layers {
+		if l.Thickness <= 0.0 || l.ZFrom != "" || l.Name == "Substrate" {
+			continue
+		}
+		if _, ok := zMin[i]; !ok {
+			t.Errorf("no cells of %s", l.Name)
+			continue
+		}
+		if !near(zMin[i], l.Height) || !near(zMax[i], top(l)) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, zMin[i], zMax[i], l.Height, top(l))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Layer config for web based GDS 3D viewers (GDS2WebGL style), so chips
+// can be published in the browser without a second layer database
+
+package techgen
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type webglLayer struct {
+	Layer    int     `json:"layer"`
+	Datatype int     `json:"datatype"`
+	Name     string  `json:"name"`
+	ZMin     float64 `json:"zmin"`
+	ZMax     float64 `json:"zmax"`
+	Color    string  `json:"color"`
+	Opacity  float64 `json:"opacity"`
+	Visible  bool    `json:"visible"`
+}
+
+type webglConfig struct {
+	Name   string       `json:"name"`
+	Units  string       `json:"units"`
+	Layers []webglLayer `json:"layers"`
+}
+
+func writeWebGLExport(w io.Writer, stack exportStack) error {
+	config := webglConfig{Name: stack.Stem, Units: "um"}
+	for _, l := range stack.Layers {
+		if l.Thickness <= 0.0 {
+			continue
+		}
+		config.Layers = append(config.Layers, webglLayer{
+			Layer:    l.GDSNumber,
+			Datatype: l.GDSDatatype,
+			Name:     l.Name,
+			ZMin:     roundUm(l.Height),
+			ZMax:     layerTop(l),
+			Color:    l.Color,
+			Opacity:  layerAlpha(l),
+			Visible:  l.Show,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(config)
+}
@@ -0,0 +1,47 @@
+package techgen_test
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWebGLExport(t *testing.T) {
+	stack, data := fixtureExport(t, "webgl", "sg13g2_webgl.json")
+	var doc struct {
+		Name   string `json:"name"`
+		Units  string `json:"units"`
+		Layers []struct {
+			Layer    int     `json:"layer"`
+			Datatype int     `json:"datatype"`
+			Name     string  `json:"name"`
+			ZMin     float64 `json:"zmin"`
+			ZMax     float64 `json:"zmax"`
+			Color    string  `json:"color"`
+			Opacity  float64 `json:"opacity"`
+			Visible  bool    `json:"visible"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "sg13g2" || doc.Units != "um" {
+		t.Errorf("stack %s in %s, want sg13g2 in um", doc.Name, doc.Units)
+	}
+	layers := drawnLayers(stack)
+	if len(doc.Layers) != len(layers) {
+		t.Fatalf("%d layers, want %d", len(doc.Layers), len(layers))
+	}
+	for i, l := range layers {
+		got := doc.Layers[i]
+		if got.Name != l.Name || got.Color != l.Color || !near(got.Opacity, 1.0-l.Filter) || got.Visible != l.Show {
+			t.Errorf("layer %d = %+v, want %s", i, got, l.Name)
+		}
+		if !near(got.ZMin, l.Height) || !near(got.ZMax, top(l)) {
+			t.Errorf("%s from z %g to %g, want %g to %g", l.Name, got.ZMin, got.ZMax, l.Height, top(l))
+		}
+		// The substrate is written with the GDS3D substrate number
+		if l.Name != "Substrate" && (got.Layer != l.GDSLayer || got.Datatype != l.GDSDatatype) {
+			t.Errorf("%s on %d/%d, want %d/%d", l.Name, got.Layer, got.Datatype, l.GDSLayer, l.GDSDatatype)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// KLayout XSection (.xs) process script built from the resolved stack
+//
+// The process is a plain planar sequence: oxide is deposited up to the
+// bottom of each layer, the layer is grown from its mask by its thickness
+// and the surface is planarized back to the layer top. That reproduces the
+// resolved z values in the 2D cross-section, not the real process flow.
+
+package techgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+)
+
+var xsIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func xsName(layer Layer) string {
+	return "l_" + xsIdentifier.ReplaceAllString(layer.Name, "_")
+}
+
+func writeXSExport(w io.Writer, stack exportStack) error {
+	var layers []Layer
+	zTop := 0.0
+	for _, l := range stack.Layers {
+		if l.Name == "Substrate" || l.Thickness <= 0.0 {
+			continue
+		}
+		layers = append(layers, l)
+		zTop = math.Max(zTop, layerTop(l))
+	}
+	sort.SliceStable(layers, func(i, j int) bool { return layers[i].Height < layers[j].Height })
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# XSection script for %s, generated by build_3d_techfile\n\n", stack.Stem)
+	fmt.Fprintf(bw, "below(%s)\ndepth(%s)\nheight(%s)\ndelta(0.005)\n\n", formatUm(previewSubstrateDepth), formatUm(previewSubstrateDepth), formatUm(zTop+1.0))
+	fmt.Fprintf(bw, "substrate = bulk\n\n")
+
+	for _, l := range layers {
+		fmt.Fprintf(bw, "%s_mask = layer(\"%d/%d\")\n", xsName(l), l.GDSNumber, l.GDSDatatype)
+	}
+	fmt.Fprintf(bw, "\n")
+
+	surface := 0.0
+	oxide := false
+	depositOxide := func(thickness float64) {
+		if oxide {
+			fmt.Fprintf(bw, "ox = ox.or(deposit(%s))\n", formatUm(thickness))
+		} else {
+			fmt.Fprintf(bw, "ox = deposit(%s)\n", formatUm(thickness))
+			oxide = true
+		}
+	}
+	for _, l := range layers {
+		name, top := xsName(l), layerTop(l)
+		fmt.Fprintf(bw, "# %s %s..%s um\n", l.Name, formatUm(l.Height), formatUm(top))
+		if gap := roundUm(l.Height - surface); gap > 0 {
+			depositOxide(gap)
+			surface = l.Height
+		}
+
+		// Layers overlapping in z with what is already there, like contacts
+		// reaching down to active, are converted from the material below
+		// the surface. If the layer ends below the surface as well, this
+		// part reaches up to the surface.
+		low := roundUm(surface - l.Height)
+		if low > 0 {
+			into := "substrate"
+			if oxide {
+				into = "ox"
+			}
+			fmt.Fprintf(bw, "%s_low = mask(%s_mask).grow(%s, :into => [%s])\n", name, name, formatUm(low), into)
+		}
+		if up := roundUm(top - surface); up > 0 {
+			fmt.Fprintf(bw, "%s = mask(%s_mask).grow(%s)\n", name, name, formatUm(up))
+			depositOxide(up)
+			fmt.Fprintf(bw, "planarize(:downto => %s, :into => [ox])\n", name)
+			if low > 0 {
+				fmt.Fprintf(bw, "%s = %s.or(%s_low)\n", name, name, name)
+			}
+			surface = top
+		} else {
+			fmt.Fprintf(bw, "%s = %s_low\n", name, name)
+		}
+		fmt.Fprintf(bw, "\n")
+	}
+
+	fmt.Fprintf(bw, "output(\"1/0\", substrate)\n")
+	for _, l := range layers {
+		fmt.Fprintf(bw, "output(\"%d/%d\", %s)\n", l.GDSNumber, l.GDSDatatype, xsName(l))
+	}
+	if oxide {
+		fmt.Fprintf(bw, "output(\"1000/0\", ox)\n")
+	}
+	return bw.Flush()
+}
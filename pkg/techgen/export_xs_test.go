@@ -0,0 +1,45 @@
+package techgen_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestXSExport(t *testing.T) {
+	stack, data := fixtureExport(t, "xs", "sg13g2.xs")
+	script := string(data)
+
+	// A section per layer built up from the substrate, of its z range and
+	// grown by its thickness
+	section := regexp.MustCompile(`(?m)^# (\S+) (\S+)\.\.(\S+) um\n(l_\S+) = mask\(l_\S+_mask\)\.grow\(([^,)]+)`)
+	sections := map[string][]string{}
+	for _, m := range section.FindAllStringSubmatch(script, -1) {
+		sections[m[1]] = m[2:]
+	}
+	for _, l := range drawnLayers(stack) {
+		if l.Name == "Substrate" {
+			continue
+		}
+		s, ok := sections[l.Name]
+		if !ok {
+			t.Errorf("no section of %s", l.Name)
+			continue
+		}
+		from, _ := strconv.ParseFloat(s[0], 64)
+		to, _ := strconv.ParseFloat(s[1], 64)
+		if !near(from, l.Height) || !near(to, top(l)) {
+			t.Errorf("%s from %g to %g um, want %g to %g", l.Name, from, to, l.Height, top(l))
+		}
+		// Layers grown into the oxide below reach down to the layer they
+		// sit on, all others are grown by their thickness
+		if grow, _ := strconv.ParseFloat(s[3], 64); !strings.HasSuffix(s[2], "_low") && !near(grow, l.Thickness) {
+			t.Errorf("%s grown by %g, want its thickness %g", l.Name, grow, l.Thickness)
+		}
+		if output := fmt.Sprintf("output(\"%d/%d\", l_%s)\n", l.GDSLayer, l.GDSDatatype, l.Name); !strings.Contains(script, output) {
+			t.Errorf("no %s", strings.TrimSpace(output))
+		}
+	}
+}
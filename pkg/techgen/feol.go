@@ -0,0 +1,137 @@
+// z model of the front end
+//
+// LEFs rarely give FEOL heights, so wells, active, poly and contacts are
+// placed by a small model of the process, parameterized per PDK (um):
+//
+//	wells     from 0 up to well_top
+//	active    active_thickness of silicon above the field oxide at well_top
+//	STI       field_oxide of trench oxide reaching down from the active top
+//	poly      on the gate oxide over active, poly_thickness thick
+//	contact   from the silicided active to the bottom of Metal1
+//
+// Only stacks with an STI or FieldOx layer, e.g. from a derived layer of
+// the stack config, get the field oxide, and only with a field_oxide set.
+//
+// The contact span is set after the metals are resolved, so contacts land
+// on Metal1 without a gap or overlap. LEF, stackup, PDK dump and stack
+// config values still win over the model. The stack config can pick the
+// model and change single values:
+/*
+
+"feol": { "pdk": "sg13g2", "gate_oxide": 0.007 }
+*/
+
+package techgen
+
+import "fmt"
+
+type feolModel struct {
+	WellTop         float64 `json:"well_top"`
+	ActiveThickness float64 `json:"active_thickness"`
+	GateOxide       float64 `json:"gate_oxide"`
+	PolyThickness   float64 `json:"poly_thickness"`
+	FieldOxide      float64 `json:"field_oxide"` // STI depth, 0 for none
+}
+
+type feolConfig struct {
+	PDK             string   `json:"pdk"`
+	WellTop         *float64 `json:"well_top"`
+	ActiveThickness *float64 `json:"active_thickness"`
+	GateOxide       *float64 `json:"gate_oxide"`
+	PolyThickness   *float64 `json:"poly_thickness"`
+	FieldOxide      *float64 `json:"field_oxide"`
+}
+
+var pdkFEOLModels = map[string]feolModel{
+	// The gate oxide is a few nm, left out as in the earlier fixed heights.
+	// The stack has no STI layer, the field oxide is left to the config.
+	"sg13g2": {WellTop: 0.2, ActiveThickness: 0.12, GateOxide: 0.0, PolyThickness: 0.1},
+}
+
+const defaultFEOLPDK = "sg13g2"
+
+func (config *feolConfig) validate() error {
+	if config.PDK != "" {
+		if _, ok := pdkFEOLModels[config.PDK]; !ok {
+			return fmt.Errorf("unknown pdk %q", config.PDK)
+		}
+	}
+	for _, v := range []*float64{config.WellTop, config.ActiveThickness, config.GateOxide, config.PolyThickness, config.FieldOxide} {
+		if v != nil && *v < 0.0 {
+			return fmt.Errorf("value %g, expected a length in um", *v)
+		}
+	}
+	return nil
+}
+
+// The model of the PDK with the overrides of the config, which may be nil
+func resolveFEOLModel(config *feolConfig) feolModel {
+	pdk := defaultFEOLPDK
+	if config != nil && config.PDK != "" {
+		pdk = config.PDK
+	}
+	m := pdkFEOLModels[pdk]
+	if config == nil {
+		return m
+	}
+	for _, o := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{config.WellTop, &m.WellTop},
+		{config.ActiveThickness, &m.ActiveThickness},
+		{config.GateOxide, &m.GateOxide},
+		{config.PolyThickness, &m.PolyThickness},
+		{config.FieldOxide, &m.FieldOxide},
+	} {
+		if o.value != nil {
+			*o.target = *o.value
+		}
+	}
+	return m
+}
+
+// Before the input files, which override the model where they have data.
+// Layers with a z of their own, e.g. read back from an IR, are kept.
+func update_layerstack_feol(LayerStack []Layer, m feolModel) {
+	activeTop := m.WellTop + m.ActiveThickness
+	for i, l := range LayerStack {
+		if l.ZSource != "default" {
+			continue
+		}
+		switch l.Name {
+		case "NWell", "PWell":
+			LayerStack[i].Height, LayerStack[i].Thickness = 0.0, m.WellTop
+		case "Active":
+			LayerStack[i].Height, LayerStack[i].Thickness = m.WellTop, m.ActiveThickness
+		case "GatPoly", "ResPoly":
+			LayerStack[i].Height, LayerStack[i].Thickness = activeTop+m.GateOxide, m.PolyThickness
+		case "STI", "FieldOx":
+			if m.FieldOxide <= 0.0 {
+				continue
+			}
+			LayerStack[i].Height, LayerStack[i].Thickness = activeTop-m.FieldOxide, m.FieldOxide
+		case "Cont":
+			LayerStack[i].Height = activeTop
+		default:
+			continue
+		}
+		LayerStack[i].ZSource = "feol model"
+	}
+}
+
+// After the metals are resolved, contacts reach up to Metal1
+func update_layerstack_contacts(LayerStack []Layer) []Warning {
+	var warnings []Warning
+	c, m := layerIndex(LayerStack, "Cont"), layerIndex(LayerStack, "Metal1")
+	if c < 0 || m < 0 || LayerStack[c].ZSource != "feol model" {
+		return nil
+	}
+	if LayerStack[m].Height <= LayerStack[c].Height {
+		warnings = append(warnings, warn("contact-z", "Cont: Metal1 at %g um is not above the active, contact left as is", LayerStack[m].Height))
+		return warnings
+	}
+	LayerStack[c].Thickness = LayerStack[m].Height - LayerStack[c].Height
+	LayerStack[c].ZSource = "feol model to Metal1"
+	return warnings
+}
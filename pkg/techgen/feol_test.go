@@ -0,0 +1,32 @@
+package techgen
+
+import "testing"
+
+func TestUpdateLayerstackFEOLFieldOxide(t *testing.T) {
+	fieldOxide := 0.3
+	tests := []struct {
+		config            *feolConfig
+		height, thickness float64 // of STI
+	}{
+		{nil, 0.0, 0.0}, // no field oxide in the model, left as it was
+		{&feolConfig{FieldOxide: &fieldOxide}, 0.02, 0.3},
+	}
+	for _, tt := range tests {
+		LayerStack := []Layer{
+			{Name: "Active", ZSource: "default"},
+			{Name: "STI", ZSource: "default"},
+		}
+		update_layerstack_feol(LayerStack, resolveFEOLModel(tt.config))
+		sti := LayerStack[1]
+		if roundUm(sti.Height) != tt.height || roundUm(sti.Thickness) != tt.thickness {
+			t.Errorf("config %+v: STI at %g um, %g um thick, want %g and %g", tt.config, sti.Height, sti.Thickness, tt.height, tt.thickness)
+		}
+		if top := layerTop(sti); tt.thickness > 0.0 && top != layerTop(LayerStack[0]) {
+			t.Errorf("config %+v: STI top %g um, want the active top %g um", tt.config, top, layerTop(LayerStack[0]))
+		}
+	}
+	bad := -0.1
+	if err := (&feolConfig{FieldOxide: &bad}).validate(); err == nil {
+		t.Error("negative field_oxide validated")
+	}
+}
@@ -0,0 +1,76 @@
+// Include and exclude filters on the resolved stack
+//
+//	build_3d_techfile -include '*Metal*,*Via*,Cont' -o beol.txt
+//	build_3d_techfile -exclude '/\.(filler|text)$/'
+//
+// Comma separated layer name patterns, globs or regular expressions
+// between slashes. With -include only matching layers are written, with
+// -exclude matching layers are left out. Via interpolation and the other
+// steps still see the whole stack.
+
+package techgen
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type layerPattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func parseLayerPatterns(list string) ([]layerPattern, error) {
+	var patterns []layerPattern
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+			continue
+		case len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/"):
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("layer pattern %s: %w", p, err)
+			}
+			patterns = append(patterns, layerPattern{re: re})
+		default:
+			if _, err := filepath.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("layer pattern %s: %w", p, err)
+			}
+			patterns = append(patterns, layerPattern{glob: p})
+		}
+	}
+	return patterns, nil
+}
+
+func matchLayer(patterns []layerPattern, name string) bool {
+	for _, p := range patterns {
+		if p.re != nil && p.re.MatchString(name) {
+			return true
+		}
+		if ok, _ := filepath.Match(p.glob, name); p.re == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func filterLayerStack(r *run, LayerStack []Layer) []Layer {
+	if r.include == nil && r.exclude == nil {
+		return LayerStack
+	}
+	var kept []Layer
+	for _, l := range LayerStack {
+		if r.include != nil && !matchLayer(r.include, l.Name) {
+			continue
+		}
+		if matchLayer(r.exclude, l.Name) {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	r.progress("Filtered stack: %d of %d layers", len(kept), len(LayerStack))
+	return kept
+}
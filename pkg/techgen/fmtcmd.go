@@ -0,0 +1,78 @@
+// Normalization of hand maintained techfiles
+//
+//	build_3d_techfile fmt -w mems.txt
+//	build_3d_techfile fmt -format strict -o clean.txt old.txt
+//
+// Reads any techfile and writes it back in canonical form: the fields in
+// -format order and spelling, lengths and colors with fixed precision.
+// Comments, blank lines and the order of the layers stay as they are, so
+// formatting a formatted file changes nothing and two techfiles can be
+// diffed and linted line by line.
+
+package techgen
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+)
+
+func runFmt(ctx context.Context, args []string) {
+	opts := DefaultOptions()
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	flags.StringVar(&opts.Unit, "unit", opts.Unit, "Length unit of the techfile: nm or um")
+	flags.IntVar(&opts.Precision, "precision", opts.Precision, "Decimals of Height and Thickness (default 0 for nm, 3 for um)")
+	flags.StringVar(&opts.Format, "format", opts.Format, "Techfile key spelling: legacy or strict")
+	flags.StringVar(&opts.Numbers, "numbers", opts.Numbers, "Number style: fixed decimals or minimal (no trailing zeros)")
+	inPlace := flags.Bool("w", false, "Rewrite the techfiles in place")
+	outPath := flags.String("o", "", "Output techfile, for a single input")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: build_3d_techfile fmt [-w | -o out.txt] techfile.txt ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 || *inPlace == (*outPath != "") || (*outPath != "" && flags.NArg() != 1) {
+		flags.Usage()
+		os.Exit(2)
+	}
+	r, err := newRun(opts, nil)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, name := range flags.Args() {
+		filePath := name
+		if *outPath != "" {
+			filePath = *outPath
+		}
+		if err := formatTechFile(ctx, r, name, filePath); err != nil {
+			fmt.Println("Error formatting techfile:", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Formatted: %s\n", filePath)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Writes the techfile name to filePath, which may be name itself, in the
+// -format and -numbers of r
+func formatTechFile(ctx context.Context, r *run, name, filePath string) error {
+	file, err := openInput(ctx, r, name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeFileAtomic(r.path(filePath), func(w io.Writer) error {
+		return gds3d.Reformat(ctx, ctxio.NewWriter(ctx, w), file, name, r.Unit, r.numberFormat())
+	})
+}
@@ -0,0 +1,143 @@
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A hand written techfile: no sections, um precision noise, the strict
+// Green spelling, the layers out of order and notes of its maintainer
+const handTechFile = `# MEMS add-on stack, maintained by hand
+#   do not regenerate!
+
+# Second metal, thick option
+LayerStart: Metal2
+Height: 2000.4
+Layer: 10
+Datatype: 0
+Thickness: 450
+Red: 0.8
+Green: 0.8
+Blue: 0.2
+Filter: 0
+# checked against the 2023 cross section
+Metal: 1
+Show: 1
+LayerEnd
+LayerStart:   Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 0.22
+Greeen: 0.75
+
+Blue: 1
+Filter: 0.0
+Metal: 1
+Shortkey: 1
+Show: 1
+LayerEnd
+#end of stack
+`
+
+// handTechFile after fmt
+const handTechFileFormatted = `# MEMS add-on stack, maintained by hand
+#   do not regenerate!
+
+# Second metal, thick option
+# checked against the 2023 cross section
+LayerStart: Metal2
+Layer: 10
+Datatype: 0
+Height: 2000
+Thickness: 450
+Red: 0.80
+Greeen: 0.80
+Blue: 0.20
+Filter: 0.0
+Metal: 1
+Show: 1
+LayerEnd
+LayerStart: Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 0.22
+Greeen: 0.75
+Blue: 1.00
+Filter: 0.0
+Metal: 1
+Shortkey: 1
+Show: 1
+LayerEnd
+#end of stack
+`
+
+func fmtTechFile(t *testing.T, r *run, src []byte) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "techfile.txt")
+	if err := os.WriteFile(name, src, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := formatTechFile(context.Background(), r, name, name); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestFmtIdempotent(t *testing.T) {
+	r, err := newRun(DefaultOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := os.ReadFile("../../sg13g2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"sg13g2", golden},
+		{"hand written", []byte(handTechFile)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			once := fmtTechFile(t, r, tt.src)
+			twice := fmtTechFile(t, r, once)
+			if !bytes.Equal(once, twice) {
+				t.Errorf("formatting a formatted techfile changed it:\n%s\nwas:\n%s", twice, once)
+			}
+		})
+	}
+}
+
+// Only the fields change, the comments and the layer order are the file's
+func TestFmtKeepsComments(t *testing.T) {
+	r, err := newRun(DefaultOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmtTechFile(t, r, []byte(handTechFile)); string(got) != handTechFileFormatted {
+		t.Errorf("formatted:\n%s\nwant:\n%s", got, handTechFileFormatted)
+	}
+
+	golden, err := os.ReadFile("../../sg13g2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmtTechFile(t, r, golden); !bytes.Equal(got, golden) {
+		t.Errorf("formatting the generated sg13g2.txt changed it:\n%s", got)
+	}
+}
@@ -0,0 +1,36 @@
+// Layers of a GDS layout
+//
+// Only the layer/datatype pairs the shapes of a layout are drawn on are
+// collected, enough to check a techfile against a design. pkg/gds streams
+// the records, so full-chip layouts are scanned in constant memory.
+
+package techgen
+
+import (
+	"context"
+	"io"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+type gdsLayerKey struct {
+	Layer, Datatype int
+}
+
+// Shape count per layer/datatype pair
+func readGDSLayers(ctx context.Context, r *run, name string) (map[gdsLayerKey]int, error) {
+	return decodeInput(ctx, r, name, decodeGDSLayers)
+}
+
+func decodeGDSLayers(file io.Reader, name string) (map[gdsLayerKey]int, error) {
+	used, err := gds.Layers(file)
+	if err != nil {
+		return nil, parseerr.InFile(name, err)
+	}
+	layers := make(map[gdsLayerKey]int, len(used))
+	for key, n := range used {
+		layers[gdsLayerKey(key)] = n
+	}
+	return layers, nil
+}
@@ -0,0 +1,300 @@
+// Package techgen is the build_3d_techfile pipeline: it parses the lyp,
+// LEF, stackup and stack config of a PDK, resolves the layer stack and
+// writes the GDS3D techfile and the exports.
+//
+// Main is the command line tool. Generate runs the same pipeline for
+// programs embedding it, e.g. a PDK dashboard or a KLayout helper daemon,
+// with the inputs as files, URLs or in memory:
+//
+//	res, err := techgen.Generate(ctx, techgen.Inputs{
+//		Lyp:   "sg13g2.lyp",
+//		LEF:   "sg13g2_tech.lef",
+//		Files: map[string][]byte{"sg13g2.lyp": lyp, "sg13g2_tech.lef": lef},
+//	})
+//
+// Options set what the flags of the command do, nil runs with their
+// defaults. A call keeps its settings and state to itself, so programs can
+// run Generate from many goroutines at once. Progress, e.g. a download or
+// a cached parse, comes back as notes among the warnings of the result,
+// nothing is printed.
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+)
+
+// Inputs of Generate, named like the flags of the command. Names are file
+// paths or http(s) URLs, or keys of Files.
+type Inputs struct {
+	Lyp     string // KLayout layer properties, required without another stack source
+	LEF     string // technology LEF, required without another stack source
+	HFSS    string // HFSS/SIwave stackup XML, optional
+	PDKJSON string // layer dump of a python PDK framework, optional
+	Config  string // stack config JSON, optional
+	Colors  string // per-layer color overrides, optional
+	BEOL    string // BEOL option of the stack config, optional
+	// GDS whose layers missing from the stack get hidden placeholders, optional
+	CatchAll string
+
+	// Other sources of the stack, used instead of the lyp/LEF/stackup
+	// inputs, at most one
+	FromIR       string // resolved stack IR, TOML or .json
+	FromTechFile string // existing GDS3D techfile
+	From         string // format:file of a registered input format
+	Template     string // generic stack template, see -template
+
+	// Extra stack variants as -variant takes them, name:op,op,...
+	Variants []string
+	// Extra outputs as -export names them, e.g. gltf, see Output.Exports
+	Exports []string
+	// Name of the techfile, as -o, the outputs are named after it
+	Name string
+
+	Clock   Clock    // time stamp of the header, nil for the system clock
+	Options *Options // flags of the run, nil for DefaultOptions
+
+	// Contents of inputs by name, read instead of the file system, e.g.
+	// uploads. Profiles a config extends are looked up here too.
+	Files map[string][]byte
+}
+
+// Result of Generate
+type Result struct {
+	TechFile []byte    // the GDS3D techfile, as -o writes it
+	Stack    ir.Stack  // the resolved stack, as -export ir-json writes it
+	Outputs  []Output  // every techfile of the run, the first is TechFile
+	Warnings []Warning // of all stages and the progress notes, the command prints them
+}
+
+// Output is one techfile of a run: of the stack, a variant or a corner
+type Output struct {
+	Name     string            // file name, Inputs.Name with the suffix of the variant or corner
+	TechFile []byte            // the GDS3D techfile
+	Stack    ir.Stack          // the resolved stack
+	Sections map[string][]byte // with Options.Split the techfile of each section by file name
+	Exports  map[string][]byte // of Inputs.Exports by file name, e.g. sg13g2.gltf
+}
+
+// Generate builds the techfiles of inputs, the same ones the command
+// writes for them with the flags of inputs.Options
+func Generate(ctx context.Context, inputs Inputs) (Result, error) {
+	opts := DefaultOptions()
+	if inputs.Options != nil {
+		opts = *inputs.Options
+	}
+	r, err := newRun(opts, inputs.Clock)
+	if err != nil {
+		return Result{}, err
+	}
+	r.files = inputs.Files
+
+	g, err := r.generate(ctx, inputs)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Warnings: g.parseWarnings}
+	for _, out := range g.outputs {
+		res.Warnings = append(res.Warnings, out.warnings...)
+		if out.err != nil {
+			return Result{}, out.err
+		}
+		o := Output{Name: out.path, Stack: irStack(out.stack)}
+		written := r.writtenStack(out.stack)
+		var index []string
+		if r.Split {
+			o.Sections = map[string][]byte{}
+			for _, section := range splitSections(out.path, written) {
+				var techFile bytes.Buffer
+				if err := encodeTechFile(&techFile, r, section.layers, nil); err != nil {
+					return Result{}, err
+				}
+				o.Sections[section.path] = techFile.Bytes()
+				index = append(index, filepath.Base(section.path))
+			}
+		}
+		var techFile bytes.Buffer
+		if err := encodeTechFile(&techFile, r, written, index); err != nil {
+			return Result{}, err
+		}
+		o.TechFile = techFile.Bytes()
+		warnings := append(slices.Clip(g.parseWarnings), out.warnings...)
+		if o.Exports, err = renderExports(ctx, r, g.exports, out.path, out.stack, warnings); err != nil {
+			return Result{}, err
+		}
+		res.Outputs = append(res.Outputs, o)
+	}
+	res.TechFile, res.Stack = res.Outputs[0].TechFile, res.Outputs[0].Stack
+	res.Warnings = append(res.Warnings, r.takeNotes()...)
+	return res, nil
+}
+
+// What the pipeline hands to Generate and the command to write: the
+// stack of every variant and the inputs it was built from
+type generated struct {
+	config        *stackConfig
+	inputs        []bundleInput // given, hashed into the header
+	exports       []string      // names of the extra outputs, with their companions
+	parseWarnings []Warning     // of the inputs, they hold for every output
+	outputs       []generatedStack
+}
+
+type generatedStack struct {
+	path     string    // Inputs.Name of the variant
+	stack    Stackup   // resolved, hooks run
+	warnings []Warning // of the variant itself
+	err      error     // from the hooks or the checks, the stack is not written
+}
+
+// The pipeline: parses the inputs once and resolves the stack of every
+// variant and corner
+func (r *run) generate(ctx context.Context, inputs Inputs) (*generated, error) {
+	if inputs.Name == "" {
+		inputs.Name = "sg13g2.txt"
+	}
+	variants := []variant{{}}
+	for _, spec := range inputs.Variants {
+		v, err := parseVariant(spec)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	sources := btoi(inputs.FromIR != "") + btoi(inputs.FromTechFile != "") + btoi(inputs.Template != "") + btoi(inputs.From != "")
+	if sources > 1 {
+		return nil, errors.New("use only one of -from-ir, -from-techfile, -from and -template")
+	}
+	if sources == 0 && (inputs.Lyp == "" || inputs.LEF == "") {
+		return nil, errors.New("techgen: Lyp and LEF are required")
+	}
+
+	// The config comes first, its aliases are needed to parse the inputs
+	g := &generated{}
+	var err error
+	if inputs.Config != "" {
+		g.config, err = parseStackConfig(ctx, r, inputs.Config)
+		if err != nil {
+			return nil, fmt.Errorf("parsing stack config: %w", err)
+		}
+		r.useConfig(g.config)
+	}
+	g.exports = exportNames(inputs.Exports, g.config)
+
+	var in stackInputs
+	LayerStack := defaultLayerStack()
+	g.parseWarnings = epochWarnings(r.NoTimestamp, r.clock)
+	assignShortkeys(LayerStack)
+	switch {
+	case inputs.FromIR != "":
+		LayerStack, err = readIRFile(ctx, r, inputs.FromIR)
+		if err != nil {
+			return nil, fmt.Errorf("reading IR file: %w", err)
+		}
+		r.progress("Read resolved stack: %s (%d layers)", inputs.FromIR, len(LayerStack))
+		// Nothing left to merge, only variants and via interpolation apply
+		in.lef = &LEFFile{}
+	case inputs.Template != "":
+		LayerStack, err = buildTemplateStack(inputs.Template)
+		if err != nil {
+			return nil, err
+		}
+		assignShortkeys(LayerStack)
+		r.progress("Built template: %s (%d layers)", inputs.Template, len(LayerStack))
+		if g.config == nil || g.config.Process == "" {
+			r.info.Process = "Generic template " + inputs.Template
+		}
+		in.lef = &LEFFile{}
+	case inputs.From != "":
+		LayerStack, err = readFromFormat(ctx, r, inputs.From)
+		if err != nil {
+			return nil, fmt.Errorf("reading stack: %w", err)
+		}
+		r.progress("Read stack: %s (%d layers)", inputs.From, len(LayerStack))
+		in.lef = &LEFFile{}
+	case inputs.FromTechFile != "":
+		LayerStack, err = readTechFile(ctx, r, inputs.FromTechFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading techfile: %w", err)
+		}
+		r.progress("Read techfile: %s (%d layers)", inputs.FromTechFile, len(LayerStack))
+		in.lef = &LEFFile{}
+	default:
+		var warnings []Warning
+		in, warnings, err = parseStackInputs(ctx, r, inputs.Lyp, inputs.LEF, inputs.HFSS, inputs.PDKJSON)
+		if err != nil {
+			return nil, err
+		}
+		g.parseWarnings = append(g.parseWarnings, warnings...)
+	}
+
+	in.config = g.config
+	in.applyAliases(r)
+	if inputs.BEOL != "" {
+		in.beol, err = selectBEOL(g.config, inputs.BEOL)
+		if err != nil {
+			return nil, err
+		}
+		r.info.Process += ", BEOL " + inputs.BEOL
+	}
+	err = parallel(
+		func() (err error) {
+			if inputs.Colors == "" {
+				return nil
+			}
+			if in.colors, err = parseColorFile(ctx, r, inputs.Colors); err != nil {
+				return fmt.Errorf("parsing color overrides: %w", err)
+			}
+			return nil
+		},
+		func() (err error) {
+			if inputs.CatchAll == "" {
+				return nil
+			}
+			if in.used, err = readGDSLayers(ctx, r, inputs.CatchAll); err != nil {
+				return fmt.Errorf("reading GDS: %w", err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Inputs of the run, hashed into the header and packed into a bundle
+	_, fromFile, _ := strings.Cut(inputs.From, ":")
+	given := []bundleInput{{"from-ir", inputs.FromIR}, {"from-techfile", inputs.FromTechFile}, {"from", fromFile}}
+	if sources == 0 {
+		given = []bundleInput{{"lyp", inputs.Lyp}, {"lef", inputs.LEF}, {"hfss", inputs.HFSS}, {"pdkjson", inputs.PDKJSON}}
+	}
+	given = append(given, bundleInput{"config", inputs.Config}, bundleInput{"colors", inputs.Colors}, bundleInput{"catch-all", inputs.CatchAll})
+	for _, input := range given {
+		if input.Path != "" {
+			g.inputs = append(g.inputs, input)
+		}
+	}
+	r.info.Inputs, err = hashInputs(ctx, r, g.inputs)
+	if err != nil {
+		return nil, fmt.Errorf("hashing inputs: %w", err)
+	}
+
+	// Inputs are parsed once, every variant gets its own copy of the stack
+	for _, v := range cornerVariants(variants, r.Corner) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		out := generatedStack{path: variantPath(inputs.Name, v)}
+		out.stack, out.warnings = resolveLayerStack(r, LayerStack, in, v)
+		if out.stack, out.err = runStackHooks(ctx, r.hooks, out.stack); out.err == nil {
+			out.err = out.stack.Validate()
+		}
+		g.outputs = append(g.outputs, out)
+	}
+	return g, nil
+}
@@ -0,0 +1,157 @@
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Calls with other options at the same time get what they get alone
+func TestGenerateConcurrent(t *testing.T) {
+	files := map[string][]byte{}
+	for _, name := range []string{"sg13g2.lyp", "sg13g2_tech.lef"} {
+		data, err := os.ReadFile("../../" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = data
+	}
+	um := DefaultOptions()
+	um.Unit = "um"
+	nm := DefaultOptions()
+	options := []*Options{&nm, &um}
+	inputs := func(opts *Options) Inputs {
+		return Inputs{
+			Lyp:     "sg13g2.lyp",
+			LEF:     "sg13g2_tech.lef",
+			Files:   files,
+			Clock:   FixedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+			Options: opts,
+		}
+	}
+
+	want := make([][]byte, len(options))
+	for i, opts := range options {
+		res, err := Generate(context.Background(), inputs(opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[i] = res.TechFile
+	}
+	if bytes.Equal(want[0], want[1]) {
+		t.Fatal("-unit um wrote the same techfile as nm")
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		for i, opts := range options {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := Generate(context.Background(), inputs(opts))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(res.TechFile, want[i]) {
+					t.Errorf("techfile of options %d changed when run concurrently", i)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// Corner all and Split give the techfiles the command writes for them
+func TestGenerateOutputs(t *testing.T) {
+	files := map[string][]byte{}
+	for _, name := range []string{"sg13g2.lyp", "sg13g2_tech.lef"} {
+		data, err := os.ReadFile("../../" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = data
+	}
+	opts := DefaultOptions()
+	opts.Corner = "all"
+	opts.Split = true
+	res, err := Generate(context.Background(), Inputs{
+		Lyp:      "sg13g2.lyp",
+		LEF:      "sg13g2_tech.lef",
+		Variants: []string{"nomim:-MIM"},
+		Name:     "ihp.txt",
+		Files:    files,
+		Options:  &opts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, o := range res.Outputs {
+		names = append(names, o.Name)
+		if len(o.Sections) == 0 {
+			t.Errorf("%s: no sections", o.Name)
+		}
+		for name := range o.Sections {
+			if !bytes.Contains(o.TechFile, []byte(name)) {
+				t.Errorf("%s: section %s not in the header", o.Name, name)
+			}
+		}
+	}
+	want := []string{"ihp_min.txt", "ihp_typ.txt", "ihp_max.txt", "ihp_nomim_min.txt", "ihp_nomim_typ.txt", "ihp_nomim_max.txt"}
+	if !slices.Equal(names, want) {
+		t.Errorf("outputs %q, want %q", names, want)
+	}
+	if !bytes.Equal(res.TechFile, res.Outputs[0].TechFile) {
+		t.Error("TechFile is not the first output")
+	}
+	if bytes.Equal(res.Outputs[0].TechFile, res.Outputs[2].TechFile) {
+		t.Error("min and max corner wrote the same techfile")
+	}
+}
+
+// -include and -exclude change what is written, not what the checks see
+func TestGenerateFilterKeepsChecks(t *testing.T) {
+	files := map[string][]byte{}
+	for _, name := range []string{"sg13g2.lyp", "sg13g2_tech.lef"} {
+		data, err := os.ReadFile("../../" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = data
+	}
+	checks := func(opts Options) ([]Warning, int) {
+		res, err := Generate(context.Background(), Inputs{Lyp: "sg13g2.lyp", LEF: "sg13g2_tech.lef", Files: files, Options: &opts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var warnings []Warning
+		for _, w := range res.Warnings {
+			if w.Severity != SeverityInfo {
+				warnings = append(warnings, w)
+			}
+		}
+		return warnings, len(res.Stack.Layers)
+	}
+
+	want, all := checks(DefaultOptions())
+	for _, filter := range []struct{ include, exclude string }{
+		{"", "Metal2"},
+		{"Via*", ""},
+	} {
+		opts := DefaultOptions()
+		opts.Include, opts.Exclude = filter.include, filter.exclude
+		got, written := checks(opts)
+		if written >= all {
+			t.Errorf("include %q, exclude %q: %d of %d layers written", filter.include, filter.exclude, written, all)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("include %q, exclude %q: warnings\n%v\nwant\n%v", filter.include, filter.exclude, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Layer groups of the techfile
+//
+// The techfile is written in sections, front end first, then metals, vias,
+// passives, dielectric slabs, packaging and placeholders, each under a
+// comment banner.
+// Every group has its own row of shortkeys so the keys of a group are next
+// to each other on the keyboard, vias sit under the metals they connect.
+// The keyboard is used up by then, packaging layers get keys from the
+// stack config.
+
+package techgen
+
+import "strings"
+
+type layerGroup struct {
+	Name  string // as used in the stack config and IR
+	Title string // section banner in the techfile
+	Keys  string // shortkeys handed out from the bottom of the stack up
+}
+
+var layerGroups = []layerGroup{
+	{"feol", "Front end: substrate, wells, active and poly", "asdfghjkl"},
+	{"metal", "Metals", "123456789"},
+	{"via", "Contacts and vias", "qwertyuio"},
+	{"passive", "Passives", "zxcvbnm"},
+	{"dielectric", "Dielectrics and passivation", "0p"},
+	{"packaging", "Packaging: RDL, UBM and bumps", ""},
+	{"other", "Other design layers, placeholders of -catch-all", ""},
+}
+
+func isLayerGroup(name string) bool {
+	for _, g := range layerGroups {
+		if g.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultGroup(layer Layer) string {
+	switch {
+	case layer.Dielectric:
+		return "dielectric"
+	case layer.Metal == 1:
+		return "metal"
+	case isViaLayer(layer):
+		return "via"
+	case layer.Name == "MIM" || strings.HasPrefix(layer.Name, "Res"):
+		return "passive"
+	}
+	return "feol"
+}
+
+func assignShortkeys(LayerStack []Layer) {
+	for _, g := range layerGroups {
+		keys := g.Keys
+		for i, l := range LayerStack {
+			if l.Group == g.Name && keys != "" {
+				LayerStack[i].Shortkey = keys[:1]
+				keys = keys[1:]
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Header and footer of the techfile
+//
+// Both are text/templates and can be replaced with -header and -footer to
+// put an organization's own boilerplate around the layers. The templates
+// see the fields of techFileInfo, e.g. {{.Process}} or {{.Date}}. The
+// default header lists the tool version and the inputs with their SHA-256.
+
+package techgen
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+)
+
+// Set at build time with -ldflags "-X github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/techgen.toolVersion=v1.2.3"
+var toolVersion = "dev"
+
+const defaultTechFileHeader = `# Autogenerated GDS3D techfile 
+# Process : {{.Process}} 
+# Author  : {{.Author}} 
+{{if .Date}}# Date    : {{.Date}}
+{{end}}# Tool    : build_3d_techfile {{.Version}}
+{{- range .Inputs}}
+# Input   : -{{.Flag}} {{.Path}} sha256:{{.SHA256}}
+{{- end}}
+{{- if .Z}}
+# Z       : {{.Z}}
+{{- end}}
+# 
+# Copyright (C) 2024 Jorgen Kragh Jakobsen <jkj@icworks.dk>
+# 
+# This program is free software; you can redistribute it and/or modify it
+# under the terms of the GNU General Public License as published by the Free
+# Software Foundation; either version 2 of the License, or (at your option)
+# any later version.
+# 
+# This program is distributed in the hope that it will be useful, but WITHOUT
+# ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+# FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+# more details.
+# 
+# You should have received a copy of the GNU General Public License along with
+# this program; if not, write to the Free Software Foundation, Inc., 51
+# Franklin Street, Fifth Floor, Boston, MA 02110-1301, USA.
+# 
+# SPDX-License-Identifier: {{.License}}
+{{- if .Sections}}
+# 
+# Sections:
+{{- range .Sections}}
+#   {{.}}
+{{- end}}
+{{- end}}
+
+`
+
+type techFileInfo struct {
+	Process  string
+	Author   string
+	Date     string
+	Version  string
+	License  string // SPDX identifier
+	Layers   int
+	Sections []string // section techfiles of a -split master
+	Inputs   []techFileInput
+	Z        string // -z-scale and -z-offset of the written heights
+}
+
+// Input file of the run with its hash, so a deployed techfile can be
+// traced back to the PDK release it was built from
+type techFileInput struct {
+	Flag   string
+	Path   string
+	SHA256 string
+}
+
+func hashInputs(ctx context.Context, r *run, inputs []bundleInput) ([]techFileInput, error) {
+	var hashed []techFileInput
+	for _, in := range inputs {
+		data, err := readInput(ctx, r, in.Path)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		hashed = append(hashed, techFileInput{in.Flag, in.Path, hex.EncodeToString(sum[:])})
+	}
+	return hashed, nil
+}
+
+// Process, author and license can be changed in the stack config
+var defaultTechFileInfo = techFileInfo{
+	Process: "IHP 130nm open source",
+	Author:  "Jørgen Kragh Jakobsen",
+	License: "GPL-2.0-or-later",
+}
+
+func loadTechFileTemplate(name, filePath string) (*template.Template, error) {
+	text, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+	// Run it once so unknown fields show up before any file is written
+	if err := t.Execute(io.Discard, defaultTechFileInfo); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return t, nil
+}
+
+// What the header and footer templates of a techfile see
+func (r *run) templateInfo(LayerStack []Layer, sections []string) techFileInfo {
+	info := r.info
+	if !r.NoTimestamp {
+		info.Date = r.now.Format("2006-01-02 15:04:05")
+	}
+	info.Version = toolVersion
+	info.Layers = len(LayerStack)
+	info.Sections = sections
+	info.Z = r.zTransformNote()
+	return info
+}
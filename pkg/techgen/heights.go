@@ -0,0 +1,188 @@
+// Heights of layers the LEF gives no HEIGHT for, and the dielectric model
+//
+// Many technology LEFs only have THICKNESS. With -heights accumulate such
+// layers are stacked bottom up on the layers below them, each above the
+// highest top so far plus the dielectric gap below it. The vias between
+// them are interpolated as usual afterwards.
+//
+// The gap below a layer comes from the "ild" section of the stack config,
+// then from the defaults of the PDK, then from -ild:
+/*
+
+"ild": {
+  "pdk": "sg13g2",
+  "default": 0.6,
+  "gaps": { "Metal2": 0.54, "TopMetal2": 2.8 },
+  "slabs": true
+}
+*/
+// "pdk" picks the built-in defaults, "none" drops them. With "slabs" every
+// gap between two metals is also written as a dielectric slab, ILD_<metal
+// above>, see dielectrics.go.
+//
+// The total BEOL height from the foundry documents catches unit mistakes
+// and missing layers: the resolved top of the highest metal, or of the
+// "top" layer, is checked against it.
+/*
+
+"beol_height": { "height": 14.16, "tolerance": 0.2, "top": "TopMetal2" }
+*/
+// The tolerance is in um and defaults to 2% of the height.
+
+package techgen
+
+import (
+	"fmt"
+	"math"
+)
+
+type beolHeightConfig struct {
+	Height    float64  `json:"height"`
+	Tolerance *float64 `json:"tolerance"`
+	Top       string   `json:"top"`
+}
+
+const defaultBEOLHeightTolerance = 0.02 // of the height
+
+type ildConfig struct {
+	PDK     string             `json:"pdk"`
+	Default *float64           `json:"default"`
+	Gaps    map[string]float64 `json:"gaps"`
+	Slabs   bool               `json:"slabs"`
+}
+
+// Dielectric below each metal in um, from the published process stacks
+var pdkILDGaps = map[string]map[string]float64{
+	"sg13g2": {
+		"Metal1":    0.51,
+		"Metal2":    0.55,
+		"Metal3":    0.55,
+		"Metal4":    0.55,
+		"Metal5":    0.55,
+		"TopMetal1": 0.83,
+		"TopMetal2": 3.0,
+	},
+}
+
+const defaultILDPDK = "sg13g2"
+
+func (ild *ildConfig) validate() error {
+	if ild.PDK != "" && ild.PDK != "none" && pdkILDGaps[ild.PDK] == nil {
+		return fmt.Errorf("unknown pdk %q", ild.PDK)
+	}
+	if ild.Default != nil && *ild.Default < 0.0 {
+		return fmt.Errorf("default %g, expected a thickness in um", *ild.Default)
+	}
+	for name, gap := range ild.Gaps {
+		if gap < 0.0 {
+			return fmt.Errorf("gap below %s is %g, expected a thickness in um", name, gap)
+		}
+	}
+	return nil
+}
+
+// Dielectric thickness below layer name, ild may be nil. Layers neither
+// the config nor the PDK defaults have a gap for get fallback, from -ild.
+func ildGap(ild *ildConfig, name string, fallback float64) float64 {
+	pdk := defaultILDPDK
+	if ild != nil {
+		if gap, ok := ild.Gaps[name]; ok {
+			return gap
+		}
+		if ild.PDK != "" {
+			pdk = ild.PDK
+		}
+	}
+	if gap, ok := pdkILDGaps[pdk][name]; ok {
+		return gap
+	}
+	if ild != nil && ild.Default != nil {
+		return *ild.Default
+	}
+	return fallback
+}
+
+func update_layerstack_accumulate(r *run, LayerStack []Layer, lefLayers []LefLayer, ild *ildConfig) {
+	missing := map[string]bool{}
+	for _, l := range lefLayers {
+		if l.Thickness > 0.0 && !l.HasHeight {
+			missing[l.Name] = true
+		}
+	}
+
+	top, below := 0.0, ""
+	for i, l := range LayerStack {
+		if l.ZFrom != "" || l.Dielectric || isViaLayer(l) || l.Name == "Substrate" {
+			continue
+		}
+		if missing[l.Name] {
+			gap := ildGap(ild, l.Name, r.ILD)
+			LayerStack[i].Height = top + gap
+			LayerStack[i].ZSource = fmt.Sprintf("accumulated on %s + %g um", below, gap)
+			r.progress("Accumulated %s: height %g um on %s", l.Name, roundUm(LayerStack[i].Height), below)
+		}
+		if LayerStack[i].Thickness > 0.0 && layerTop(LayerStack[i]) > top {
+			top, below = layerTop(LayerStack[i]), l.Name
+		}
+	}
+}
+
+// A slab for every gap between two metals, in stack order
+func ildSlabs(LayerStack []Layer, ild *ildConfig) []dielectricConfig {
+	if ild == nil || !ild.Slabs {
+		return nil
+	}
+	var slabs []dielectricConfig
+	below := ""
+	for _, l := range LayerStack {
+		if l.Metal != 1 || l.ZFrom != "" {
+			continue
+		}
+		if below != "" {
+			slabs = append(slabs, dielectricConfig{Name: "ILD_" + l.Name, Between: []string{below, l.Name}})
+		}
+		below = l.Name
+	}
+	return slabs
+}
+
+func (b *beolHeightConfig) validate() error {
+	if b.Height <= 0.0 {
+		return fmt.Errorf("height %g, expected a positive height in um", b.Height)
+	}
+	if b.Tolerance != nil && *b.Tolerance < 0.0 {
+		return fmt.Errorf("tolerance %g, expected a tolerance in um", *b.Tolerance)
+	}
+	return nil
+}
+
+// Packaging and backside layers are not part of the BEOL
+func checkBEOLHeight(LayerStack []Layer, b *beolHeightConfig) []Warning {
+	var warnings []Warning
+	if b == nil {
+		return nil
+	}
+	top, name := 0.0, ""
+	if b.Top != "" {
+		i := layerIndex(LayerStack, b.Top)
+		if i < 0 {
+			warnings = append(warnings, warn("unknown-layer", "beol height: no layer %s in the stack", b.Top))
+			return warnings
+		}
+		top, name = layerTop(LayerStack[i]), b.Top
+	} else {
+		for _, l := range LayerStack {
+			if l.Metal == 1 && l.ZFrom == "" && l.Group == "metal" && l.Height >= 0.0 && layerTop(l) > top {
+				top, name = layerTop(l), l.Name
+			}
+		}
+	}
+	tolerance := b.Height * defaultBEOLHeightTolerance
+	if b.Tolerance != nil {
+		tolerance = *b.Tolerance
+	}
+	if math.Abs(top-b.Height) > tolerance {
+		warnings = append(warnings, issue("beol-height", []string{name}, "beol height: top of %s at %g um, expected %g +- %g um", name, top, b.Height, roundUm(tolerance)))
+	}
+	return warnings
+}
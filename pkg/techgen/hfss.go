@@ -0,0 +1,34 @@
+// Layers from stackup XML override the LEF z, see pkg/stackup
+
+package techgen
+
+import (
+	"context"
+	"io"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/stackup"
+)
+
+type HFSSLayer = stackup.Layer
+
+func parseHFSSFile(ctx context.Context, r *run, filePath string) ([]HFSSLayer, []Warning, error) {
+	return decodeCached(ctx, r, "hfss", "", filePath, decodeHFSSFile)
+}
+
+func decodeHFSSFile(file io.Reader, filePath string) ([]HFSSLayer, []Warning, error) {
+
+	layers, err := stackup.Parse(file)
+	if err != nil {
+		return nil, nil, parseerr.InFile(filePath, err)
+	}
+	return layers, nil, nil
+}
+
+func update_layerstack_hfss(LayerStack []Layer, layers *layerRegistry, layer HFSSLayer) {
+	for _, i := range layers.named(layer.Name) {
+		LayerStack[i].Height = layer.Height
+		LayerStack[i].Thickness = layer.Thick
+		LayerStack[i].ZSource = "stackup xml " + layer.Name
+	}
+}
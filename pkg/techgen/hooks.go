@@ -0,0 +1,42 @@
+// Hooks of the hook package run with -hook between resolving and writing
+
+package techgen
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/hook"
+)
+
+func parseHookList(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := hook.Lookup(name); !ok {
+			return nil, fmt.Errorf("unknown hook %s (registered: %s)", name, strings.Join(hook.Names(), ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Runs the hooks, names from -hook in the order they run, on the stack as
+// IR, the layers they leave are the stack
+func runStackHooks(ctx context.Context, hooks []string, stack Stackup) (Stackup, error) {
+	if len(hooks) == 0 {
+		return stack, nil
+	}
+	s := irStack(stack)
+	for _, name := range hooks {
+		fn, _ := hook.Lookup(name)
+		if err := fn(ctx, &s); err != nil {
+			return nil, fmt.Errorf("hook %s: %w", name, err)
+		}
+	}
+	return irLayers(s), nil
+}
@@ -0,0 +1,303 @@
+// Opening of input files
+//
+// All parsers open their inputs through openInput so the files can also be
+// read straight out of a PDK release archive (zip, tar, tar.gz) without
+// extracting it first, or fetched from a http(s) URL, e.g. a raw GitHub link
+// into IHP-Open-PDK. Downloads are cached in the user cache directory.
+//
+// The parsers themselves decode an io.Reader, decodeInput opens the file
+// for them, so they work as well on data in memory or a network stream.
+
+package techgen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+)
+
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "https://") || strings.HasPrefix(name, "http://")
+}
+
+// Opens name: a file given in memory, a download, a member of -archive or
+// a file
+func openInput(ctx context.Context, r *run, name string) (io.ReadCloser, error) {
+	if data, ok := r.files[name]; ok {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	if isURL(name) {
+		cached, err := fetchCached(ctx, r, name)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(cached)
+	}
+	if r.Archive != "" {
+		archive := r.path(r.Archive)
+		if isURL(archive) {
+			cached, err := fetchCached(ctx, r, archive)
+			if err != nil {
+				return nil, err
+			}
+			archive = cached
+		}
+		member, err := openArchiveMember(r, archive, name)
+		if err == nil {
+			return member, nil
+		}
+		// Allow mixing archive inputs with local files, e.g. a custom stackup XML
+		if _, statErr := os.Stat(r.path(name)); statErr != nil {
+			return nil, err
+		}
+	}
+	return os.Open(r.path(name))
+}
+
+// Opens name and hands it to decode, with name for the messages. Reading
+// fails with the context error once ctx is done, e.g. on Ctrl-C.
+func decodeInput[T any](ctx context.Context, r *run, name string, decode func(file io.Reader, name string) (T, error)) (T, error) {
+	file, err := openInput(ctx, r, name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer file.Close()
+	return decode(ctxio.NewReader(ctx, file), name)
+}
+
+// Runs the jobs at once, e.g. the parsers of independent inputs that on
+// network file systems mostly wait. Returns the error of the first job
+// that failed in argument order, so the message does not depend on timing.
+func parallel(jobs ...func() error) error {
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = job()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A member matches when its path equals name or ends with /name, so both
+// "sg13g2.lyp" and "libs.tech/klayout/tech/sg13g2.lyp" can be used.
+func archiveMemberMatch(member, name string) bool {
+	member = strings.TrimPrefix(path.Clean(member), "./")
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	return member == name || strings.HasSuffix(member, "/"+name)
+}
+
+func openArchiveMember(r *run, archive, name string) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(archive, ".zip"):
+		return openZipMember(r, archive, name)
+	case strings.HasSuffix(archive, ".tar.gz"), strings.HasSuffix(archive, ".tgz"):
+		return openTarMember(r, archive, name, true)
+	case strings.HasSuffix(archive, ".tar"):
+		return openTarMember(r, archive, name, false)
+	}
+	return nil, fmt.Errorf("%s: unsupported archive type (zip, tar, tar.gz)", archive)
+}
+
+type zipMember struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (m zipMember) Close() error {
+	m.ReadCloser.Close()
+	return m.archive.Close()
+}
+
+func openZipMember(run *run, archive, name string) (io.ReadCloser, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !archiveMemberMatch(f.Name, name) {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			zr.Close()
+			return nil, err
+		}
+		run.progress("Found %s in %s: %s", name, archive, f.Name)
+		return zipMember{r, zr}, nil
+	}
+	zr.Close()
+	return nil, fmt.Errorf("%s: no member matching %s", archive, name)
+}
+
+// Tar archives can only be read sequentially, so the matching member is
+// read into memory and the archive closed again.
+func openTarMember(run *run, archive, name string, gzipped bool) (io.ReadCloser, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if gzipped {
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", archive, err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", archive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !archiveMemberMatch(hdr.Name, name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", archive, hdr.Name, err)
+		}
+		run.progress("Found %s in %s: %s", name, archive, hdr.Name)
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return nil, fmt.Errorf("%s: no member matching %s", archive, name)
+}
+
+// The cache file keeps the base name of the URL path so archive type
+// detection by extension still works on the cached copy. Query strings,
+// e.g. access tokens, only go into the hash.
+func cachePathForURL(rawURL string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	base := path.Base(u.Path)
+	if base == "/" || base == "." {
+		base = "download"
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	name := hex.EncodeToString(sum[:8]) + "-" + base
+	return filepath.Join(dir, "build_3d_techfile", name), nil
+}
+
+// A download of a run, the inputs of the same URL wait for the first one
+// instead of fetching it again, e.g. the lyp and LEF of an -archive URL
+// parsed at once
+type download struct {
+	done   chan struct{}
+	cached string
+	err    error
+}
+
+// The cache file of rawURL, downloaded once per run
+func fetchCached(ctx context.Context, r *run, rawURL string) (string, error) {
+	r.downloadMu.Lock()
+	d, started := r.downloads[rawURL]
+	if !started {
+		d = &download{done: make(chan struct{})}
+		if r.downloads == nil {
+			r.downloads = map[string]*download{}
+		}
+		r.downloads[rawURL] = d
+	}
+	r.downloadMu.Unlock()
+	if started {
+		select {
+		case <-d.done:
+			return d.cached, d.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	d.cached, d.err = fetchURL(ctx, r, rawURL)
+	close(d.done)
+	return d.cached, d.err
+}
+
+// Downloads rawURL into the cache unless it is there already, the download
+// is aborted once ctx is done
+func fetchURL(ctx context.Context, r *run, rawURL string) (string, error) {
+	cached, err := cachePathForURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(cached); err == nil && !r.Refresh {
+		r.progress("Using cached %s: %s", rawURL, cached)
+		return cached, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		return "", err
+	}
+
+	r.progress("Downloading %s", rawURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+
+	// Download next to the cache entry and rename, so an aborted transfer
+	// never leaves a truncated file that later runs would trust
+	tmp, err := os.CreateTemp(filepath.Dir(cached), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("%s: %w", rawURL, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), cached); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
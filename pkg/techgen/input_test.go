@@ -0,0 +1,87 @@
+package techgen
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCachePathForURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		base string
+	}{
+		{"https://example.com/pdk/sg13g2.lyp", "sg13g2.lyp"},
+		{"https://example.com/pdk.tar.gz?token=x&raw=1", "pdk.tar.gz"},
+		{"https://example.com/", "download"},
+	}
+	for _, tt := range tests {
+		cached, err := cachePathForURL(tt.url)
+		if err != nil {
+			t.Fatalf("cachePathForURL(%q): %v", tt.url, err)
+		}
+		_, base, _ := strings.Cut(filepath.Base(cached), "-")
+		if base != tt.base {
+			t.Errorf("cachePathForURL(%q) = %s, want base name %s", tt.url, cached, tt.base)
+		}
+	}
+	// The query string still tells the entries apart
+	a, _ := cachePathForURL("https://example.com/pdk.zip?v=1")
+	b, _ := cachePathForURL("https://example.com/pdk.zip?v=2")
+	if a == b {
+		t.Errorf("URLs differing in the query share the cache entry %s", a)
+	}
+}
+
+func TestFetchCachedOnce(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("archive"))
+	}))
+	defer server.Close()
+
+	opts := DefaultOptions()
+	opts.Refresh = true // a cached file would hide a second download
+	r, err := newRun(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := server.URL + "/pdk.zip"
+	paths := make([]string, 4)
+	var jobs []func() error
+	for i := range paths {
+		jobs = append(jobs, func() (err error) {
+			paths[i], err = fetchCached(context.Background(), r, url)
+			return err
+		})
+	}
+	if err := parallel(jobs...); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests for the inputs of one URL, want 1", n)
+	}
+	for _, p := range paths[1:] {
+		if p != paths[0] {
+			t.Errorf("fetchCached = %s and %s for the same URL", paths[0], p)
+		}
+	}
+	if data, err := os.ReadFile(paths[0]); err != nil || string(data) != "archive" {
+		t.Errorf("cached file = %q, %v, want archive", data, err)
+	}
+
+	// A canceled download fails with the context error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fetchCached(ctx, r, url+"?canceled"); err == nil {
+		t.Error("fetchCached with a canceled context succeeded")
+	}
+}
@@ -0,0 +1,308 @@
+// Versioned TOML intermediate representation of the resolved stack
+//
+// The IR holds everything the techfile and the exporters need, so
+// -from-ir can regenerate them without the lyp, LEF and stackup inputs:
+//
+//	build_3d_techfile -export ir            # writes sg13g2.ir.toml
+//	build_3d_techfile -from-ir sg13g2.ir.toml -export svg
+//
+// The fields are those of ir.Stack, the contract for external tools, which
+// -export ir-json writes as JSON and -from-ir reads back from a .json too.
+// Floats are written with full precision so a round trip is exact. Only
+// the TOML subset written here is read back: top level keys, [[layer]]
+// tables, strings, numbers and string arrays.
+
+package techgen
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/format"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ir"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+// Version of the IR written, see ir.SchemaVersion for what it covers
+const irVersion = ir.SchemaVersion
+
+// The stack as the IR has it
+func irStack(LayerStack []Layer) ir.Stack {
+	layers := make([]ir.Layer, 0, len(LayerStack))
+	for _, l := range LayerStack {
+		layers = append(layers, ir.Layer{
+			Name:        l.Name,
+			AltName:     l.altName,
+			GDSLayer:    l.GDSNumber,
+			GDSDatatype: l.GDSDatatype,
+			Color:       l.Color,
+			Height:      l.Height,
+			Thickness:   l.Thickness,
+			Metal:       l.Metal == 1,
+			Filter:      l.Filter,
+			Show:        l.Show,
+			Shortkey:    l.Shortkey,
+			Group:       l.Group,
+			ZFrom:       l.ZFrom,
+			SharedLook:  l.SharedLook,
+			Dielectric:  l.Dielectric,
+			Mask:        l.Mask,
+			Derived:     l.Derived,
+			Tolerance:   l.Tolerance,
+			LEFConnects: l.LEFConnects,
+			ColorSource: l.ColorSource,
+			ZSource:     l.ZSource,
+		})
+	}
+	return ir.New(layers)
+}
+
+// The layers of an IR stack, grouped by type when it has no groups
+func irLayers(stack ir.Stack) []Layer {
+	LayerStack := make([]Layer, 0, len(stack.Layers))
+	for _, l := range stack.Layers {
+		layer := Layer{
+			Name:        l.Name,
+			altName:     l.AltName,
+			GDSNumber:   l.GDSLayer,
+			GDSDatatype: l.GDSDatatype,
+			Color:       l.Color,
+			Height:      l.Height,
+			Thickness:   l.Thickness,
+			Metal:       btoi(l.Metal),
+			Filter:      l.Filter,
+			Show:        l.Show,
+			Shortkey:    l.Shortkey,
+			Group:       l.Group,
+			ZFrom:       l.ZFrom,
+			SharedLook:  l.SharedLook,
+			Dielectric:  l.Dielectric,
+			Mask:        l.Mask,
+			Derived:     l.Derived,
+			Tolerance:   l.Tolerance,
+			LEFConnects: l.LEFConnects,
+			ColorSource: l.ColorSource,
+			ZSource:     l.ZSource,
+		}
+		if layer.Group == "" {
+			layer.Group = defaultGroup(layer)
+		}
+		LayerStack = append(LayerStack, layer)
+	}
+	return LayerStack
+}
+
+func writeIRJSONExport(w io.Writer, stack exportStack) error {
+	return irStack(stack.Layers).WriteJSON(w)
+}
+
+func writeIRExport(w io.Writer, stack exportStack) error {
+	doc := irStack(stack.Layers)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Resolved layer stack of %s, generated by build_3d_techfile\n", stack.Stem)
+	fmt.Fprintf(bw, "# Regenerate the techfile with: build_3d_techfile -from-ir %s.ir.toml\n\n", stack.Stem)
+	fmt.Fprintf(bw, "version = %d\n", doc.SchemaVersion)
+	fmt.Fprintf(bw, "units = %s\n", strconv.Quote(doc.Units))
+	for _, l := range doc.Layers {
+		fmt.Fprintf(bw, "\n[[layer]]\n")
+		fmt.Fprintf(bw, "name = %s\n", strconv.Quote(l.Name))
+		fmt.Fprintf(bw, "alt_name = %s\n", strconv.Quote(l.AltName))
+		fmt.Fprintf(bw, "gds_layer = %d\n", l.GDSLayer)
+		fmt.Fprintf(bw, "gds_datatype = %d\n", l.GDSDatatype)
+		fmt.Fprintf(bw, "color = %s\n", strconv.Quote(l.Color))
+		fmt.Fprintf(bw, "height = %s\n", formatDecimal(l.Height))
+		fmt.Fprintf(bw, "thickness = %s\n", formatDecimal(l.Thickness))
+		fmt.Fprintf(bw, "metal = %t\n", l.Metal)
+		fmt.Fprintf(bw, "filter = %s\n", formatDecimal(l.Filter))
+		fmt.Fprintf(bw, "show = %t\n", l.Show)
+		fmt.Fprintf(bw, "shortkey = %s\n", strconv.Quote(l.Shortkey))
+		fmt.Fprintf(bw, "group = %s\n", strconv.Quote(l.Group))
+		fmt.Fprintf(bw, "z_from = %s\n", strconv.Quote(l.ZFrom))
+		fmt.Fprintf(bw, "shared_look = %t\n", l.SharedLook)
+		fmt.Fprintf(bw, "dielectric = %t\n", l.Dielectric)
+		fmt.Fprintf(bw, "mask = %d\n", l.Mask)
+		fmt.Fprintf(bw, "derived = %s\n", strconv.Quote(l.Derived))
+		fmt.Fprintf(bw, "tolerance = %s\n", formatDecimal(l.Tolerance))
+		var connects []string
+		for _, name := range l.LEFConnects {
+			connects = append(connects, strconv.Quote(name))
+		}
+		fmt.Fprintf(bw, "lef_connects = [%s]\n", strings.Join(connects, ", "))
+		fmt.Fprintf(bw, "color_source = %s\n", strconv.Quote(l.ColorSource))
+		fmt.Fprintf(bw, "z_source = %s\n", strconv.Quote(l.ZSource))
+	}
+	return bw.Flush()
+}
+
+func readIRFile(ctx context.Context, r *run, name string) ([]Layer, error) {
+	if strings.HasSuffix(name, ".json") {
+		return decodeInput(ctx, r, name, decodeIRJSONFile)
+	}
+	return decodeInput(ctx, r, name, decodeIRFile)
+}
+
+// Reads spec, format:file, with a parser registered with the format package
+func readFromFormat(ctx context.Context, r *run, spec string) ([]Layer, error) {
+	name, path, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("%s: expected format:file", spec)
+	}
+	p, ok := format.LookupParser(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown input format %s (registered: %s)", name, strings.Join(format.ParserNames(), ", "))
+	}
+	stack, err := decodeInput(ctx, r, path, func(file io.Reader, path string) (ir.Stack, error) {
+		return p.Parse(ctx, file, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(stack.Layers) == 0 {
+		return nil, &parseerr.Error{File: path, Err: fmt.Errorf("%w: no layers", parseerr.ErrMissingSection)}
+	}
+	return irLayers(stack), nil
+}
+
+func decodeIRJSONFile(file io.Reader, name string) ([]Layer, error) {
+	stack, err := ir.ReadJSON(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(stack.Layers) == 0 {
+		return nil, &parseerr.Error{File: name, Err: fmt.Errorf("%w: no layers", parseerr.ErrMissingSection)}
+	}
+	return irLayers(stack), nil
+}
+
+func decodeIRFile(file io.Reader, name string) ([]Layer, error) {
+
+	var stack ir.Stack
+	var layer *ir.Layer
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "[[layer]]" {
+			stack.Layers = append(stack.Layers, ir.Layer{Show: true})
+			layer = &stack.Layers[len(stack.Layers)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, &parseerr.Error{File: name, Line: lineNo, Err: fmt.Errorf("expected key = value")}
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := setIRValue(&stack, layer, key, value); err != nil {
+			perr := &parseerr.Error{File: name, Line: lineNo, Err: err}
+			if layer != nil {
+				perr.Layer = layer.Name
+			}
+			return nil, perr
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := stack.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(stack.Layers) == 0 {
+		return nil, &parseerr.Error{File: name, Err: fmt.Errorf("%w: no [[layer]]", parseerr.ErrMissingSection)}
+	}
+	return irLayers(stack), nil
+}
+
+func setIRValue(stack *ir.Stack, layer *ir.Layer, key, value string) error {
+	var err error
+	if layer == nil {
+		switch key {
+		case "version":
+			stack.SchemaVersion, err = strconv.Atoi(value)
+		case "units":
+			stack.Units, err = strconv.Unquote(value)
+		default:
+			return fmt.Errorf("unknown key %s", key)
+		}
+		return err
+	}
+	switch key {
+	case "name":
+		layer.Name, err = strconv.Unquote(value)
+	case "alt_name":
+		layer.AltName, err = strconv.Unquote(value)
+	case "gds_layer":
+		layer.GDSLayer, err = strconv.Atoi(value)
+	case "gds_datatype":
+		layer.GDSDatatype, err = strconv.Atoi(value)
+	case "color":
+		layer.Color, err = strconv.Unquote(value)
+	case "height":
+		layer.Height, err = strconv.ParseFloat(value, 64)
+	case "thickness":
+		layer.Thickness, err = strconv.ParseFloat(value, 64)
+	case "metal":
+		layer.Metal, err = strconv.ParseBool(value)
+	case "filter":
+		layer.Filter, err = strconv.ParseFloat(value, 64)
+	case "show":
+		layer.Show, err = strconv.ParseBool(value)
+	case "shortkey":
+		layer.Shortkey, err = strconv.Unquote(value)
+	case "group":
+		layer.Group, err = strconv.Unquote(value)
+		if err == nil && !isLayerGroup(layer.Group) {
+			return fmt.Errorf("unknown group %s", value)
+		}
+	case "z_from":
+		layer.ZFrom, err = strconv.Unquote(value)
+	case "shared_look":
+		layer.SharedLook, err = strconv.ParseBool(value)
+	case "dielectric":
+		layer.Dielectric, err = strconv.ParseBool(value)
+	case "mask":
+		layer.Mask, err = strconv.Atoi(value)
+	case "derived":
+		layer.Derived, err = strconv.Unquote(value)
+	case "tolerance":
+		layer.Tolerance, err = strconv.ParseFloat(value, 64)
+	case "lef_connects":
+		layer.LEFConnects, err = parseIRStrings(value)
+	case "color_source":
+		layer.ColorSource, err = strconv.Unquote(value)
+	case "z_source":
+		layer.ZSource, err = strconv.Unquote(value)
+	default:
+		return fmt.Errorf("unknown layer key %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("bad value for %s: %s: %w", key, value, err)
+	}
+	return nil
+}
+
+func parseIRStrings(value string) ([]string, error) {
+	inner, ok := strings.CutPrefix(value, "[")
+	inner, ok2 := strings.CutSuffix(inner, "]")
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("expected [...]")
+	}
+	var list []string
+	for _, item := range strings.Split(inner, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		s, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
@@ -0,0 +1,41 @@
+package techgen_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/techgen"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/techgen/techgentest"
+)
+
+// The IR exports read back with -from-ir make the same techfile, but for
+// the inputs named in its header
+func TestIRRoundTrip(t *testing.T) {
+	inputs := regexp.MustCompile(`(?m)^# Input   : .*\n`)
+	withoutInputs := func(techFile []byte) []byte { return inputs.ReplaceAll(techFile, nil) }
+
+	out := techgentest.Fixtures[0].Output(t, "ir", "ir-json")
+	for _, file := range []string{"sg13g2.ir.toml", "sg13g2.ir.json"} {
+		data, ok := out.Exports[file]
+		if !ok {
+			t.Fatalf("no %s", file)
+		}
+		res, err := techgen.Generate(context.Background(), techgen.Inputs{
+			FromIR: file,
+			Name:   "sg13g2.txt",
+			Clock:  techgentest.Clock,
+			Files:  map[string][]byte{file: data},
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if !bytes.Contains(res.Outputs[0].TechFile, []byte("# Input   : -from-ir "+file)) {
+			t.Errorf("%s not in the header", file)
+		}
+		if err := techgentest.Compare(withoutInputs(out.TechFile), withoutInputs(res.Outputs[0].TechFile)); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Merge of a base techfile with an overlay techfile
+//
+//	build_3d_techfile merge -o combined.txt sg13g2.txt mems.txt
+//
+// Adds the layers of the overlay, e.g. MEMS or custom passives, to a
+// standard PDK techfile. An overlay layer with the name of a base layer
+// replaces it. An overlay layer on the GDS number/datatype of another base
+// layer is a conflict, GDS3D would draw the shapes twice, and nothing is
+// written.
+//
+// The base file is written as it is, header, comments and layer order,
+// only replaced blocks change and the added ones follow its last line,
+// each with the comments it has in the overlay.
+
+package techgen
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+)
+
+func runMerge(ctx context.Context, args []string) {
+	opts := DefaultOptions()
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	flags.StringVar(&opts.Unit, "unit", opts.Unit, "Length unit of the techfiles: nm or um")
+	flags.StringVar(&opts.Format, "format", opts.Format, "Techfile key spelling of the replaced and added layers: legacy or strict")
+	outPath := flags.String("o", "merged.txt", "Output GDS3D techfile")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: build_3d_techfile merge [-o merged.txt] base.txt overlay.txt")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	r, err := newRun(opts, nil)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	merged, err := mergeTechFile(ctx, r, flags.Arg(0), flags.Arg(1), *outPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote merged techfile: %s (%d layers)\n", *outPath, merged)
+}
+
+// Writes base with overlay merged into it to outPath, returns the number
+// of layers written
+func mergeTechFile(ctx context.Context, r *run, basePath, overlayPath, outPath string) (int, error) {
+	base, err := readTechFileSource(ctx, r, basePath)
+	if err != nil {
+		return 0, fmt.Errorf("reading techfile: %w", err)
+	}
+	overlay, err := readTechFileSource(ctx, r, overlayPath)
+	if err != nil {
+		return 0, fmt.Errorf("reading techfile: %w", err)
+	}
+	LayerStack, from, conflicts := mergeTechFiles(techFileLayers(base.Layers, basePath), techFileLayers(overlay.Layers, overlayPath))
+	if conflicts > 0 {
+		return 0, fmt.Errorf("%d GDS conflicts, %s not written", conflicts, outPath)
+	}
+	replace := map[int]gds3d.Layer{}
+	var add []gds3d.Layer
+	for i, j := range from {
+		switch {
+		case j < 0:
+		case i < len(base.Layers):
+			replace[i] = overlay.Layers[j]
+		default:
+			add = append(add, overlay.Layers[j])
+		}
+	}
+	err = writeFileAtomic(r.path(outPath), func(w io.Writer) error {
+		return base.Write(ctxio.NewWriter(ctx, w), r.numberFormat(), replace, add)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("writing techfile: %w", err)
+	}
+	return len(LayerStack), nil
+}
+
+func readTechFileSource(ctx context.Context, r *run, name string) (*gds3d.Source, error) {
+	return decodeInput(ctx, r, name, func(file io.Reader, name string) (*gds3d.Source, error) {
+		return gds3d.ReadSource(ctx, file, name, r.Unit)
+	})
+}
+
+// Overlay layers replace base layers of the same name and are appended
+// otherwise. Returns the merged stack, the overlay index of each of its
+// layers, -1 for base layers, and the number of GDS collisions.
+func mergeTechFiles(base, overlay []Layer) ([]Layer, []int, int) {
+	LayerStack := append([]Layer{}, base...)
+	from := make([]int, len(base))
+	for i := range from {
+		from[i] = -1
+	}
+	layers := newLayerRegistry(LayerStack)
+	conflicts := 0
+	for j, l := range overlay {
+		for _, i := range layers.gds(l.GDSNumber, l.GDSDatatype) {
+			if b := LayerStack[i]; b.Name != l.Name {
+				fmt.Printf("Conflict: overlay %s and base %s are both %s\n", l.Name, b.Name, formatGDS(l))
+				conflicts++
+			}
+		}
+		if same := layers.named(l.Name); len(same) > 0 {
+			replace := same[len(same)-1]
+			fmt.Printf("Replaced: %s\n", l.Name)
+			layers.remove(replace, LayerStack[replace])
+			LayerStack[replace] = l
+			from[replace] = j
+			layers.add(replace, l)
+		} else {
+			fmt.Printf("Added: %s (%s)\n", l.Name, formatGDS(l))
+			LayerStack = append(LayerStack, l)
+			from = append(from, j)
+			layers.add(len(LayerStack)-1, l)
+		}
+	}
+	return LayerStack, from, conflicts
+}
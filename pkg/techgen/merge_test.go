@@ -0,0 +1,198 @@
+package techgen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMergeTechFiles(t *testing.T) {
+	base := []Layer{
+		{Name: "Metal1", GDSNumber: 8, Color: "#39bfff"},
+		{Name: "Metal2", GDSNumber: 10, Color: "#ccccd9"},
+	}
+	tests := []struct {
+		name      string
+		overlay   []Layer
+		want      []string // names and colors of the merged stack
+		conflicts int
+	}{
+		{"empty", nil, []string{"Metal1 #39bfff", "Metal2 #ccccd9"}, 0},
+		{"replace", []Layer{{Name: "Metal1", GDSNumber: 8, Color: "#ff0000"}},
+			[]string{"Metal1 #ff0000", "Metal2 #ccccd9"}, 0},
+		{"add", []Layer{{Name: "Membrane", GDSNumber: 200, Color: "#00ff00"}},
+			[]string{"Metal1 #39bfff", "Metal2 #ccccd9", "Membrane #00ff00"}, 0},
+		{"conflict", []Layer{{Name: "Cavity", GDSNumber: 8, Color: "#0000ff"}},
+			[]string{"Metal1 #39bfff", "Metal2 #ccccd9", "Cavity #0000ff"}, 1},
+		{"replace onto another layer", []Layer{{Name: "Metal2", GDSNumber: 8, Color: "#ff0000"}},
+			[]string{"Metal1 #39bfff", "Metal2 #ff0000"}, 1},
+		{"datatype tells apart", []Layer{{Name: "Metal1.pin", GDSNumber: 8, GDSDatatype: 2, Color: "#ff0000"}},
+			[]string{"Metal1 #39bfff", "Metal2 #ccccd9", "Metal1.pin #ff0000"}, 0},
+		// The first Membrane is replaced and frees 200/0 for Cavity
+		{"replace added", []Layer{
+			{Name: "Membrane", GDSNumber: 200, Color: "#00ff00"},
+			{Name: "Membrane", GDSNumber: 201, Color: "#00ff00"},
+			{Name: "Cavity", GDSNumber: 200, Color: "#0000ff"},
+			{Name: "Pad", GDSNumber: 201, Color: "#ffffff"},
+		}, []string{"Metal1 #39bfff", "Metal2 #ccccd9", "Membrane #00ff00", "Cavity #0000ff", "Pad #ffffff"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, from, conflicts := mergeTechFiles(base, tt.overlay)
+			if conflicts != tt.conflicts {
+				t.Errorf("%d conflicts, want %d", conflicts, tt.conflicts)
+			}
+			var got []string
+			for _, l := range merged {
+				got = append(got, l.Name+" "+l.Color)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("merged %q, want %q", got, tt.want)
+			}
+			for i, j := range from {
+				if j >= 0 && merged[i].Name != tt.overlay[j].Name {
+					t.Errorf("layer %d %s is from overlay layer %d %s", i, merged[i].Name, j, tt.overlay[j].Name)
+				}
+			}
+		})
+	}
+	if base[0].Color != "#39bfff" || len(base) != 2 {
+		t.Errorf("base changed: %+v", base)
+	}
+}
+
+const mergeBase = `# Foundry techfile, not generated
+# Rev 3
+
+# routing
+LayerStart: Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 0.22
+Greeen: 0.75
+Blue: 1
+Filter: 0.0
+Metal: 1
+Show: 1
+LayerEnd
+
+# keep as is
+LayerStart: Metal2
+Layer: 10
+Height: 2000
+Thickness: 450
+Red: 0.8
+Greeen: 0.8
+Blue: 0.85
+Metal: 1
+LayerEnd
+# end of foundry layers
+`
+
+const mergeOverlay = `# MEMS overlay
+LayerStart: Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 1
+Greeen: 0
+Blue: 0
+Filter: 0.0
+Metal: 1
+Show: 1
+LayerEnd
+# released membrane
+LayerStart: Membrane
+Layer: 200
+Datatype: 0
+Height: 5000
+Thickness: 1000
+Red: 0
+Greeen: 1
+Blue: 0
+Filter: 0.5
+Metal: 0
+Show: 1
+LayerEnd
+`
+
+// The base file keeps its header, comments and blocks, only Metal1 is
+// replaced and Membrane follows the last line
+const mergeWant = `# Foundry techfile, not generated
+# Rev 3
+
+# routing
+# MEMS overlay
+LayerStart: Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 1.00
+Greeen: 0.00
+Blue: 0.00
+Filter: 0.0
+Metal: 1
+Show: 1
+LayerEnd
+
+# keep as is
+LayerStart: Metal2
+Layer: 10
+Height: 2000
+Thickness: 450
+Red: 0.8
+Greeen: 0.8
+Blue: 0.85
+Metal: 1
+LayerEnd
+# end of foundry layers
+# released membrane
+LayerStart: Membrane
+Layer: 200
+Datatype: 0
+Height: 5000
+Thickness: 1000
+Red: 0.00
+Greeen: 1.00
+Blue: 0.00
+Filter: 0.5
+Metal: 0
+Show: 1
+LayerEnd
+
+`
+
+func TestMergeTechFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{"base.txt": mergeBase, "overlay.txt": mergeOverlay} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := DefaultOptions()
+	opts.Dir = dir
+	r, err := newRun(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := mergeTechFile(context.Background(), r, "base.txt", "overlay.txt", "merged.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged != 3 {
+		t.Errorf("%d layers merged, want 3", merged)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "merged.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != mergeWant {
+		t.Errorf("merged:\n%s\nwant:\n%s", got, mergeWant)
+	}
+}
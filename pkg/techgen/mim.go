@@ -0,0 +1,54 @@
+// z of the MIM capacitor from the metal stack
+//
+// The MIM top plate sits on its bottom plate metal, separated by the
+// capacitor dielectric: in SG13G2 on Metal5, under TopVia1 and TopMetal1.
+// Unless a LEF, stackup or PDK dump gives the MIM layer a z of its own it
+// is placed on top of the resolved bottom metal, so it follows metal
+// changes of variants and other inputs. The stack config can change both:
+/*
+
+"mim": { "below": "Metal5", "dielectric": 0.04 }
+*/
+
+package techgen
+
+import "fmt"
+
+type mimConfig struct {
+	Below      string   `json:"below"`
+	Dielectric *float64 `json:"dielectric"`
+}
+
+const (
+	defaultMIMBelow      = "Metal5"
+	defaultMIMDielectric = 0.04 // um, SiN between Metal5 and the MIM top plate
+)
+
+func update_layerstack_mim(LayerStack []Layer, mim *mimConfig) []Warning {
+	var warnings []Warning
+	below, dielectric := defaultMIMBelow, defaultMIMDielectric
+	if mim != nil {
+		if mim.Below != "" {
+			below = mim.Below
+		}
+		if mim.Dielectric != nil {
+			dielectric = *mim.Dielectric
+		}
+	}
+	i, m := layerIndex(LayerStack, "MIM"), layerIndex(LayerStack, below)
+	if i < 0 || LayerStack[i].ZSource != "default" {
+		return nil
+	}
+	if m < 0 {
+		warnings = append(warnings, warn("unknown-layer", "MIM: no layer %s to sit on, keeping the default z", below))
+		return warnings
+	}
+	LayerStack[i].Height = LayerStack[m].Height + LayerStack[m].Thickness + dielectric
+	LayerStack[i].ZSource = fmt.Sprintf("on %s + %g um", below, dielectric)
+	for _, l := range LayerStack {
+		if l.Metal == 1 && l.ZFrom == "" && l.Height > LayerStack[m].Height && l.Height < layerTop(LayerStack[i]) {
+			warnings = append(warnings, warn("mim-overlap", "MIM: top at %g um is above the bottom of %s", layerTop(LayerStack[i]), l.Name))
+		}
+	}
+	return warnings
+}
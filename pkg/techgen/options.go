@@ -0,0 +1,216 @@
+// Settings of a run
+//
+// Options are what the flags of the command set, Main fills them from the
+// command line and Generate takes them with its Inputs. A run keeps them
+// together with what it sets up from them and from the stack config, the
+// header of the techfile, its time stamp, and hands them down the
+// pipeline. Nothing of a run is kept in package variables, so runs can go
+// on side by side, e.g. the jobs of a batch.
+
+package techgen
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/lef"
+)
+
+// Options of a run, named like the flags of the command. The zero value
+// is not valid, start from DefaultOptions.
+type Options struct {
+	// Inputs
+	Dir        string // directory relative paths are taken from, "" for the working directory
+	Archive    string // PDK release archive (zip, tar, tar.gz) the inputs are read from first
+	Refresh    bool   // download and parse the inputs again instead of using the caches
+	ParseCache bool   // cache parsed inputs by content hash in the user cache directory, the command turns it on
+	LEFMaxLine int    // longest LEF line in bytes read before failing, 0 for no limit
+	ProfileDir string // directory of the stack profiles
+
+	// Stack
+	Heights       string  // metals with LEF THICKNESS but no HEIGHT: lef or accumulate
+	ILD           float64 // um of dielectric below accumulated layers without a gap
+	Corner        string  // thickness corner: typ, min, max or all
+	ZeroThickness string  // layers without thickness: warn, drop or min
+	MinThickness  float64 // um given to layers without one with ZeroThickness min
+	Sliver        float64 // um thinner layers are clamped up to, 0 for off
+	Include       string  // comma separated layer globs or /regex/, written only
+	Exclude       string  // comma separated layer globs or /regex/, left out
+	Hooks         string  // comma separated registered hooks, run before writing
+
+	// Techfile
+	Unit        string  // length unit of Height and Thickness: nm or um
+	Precision   int     // decimals of Height and Thickness, below 0 for the default of Unit
+	Numbers     string  // number style: fixed or minimal
+	Format      string  // key spelling: legacy or strict
+	Sort        string  // layer order within a section: stack, z or gds
+	ViaMetal    bool    // write cut layers with Metal: 1
+	ZScale      float64 // scale of the written heights and thicknesses
+	ZOffset     float64 // um the written heights are shifted by after ZScale
+	Header      string  // text/template file replacing the header, "" for the default
+	Footer      string  // text/template file written after the layers
+	NoTimestamp bool    // leave the date out of the header
+	Split       bool    // also write a techfile per section
+}
+
+// DefaultOptions are the defaults of the flags of the command, except
+// that nothing is written to the user cache directory
+func DefaultOptions() Options {
+	return Options{
+		LEFMaxLine:    lef.DefaultMaxLineLength,
+		ProfileDir:    "profiles",
+		Heights:       "lef",
+		ILD:           0.5,
+		Corner:        "typ",
+		ZeroThickness: "warn",
+		MinThickness:  0.01,
+		Unit:          "nm",
+		Precision:     -1,
+		Numbers:       "fixed",
+		Format:        "legacy",
+		Sort:          "stack",
+		ZScale:        1.0,
+	}
+}
+
+func (o Options) validate() error {
+	_, knownUnit := techFileUnitDigits[o.Unit]
+	switch {
+	case techFileFormats[o.Format] == nil:
+		return fmt.Errorf("unknown techfile format %s (use legacy or strict)", o.Format)
+	case techFileSorts[o.Sort] == nil:
+		return fmt.Errorf("unknown layer order %s (use stack, z or gds)", o.Sort)
+	case !knownUnit:
+		return fmt.Errorf("unknown techfile unit %s (use nm or um)", o.Unit)
+	case o.Heights != "lef" && o.Heights != "accumulate":
+		return fmt.Errorf("unknown height mode %s (use lef or accumulate)", o.Heights)
+	case !techFileNumberStyles[o.Numbers]:
+		return fmt.Errorf("unknown number style %s (use fixed or minimal)", o.Numbers)
+	case o.ZScale <= 0.0:
+		return fmt.Errorf("z scale %g must be positive", o.ZScale)
+	case o.Corner != "all" && !slices.Contains(stackCorners, o.Corner):
+		return fmt.Errorf("unknown corner %s (use typ, min, max or all)", o.Corner)
+	case o.Sliver < 0.0:
+		return fmt.Errorf("sliver thickness %g must not be negative", o.Sliver)
+	case !zeroThicknessPolicies[o.ZeroThickness]:
+		return fmt.Errorf("unknown zero thickness policy %s (use warn, drop or min)", o.ZeroThickness)
+	}
+	return nil
+}
+
+// A run of the pipeline, Main or one Generate: its options and what is
+// set up from them and the stack config
+type run struct {
+	Options
+	include, exclude []layerPattern
+	hooks            []string
+	header, footer   *template.Template
+
+	files              map[string][]byte // inputs in memory by name, read before the file system
+	aliases            map[string]string // of the stack config, input layer name to stack name
+	rename             []renameRule
+	info               techFileInfo // shown in the header
+	substrateGDSNumber int          // written for the substrate, GDS3D draws 255
+	substrateGDSSource string       // where substrateGDSNumber came from
+	substrateMargin    float64      // um the substrate of the previews reaches past the layers
+	clock              Clock        // of the caller, nil for SOURCE_DATE_EPOCH or the system clock
+	now                time.Time    // time stamp of the outputs
+
+	downloadMu sync.Mutex
+	downloads  map[string]*download // by URL
+
+	// Progress is printed to log as it goes, without a log it is kept as
+	// notes for the result
+	mu    sync.Mutex
+	log   io.Writer
+	notes []Warning
+}
+
+// The run of opts, the outputs are stamped from clock
+func newRun(opts Options, clock Clock) (*run, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	r := &run{
+		Options:            opts,
+		info:               defaultTechFileInfo,
+		substrateGDSNumber: 255,
+		substrateGDSSource: "GDS3D substrate number",
+		clock:              clock,
+		now:                generatedTime(opts.NoTimestamp, clock),
+	}
+	var err error
+	if r.include, err = parseLayerPatterns(opts.Include); err != nil {
+		return nil, err
+	}
+	if r.exclude, err = parseLayerPatterns(opts.Exclude); err != nil {
+		return nil, err
+	}
+	if r.hooks, err = parseHookList(opts.Hooks); err != nil {
+		return nil, err
+	}
+	r.header = template.Must(template.New("header").Parse(defaultTechFileHeader))
+	r.footer = template.Must(template.New("footer").Parse(""))
+	if opts.Header != "" {
+		if r.header, err = loadTechFileTemplate("header", r.path(opts.Header)); err != nil {
+			return nil, fmt.Errorf("reading header template: %w", err)
+		}
+	}
+	if opts.Footer != "" {
+		if r.footer, err = loadTechFileTemplate("footer", r.path(opts.Footer)); err != nil {
+			return nil, fmt.Errorf("reading footer template: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// Where a file of the run is, relative paths are taken from Dir. Paths
+// keep the names they were given in the outputs, the header lists them as
+// on the command line.
+func (o Options) path(name string) string {
+	if o.Dir == "" || isURL(name) || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(o.Dir, name)
+}
+
+// What the stack config changes besides the stack: the layer names of the
+// inputs, the header and the substrate as GDS3D sees it
+func (r *run) useConfig(config *stackConfig) {
+	r.aliases, r.rename = config.Aliases, config.Rename
+	r.info = config.headerInfo(r.info)
+	if sub := config.Substrate; sub != nil {
+		if sub.GDSNumber != nil {
+			r.substrateGDSNumber = *sub.GDSNumber
+			r.substrateGDSSource = "stack config substrate gds_layer"
+		}
+		if sub.Margin != nil {
+			r.substrateMargin = *sub.Margin
+		}
+	}
+}
+
+// Reports progress, e.g. a download or a dropped layer
+func (r *run) progress(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.log != nil {
+		fmt.Fprintf(r.log, format+"\n", args...)
+		return
+	}
+	r.notes = append(r.notes, note("progress", format, args...))
+}
+
+// The progress kept so far, cleared
+func (r *run) takeNotes() []Warning {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notes := r.notes
+	r.notes = nil
+	return notes
+}
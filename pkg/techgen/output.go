@@ -0,0 +1,45 @@
+// Writing of output files
+//
+// Outputs are written to a temp file next to the target and renamed into
+// place once complete. An error or panic halfway through leaves the previous
+// file alone instead of a truncated techfile that GDS3D fails to load in
+// confusing ways.
+
+package techgen
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func writeFileAtomic(filePath string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*")
+	if err != nil {
+		return err
+	}
+	// Also runs when write panics, the panic itself goes on
+	done := false
+	defer func() {
+		if !done {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	// CreateTemp makes the file private, outputs are as readable as os.Create
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return err
+	}
+	done = true
+	return nil
+}
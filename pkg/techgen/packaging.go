@@ -0,0 +1,128 @@
+// Post-passivation packaging layers above the top metal
+//
+// Redistribution layers, under bump metallization and bumps are not part
+// of the PDK stack. The stack config adds them, stacked from the bottom up
+// on the resolved top metal surface:
+/*
+
+"packaging": [
+  { "name": "RDL", "gds": "201/0", "gap": 2.0, "thickness": 3.0, "color": "#e0a040" },
+  { "name": "UBM", "gds": "202/0", "gap": 1.0, "thickness": 0.5 },
+  { "name": "Bump", "gds": "203/0", "thickness": 40.0, "color": "#c0c0c0" }
+]
+*/
+// Every layer sits gap um above the previous one, the first above the
+// highest metal, or above the "on" layer, e.g. a passivation slab from
+// "dielectrics". The gds is a gds/datatype pair or a lyp name. The layers
+// are metal unless "metal" is false and follow variants and via
+// interpolation of the metals below them.
+
+package techgen
+
+import (
+	"fmt"
+	"slices"
+)
+
+const defaultPackagingColor = "#d4a84c"
+
+type packagingConfig struct {
+	Name      string   `json:"name"`
+	GDS       string   `json:"gds"`
+	On        string   `json:"on"`
+	Gap       float64  `json:"gap"`
+	Thickness float64  `json:"thickness"`
+	Metal     *bool    `json:"metal"`
+	Color     string   `json:"color"`
+	Filter    *float64 `json:"filter"`
+}
+
+func (p packagingConfig) validate() error {
+	switch {
+	case p.Name == "":
+		return fmt.Errorf("has no name")
+	case p.GDS == "":
+		return fmt.Errorf("%s has no gds layer", p.Name)
+	case p.Thickness <= 0.0:
+		return fmt.Errorf("%s has thickness %g, expected a positive thickness in um", p.Name, p.Thickness)
+	case p.Gap < 0.0:
+		return fmt.Errorf("%s has gap %g, expected a gap in um", p.Name, p.Gap)
+	case p.Color != "" && len(p.Color) != 7:
+		return fmt.Errorf("%s has color %q, expected #rrggbb", p.Name, p.Color)
+	case p.Filter != nil && (*p.Filter < 0.0 || *p.Filter > 1.0):
+		return fmt.Errorf("%s has filter %g, expected 0.0 to 1.0", p.Name, *p.Filter)
+	}
+	return nil
+}
+
+// Packaging layers go on top of the stack, existing entries (e.g. read
+// back from an IR) are kept as they are
+func addPackagingLayers(LayerStack []Layer, packaging []packagingConfig, lyp []KLayer) ([]Layer, []Warning) {
+	var warnings []Warning
+	for _, p := range packaging {
+		if layerIndex(LayerStack, p.Name) >= 0 {
+			continue
+		}
+		layer := Layer{
+			Name:        p.Name,
+			altName:     p.Name,
+			Color:       defaultPackagingColor,
+			Metal:       1,
+			Show:        true,
+			Group:       "packaging",
+			ColorSource: "default",
+			ZSource:     "stack config",
+		}
+		if err := setLayerGDS(&layer, p.GDS, lyp); err != nil {
+			warnings = append(warnings, warn("no-lyp-layer", "packaging %s: %v", p.Name, err))
+		}
+		if p.Metal != nil && !*p.Metal {
+			layer.Metal = 0
+		}
+		if p.Color != "" {
+			layer.Color = p.Color
+		}
+		if p.Filter != nil {
+			layer.Filter = *p.Filter
+		}
+		LayerStack = append(LayerStack, layer)
+	}
+	return LayerStack, warnings
+}
+
+// After the dielectrics, so a packaging layer can sit on a passivation slab
+func update_layerstack_packaging(LayerStack []Layer, packaging []packagingConfig) []Warning {
+	var warnings []Warning
+	if len(packaging) == 0 {
+		return nil
+	}
+	names := make([]string, len(packaging))
+	for i, p := range packaging {
+		names[i] = p.Name
+	}
+	below, surface := "", 0.0
+	for _, l := range LayerStack {
+		if l.Metal == 1 && l.ZFrom == "" && !slices.Contains(names, l.Name) && layerTop(l) > surface {
+			below, surface = l.Name, layerTop(l)
+		}
+	}
+	for _, p := range packaging {
+		if p.On != "" {
+			on := layerIndex(LayerStack, p.On)
+			if on < 0 {
+				warnings = append(warnings, warn("unknown-layer", "packaging %s: no layer %s to sit on", p.Name, p.On))
+				continue
+			}
+			below, surface = p.On, layerTop(LayerStack[on])
+		}
+		i := layerIndex(LayerStack, p.Name)
+		if i < 0 {
+			continue
+		}
+		LayerStack[i].Height = surface + p.Gap
+		LayerStack[i].Thickness = p.Thickness
+		LayerStack[i].ZSource = fmt.Sprintf("on %s + %g um", below, p.Gap)
+		below, surface = p.Name, layerTop(LayerStack[i])
+	}
+	return warnings
+}
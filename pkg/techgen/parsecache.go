@@ -0,0 +1,180 @@
+// Cache of parsed inputs
+//
+// Parsing a full PDK lyp and LEF takes most of a run, and watch mode and
+// batch variants parse the same unchanged files again and again. The
+// parsed layers are kept in the user cache directory ($XDG_CACHE_HOME on
+// Linux) next to the downloads, keyed by a hash of the file content and
+// of the settings the parser depends on and of the build of the parsers,
+// so a changed file, setting or parser is parsed again and nothing needs
+// to be invalidated by hand. Entries of old files and builds stay behind
+// until the directory is removed.
+
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/ctxio"
+)
+
+// Bumped when the cached types change, so entries of older builds are not
+// decoded into them
+const parseCacheVersion = 2
+
+// The build the parsers come from, in the key of every entry: the version
+// and sum of this module when it is a dependency, the vcs revision of a
+// build from a clean checkout, else a hash of the executable. A parser fix
+// without a bump of parseCacheVersion never reads the entries of another
+// build.
+var parserBuild = sync.OnceValue(func() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath && dep.Sum != "" {
+				return dep.Version + " " + dep.Sum
+			}
+		}
+		revision, modified := "", true
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if info.Main.Path == modulePath && revision != "" && !modified {
+			return revision
+		}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return toolVersion
+	}
+	file, err := os.Open(exe)
+	if err != nil {
+		return toolVersion
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return toolVersion
+	}
+	return hex.EncodeToString(h.Sum(nil))
+})
+
+const modulePath = "github.com/jorgenkraghjakobsen/build_3d_techfile"
+
+// A cache entry, the warnings are kept so a cached parse reports them too
+type parsed[T any] struct {
+	Value    T
+	Warnings []Warning
+}
+
+// Reads name and decodes it like decodeInput, or takes the result and its
+// warnings from the cache when the content and params were decoded before.
+// kind tells the parsers apart, params are the settings the result depends
+// on besides the content. -refresh parses again and replaces the entry,
+// -parse-cache=false leaves the cache alone.
+func decodeCached[T any](ctx context.Context, r *run, kind, params, name string, decode func(file io.Reader, name string) (T, []Warning, error)) (T, []Warning, error) {
+	var zero T
+	if !r.ParseCache {
+		var warnings []Warning
+		v, err := decodeInput(ctx, r, name, func(file io.Reader, name string) (v T, err error) {
+			v, warnings, err = decode(file, name)
+			return v, err
+		})
+		return v, warnings, err
+	}
+	file, err := openInput(ctx, r, name)
+	if err != nil {
+		return zero, nil, err
+	}
+	data, err := io.ReadAll(ctxio.NewReader(ctx, file))
+	file.Close()
+	if err != nil {
+		return zero, nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	cached, err := parseCachePath(kind, params, data)
+	if err != nil {
+		// No cache directory, e.g. without HOME, just parse
+		return decode(bytes.NewReader(data), name)
+	}
+	if !r.Refresh {
+		var entry parsed[T]
+		if readParseCache(cached, &entry) == nil {
+			r.progress("Using cached parse of %s: %s", name, cached)
+			return entry.Value, entry.Warnings, nil
+		}
+	}
+	v, warnings, err := decode(ctxio.NewReader(ctx, bytes.NewReader(data)), name)
+	if err != nil {
+		return v, warnings, err
+	}
+	// The cache only saves time, a run never fails on it
+	writeParseCache(cached, parsed[T]{v, warnings})
+	return v, warnings, nil
+}
+
+// Settings decodeLEF depends on, the line limit and the names of the
+// layers it keeps, which go through the aliases and rename rules
+func (r *run) lefCacheParams() string {
+	params, _ := json.Marshal(struct {
+		MaxLine int
+		Aliases map[string]string
+		Rename  []renameRule
+	}{r.LEFMaxLine, r.aliases, r.rename})
+	return string(params)
+}
+
+func parseCachePath(kind, params string, data []byte) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d %s %s %q\n", parseCacheVersion, parserBuild(), kind, params)
+	h.Write(data)
+	return filepath.Join(dir, "build_3d_techfile", "parsed", kind+"-"+hex.EncodeToString(h.Sum(nil)[:16])+".gob"), nil
+}
+
+func readParseCache(path string, v any) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return gob.NewDecoder(file).Decode(v)
+}
+
+// Written next to the entry and renamed, so parallel runs never read a
+// half written one
+func writeParseCache(path string, v any) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".parse-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	os.Rename(tmp.Name(), path)
+}
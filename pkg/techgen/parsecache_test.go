@@ -0,0 +1,51 @@
+package techgen
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestParseCacheParserBuild(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func(build func() string) { parserBuild = build }(parserBuild)
+
+	opts := DefaultOptions()
+	opts.ParseCache = true
+	r, err := newRun(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.files = map[string][]byte{"in.txt": []byte("content")}
+	parses := 0
+	decode := func(file io.Reader, name string) (string, []Warning, error) {
+		parses++
+		data, err := io.ReadAll(file)
+		return string(data), nil, err
+	}
+
+	tests := []struct {
+		build  string
+		parses int // parses so far
+	}{
+		{"v1", 1},
+		{"v1", 1}, // cached
+		{"v2", 2}, // a fixed parser does not read the entry of v1
+		{"v2", 2},
+		{"v1", 2}, // the entry of v1 is still there
+	}
+	for i, tt := range tests {
+		parserBuild = func() string { return tt.build }
+		v, _, err := decodeCached(context.Background(), r, "test", "", "in.txt", decode)
+		if err != nil {
+			t.Fatalf("decode %d: %v", i, err)
+		}
+		if v != "content" {
+			t.Errorf("decode %d = %q, want content", i, v)
+		}
+		if parses != tt.parses {
+			t.Errorf("after decode %d with build %s: %d parses, want %d", i, tt.build, parses, tt.parses)
+		}
+	}
+}
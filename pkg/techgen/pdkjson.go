@@ -0,0 +1,130 @@
+// Import of JSON layer/stack dumps from python based PDK frameworks
+//
+// PDKMaster and the hdl21 pdk packages both describe a process programmatically.
+// A small export script on their side dumps the layers to JSON, e.g.
+/*
+
+{
+  "name": "sg13g2",
+  "units": "um",
+  "layers": [
+    { "name": "Metal1", "gds_layer": 8, "gds_datatype": 0,
+      "height": 0.93, "thickness": 0.4, "color": "#39bfff", "type": "routing" }
+  ]
+}
+*/
+// All values except name are optional. Heights and thicknesses are in
+// units, micron when not given. The type sets whether the layer is drawn
+// as metal: metal and routing layers are, via, cut and dielectric layers
+// are not and dielectric ones become slabs.
+
+package techgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+type PDKLayer struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	GDSNumber   *int     `json:"gds_layer"`
+	GDSDatatype *int     `json:"gds_datatype"`
+	Height      *float64 `json:"height"`
+	Thickness   *float64 `json:"thickness"`
+	Color       string   `json:"color"`
+}
+
+type PDKFile struct {
+	Name   string     `json:"name"`
+	Units  string     `json:"units"`
+	Layers []PDKLayer `json:"layers"`
+}
+
+// What the types of a dump make of a layer, Metal and Dielectric
+var pdkLayerTypes = map[string]struct{ metal, dielectric bool }{
+	"metal":      {true, false},
+	"routing":    {true, false},
+	"via":        {false, false},
+	"cut":        {false, false},
+	"dielectric": {false, true},
+}
+
+func parsePDKJSON(ctx context.Context, r *run, filePath string) (*PDKFile, error) {
+	return decodeInput(ctx, r, filePath, decodePDKJSON)
+}
+
+func decodePDKJSON(file io.Reader, filePath string) (*PDKFile, error) {
+
+	pdkFile := &PDKFile{}
+	if err := json.NewDecoder(file).Decode(pdkFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	scale, ok := lengthUnits[strings.ToLower(pdkFile.Units)]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown units %q", filePath, pdkFile.Units)
+	}
+	for i, layer := range pdkFile.Layers {
+		if layer.Height != nil {
+			*pdkFile.Layers[i].Height *= scale
+		}
+		if layer.Thickness != nil {
+			*pdkFile.Layers[i].Thickness *= scale
+		}
+		if layer.Name == "" {
+			return nil, fmt.Errorf("%s: layer %d has no name", filePath, i)
+		}
+		if layer.Type != "" {
+			pdkFile.Layers[i].Type = strings.ToLower(layer.Type)
+			if _, ok := pdkLayerTypes[pdkFile.Layers[i].Type]; !ok {
+				return nil, fmt.Errorf("%s: layer %s: unknown type %q (use metal, routing, via, cut or dielectric)", filePath, layer.Name, layer.Type)
+			}
+		}
+		if layer.Color != "" {
+			if _, _, _, err := gds3d.ParseColor(layer.Color); err != nil {
+				return nil, &parseerr.Error{File: filePath, Layer: layer.Name, Err: err}
+			}
+		}
+	}
+	return pdkFile, nil
+}
+
+// Only the values present in the dump replace what lyp and LEF gave us
+func update_layerstack_pdk(LayerStack []Layer, layers *layerRegistry, layer PDKLayer) {
+	for _, i := range slices.Clone(layers.named(layer.Name)) {
+		layers.remove(i, LayerStack[i])
+		if layer.GDSNumber != nil {
+			LayerStack[i].GDSNumber = *layer.GDSNumber
+			LayerStack[i].ColorSource = "pdkjson " + layer.Name
+		}
+		if layer.GDSDatatype != nil {
+			LayerStack[i].GDSDatatype = *layer.GDSDatatype
+			LayerStack[i].ColorSource = "pdkjson " + layer.Name
+		}
+		if layer.Height != nil {
+			LayerStack[i].Height = *layer.Height
+			LayerStack[i].ZSource = "pdkjson " + layer.Name
+		}
+		if layer.Thickness != nil {
+			LayerStack[i].Thickness = *layer.Thickness
+			LayerStack[i].ZSource = "pdkjson " + layer.Name
+		}
+		if layer.Color != "" {
+			LayerStack[i].Color = layer.Color
+			LayerStack[i].ColorSource = "pdkjson " + layer.Name
+		}
+		if t, ok := pdkLayerTypes[layer.Type]; ok {
+			LayerStack[i].Metal = btoi(t.metal)
+			LayerStack[i].Dielectric = t.dielectric
+		}
+		layers.add(i, LayerStack[i])
+	}
+}
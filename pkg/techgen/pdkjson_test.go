@@ -0,0 +1,61 @@
+package techgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodePDKJSON(t *testing.T) {
+	tests := []struct {
+		dump      string
+		thickness float64 // of the first layer, um
+		typ       string
+		err       string
+	}{
+		{`{"units": "um", "layers": [{"name": "Metal1", "thickness": 0.4}]}`, 0.4, "", ""},
+		{`{"units": "UM", "layers": [{"name": "Metal1", "thickness": 0.4}]}`, 0.4, "", ""},
+		{`{"units": "Nm", "layers": [{"name": "Metal1", "thickness": 400}]}`, 0.4, "", ""},
+		{`{"layers": [{"name": "Metal1", "thickness": 0.4, "type": "Routing"}]}`, 0.4, "routing", ""},
+		{`{"units": "furlong", "layers": []}`, 0, "", `unknown units "furlong"`},
+		{`{"layers": [{"name": "Metal1", "type": "wire"}]}`, 0, "", `unknown type "wire"`},
+	}
+	for _, tt := range tests {
+		pdk, err := decodePDKJSON(strings.NewReader(tt.dump), "pdk.json")
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("decodePDKJSON(%s) = %v, want error %s", tt.dump, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("decodePDKJSON(%s): %v", tt.dump, err)
+			continue
+		}
+		l := pdk.Layers[0]
+		if l.Thickness == nil || roundUm(*l.Thickness) != tt.thickness || l.Type != tt.typ {
+			t.Errorf("decodePDKJSON(%s) = %+v, want thickness %g and type %q", tt.dump, l, tt.thickness, tt.typ)
+		}
+	}
+}
+
+func TestUpdateLayerstackPDKType(t *testing.T) {
+	tests := []struct {
+		typ        string
+		metal      int
+		dielectric bool
+	}{
+		{"", 1, false}, // as it was
+		{"metal", 1, false},
+		{"routing", 1, false},
+		{"via", 0, false},
+		{"cut", 0, false},
+		{"dielectric", 0, true},
+	}
+	for _, tt := range tests {
+		LayerStack := []Layer{{Name: "Metal1", GDSNumber: 8, Metal: 1, Show: true}}
+		update_layerstack_pdk(LayerStack, newLayerRegistry(LayerStack), PDKLayer{Name: "Metal1", Type: tt.typ})
+		if l := LayerStack[0]; l.Metal != tt.metal || l.Dielectric != tt.dielectric {
+			t.Errorf("type %q: Metal %d, Dielectric %t, want %d, %t", tt.typ, l.Metal, l.Dielectric, tt.metal, tt.dielectric)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// 3D preview geometry of the layer stack shared by the mesh exporters
+//
+// The preview is a square piece of die. Metals and the substrate are full
+// slabs, vias are a grid of pillars and the remaining layers each get a
+// strip of the die, like the lanes in the SVG cross-section. All
+// coordinates are in micron.
+
+package techgen
+
+const (
+	previewSize           = 10.0 // die edge length
+	previewSubstrateDepth = 1.0  // micron of substrate shown
+	previewViaGrid        = 3    // pillars per side
+	previewViaSize        = 0.6
+)
+
+type previewBox struct {
+	Layer                  int // index into the layer stack
+	X0, Y0, Z0, X1, Y1, Z1 float64
+}
+
+// The substrate reaches margin um past the die on every side, from the
+// stack config
+func previewBoxes(LayerStack []Layer, margin float64) []previewBox {
+	lanes := 0
+	for _, l := range LayerStack {
+		if l.Name != "Substrate" && l.Metal == 0 && !isViaLayer(l) && l.ZFrom == "" && !l.Dielectric {
+			lanes++
+		}
+	}
+	laneWidth := previewSize / float64(max(lanes, 1))
+
+	lowest := 0.0
+	for _, l := range LayerStack {
+		if l.Name != "Substrate" {
+			lowest = min(lowest, layerTop(l))
+		}
+	}
+
+	var boxes []previewBox
+	lane := 0
+	for i, l := range LayerStack {
+		z0, z1 := l.Height, layerTop(l)
+		// Extra datatypes would only fill the slab of their layer again
+		if z1 <= z0 || l.ZFrom != "" {
+			continue
+		}
+		switch {
+		case l.Name == "Substrate":
+			// A thin film substrate is shown as thin as it is, backside
+			// layers show all of it down to them
+			depth := min(l.Thickness, max(previewSubstrateDepth, z1-lowest))
+			m := margin
+			boxes = append(boxes, previewBox{i, -m, -m, z1 - depth, previewSize + m, previewSize + m, z1})
+		case l.Metal == 1 || l.Dielectric:
+			boxes = append(boxes, previewBox{i, 0, 0, z0, previewSize, previewSize, z1})
+		case isViaLayer(l):
+			pitch := previewSize / previewViaGrid
+			for ix := 0; ix < previewViaGrid; ix++ {
+				for iy := 0; iy < previewViaGrid; iy++ {
+					x := pitch*(float64(ix)+0.5) - previewViaSize/2
+					y := pitch*(float64(iy)+0.5) - previewViaSize/2
+					boxes = append(boxes, previewBox{i, x, y, z0, x + previewViaSize, y + previewViaSize, z1})
+				}
+			}
+		default:
+			x := laneWidth * float64(lane)
+			boxes = append(boxes, previewBox{i, x, 0, z0, x + laneWidth, previewSize, z1})
+			lane++
+		}
+	}
+	return boxes
+}
+
+// Corners of a box, bit 0 selects x, bit 1 y and bit 2 z
+func (b previewBox) corners() [8][3]float64 {
+	var c [8][3]float64
+	for i := range c {
+		c[i] = [3]float64{b.X0, b.Y0, b.Z0}
+		if i&1 != 0 {
+			c[i][0] = b.X1
+		}
+		if i&2 != 0 {
+			c[i][1] = b.Y1
+		}
+		if i&4 != 0 {
+			c[i][2] = b.Z1
+		}
+	}
+	return c
+}
+
+// The six faces of a box as corner indices, counter clockwise seen from
+// outside, with their outward normals
+var previewBoxFaces = [6]struct {
+	Corners [4]int
+	Normal  [3]float64
+}{
+	{[4]int{0, 2, 3, 1}, [3]float64{0, 0, -1}},
+	{[4]int{4, 5, 7, 6}, [3]float64{0, 0, 1}},
+	{[4]int{0, 1, 5, 4}, [3]float64{0, -1, 0}},
+	{[4]int{2, 6, 7, 3}, [3]float64{0, 1, 0}},
+	{[4]int{0, 4, 6, 2}, [3]float64{-1, 0, 0}},
+	{[4]int{1, 3, 7, 5}, [3]float64{1, 0, 0}},
+}
+
+// Stack z up to the y up convention of glTF and OBJ. Subtracting from 0
+// keeps zero coordinates from turning into -0.
+func toYUp(v [3]float64) [3]float64 {
+	return [3]float64{v[0], v[2], 0 - v[1]}
+}
@@ -0,0 +1,110 @@
+// Stack profiles: stack configs that extend other stack configs
+//
+// A team profile often differs from the PDK profile in a few layers. It
+// names its base and only lists what changes:
+/*
+
+{
+  "extends": "sg13g2",
+  "process": "IHP SG13G2 with MIM",
+  "layers": [ { "name": "MIM", "filter": 0.0, "show": true } ]
+}
+*/
+// The base is a profile name, looked up as name.json next to the config
+// that extends it, or a path relative to it. Bases may extend further
+// bases. Values of the profile replace those of its base, objects are
+// merged key by key and layers by name, other lists are replaced as a
+// whole. -profile name reads name.json from -profile-dir as the config.
+
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+func profilePath(name, dir string) string {
+	if strings.HasSuffix(name, ".json") || isURL(name) {
+		if filepath.IsAbs(name) || isURL(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(dir, name+".json")
+}
+
+// The config as JSON with all its bases merged in, seen guards against
+// profiles that extend themselves
+func loadStackProfile(ctx context.Context, r *run, filePath string, seen map[string]bool) (map[string]any, error) {
+	if seen[filePath] {
+		return nil, fmt.Errorf("%s: profiles extend each other in a loop", filePath)
+	}
+	seen[filePath] = true
+
+	data, err := readInput(ctx, r, filePath)
+	if err != nil {
+		return nil, err
+	}
+	var profile map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&profile); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	base, ok := profile["extends"]
+	if !ok {
+		return profile, nil
+	}
+	delete(profile, "extends")
+	name, ok := base.(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("%s: extends needs a profile name", filePath)
+	}
+	merged, err := loadStackProfile(ctx, r, profilePath(name, filepath.Dir(filePath)), seen)
+	if err != nil {
+		return nil, err
+	}
+	return mergeProfile(merged, profile), nil
+}
+
+func mergeProfile(base, profile map[string]any) map[string]any {
+	for key, value := range profile {
+		switch v := value.(type) {
+		case map[string]any:
+			if b, ok := base[key].(map[string]any); ok {
+				base[key] = mergeProfile(b, v)
+				continue
+			}
+		case []any:
+			if b, ok := base[key].([]any); ok && key == "layers" {
+				base[key] = mergeProfileLayers(b, v)
+				continue
+			}
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// Layers of the profile change the base layer of the same name or are added
+func mergeProfileLayers(base, layers []any) []any {
+	for _, layer := range layers {
+		l, _ := layer.(map[string]any)
+		merged := false
+		for i, b := range base {
+			bl, _ := b.(map[string]any)
+			if l != nil && bl != nil && bl["name"] == l["name"] {
+				base[i] = mergeProfile(bl, l)
+				merged = true
+			}
+		}
+		if !merged {
+			base = append(base, layer)
+		}
+	}
+	return base
+}
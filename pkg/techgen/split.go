@@ -0,0 +1,73 @@
+// Per-section techfiles for large layer sets
+//
+// With -split the layers are also written as one techfile per section,
+// e.g. sg13g2_feol.txt, sg13g2_beol.txt and sg13g2_passive.txt, as some
+// GDS3D installs keep a techfile per module. The -o techfile stays the
+// master with all layers and lists the section files in its header.
+
+package techgen
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type techFileSection struct {
+	Name   string   // file name suffix
+	Groups []string // layer groups in the section
+}
+
+var techFileSections = []techFileSection{
+	{"feol", []string{"feol"}},
+	{"beol", []string{"metal", "via", "dielectric"}},
+	{"passive", []string{"passive"}},
+	{"packaging", []string{"packaging"}},
+}
+
+func sectionPath(filePath, section string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + "_" + section + ext
+}
+
+// The sections of the techfile filePath that have layers, with their files
+func splitSections(filePath string, LayerStack []Layer) []splitSection {
+	var sections []splitSection
+	for _, section := range techFileSections {
+		var layers []Layer
+		for _, l := range LayerStack {
+			for _, group := range section.Groups {
+				if l.Group == group {
+					layers = append(layers, l)
+				}
+			}
+		}
+		if len(layers) > 0 {
+			sections = append(sections, splitSection{sectionPath(filePath, section.Name), layers})
+		}
+	}
+	return sections
+}
+
+type splitSection struct {
+	path   string
+	layers []Layer
+}
+
+// Writes the sections with layers, returns their files for the master index
+// and how many could not be written
+func writeSplitTechFiles(ctx context.Context, r *run, filePath string, LayerStack []Layer) ([]string, int) {
+	var index []string
+	failed := 0
+	for _, section := range splitSections(filePath, LayerStack) {
+		if err := writeTechFile(ctx, r, section.path, section.layers, nil); err != nil {
+			fmt.Fprintln(r.log, "Error writing section techfile:", err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(r.log, "Wrote section techfile: %s (%d layers)\n", section.path, len(section.layers))
+		index = append(index, filepath.Base(section.path))
+	}
+	return index, failed
+}
@@ -0,0 +1,203 @@
+// The layer stack as a type
+//
+// Stackup is the []Layer the update_layerstack_* steps work on, with the
+// edits that are otherwise spelled out on the slice: lookups by name and
+// GDS number, adding and removing layers, sorting by z and overrides. It
+// is a plain slice type, so it goes to every function taking []Layer.
+
+package techgen
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+)
+
+type Stackup []Layer
+
+// Index of layer name, -1 if it is not in the stack
+func (s Stackup) Index(name string) int {
+	for i, l := range s {
+		if l.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Layer name in the stack, nil if there is none
+func (s Stackup) Find(name string) *Layer {
+	if i := s.Index(name); i >= 0 {
+		return &s[i]
+	}
+	return nil
+}
+
+// First layer drawn from a GDS layer and datatype, nil if there is none
+func (s Stackup) FindByGDS(number, datatype int) *Layer {
+	for i, l := range s {
+		if l.GDSNumber == number && l.GDSDatatype == datatype {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// Appends layer, names are unique in the stack
+func (s *Stackup) AddLayer(layer Layer) error {
+	if layer.Name == "" {
+		return errors.New("layer without a name")
+	}
+	if s.Index(layer.Name) >= 0 {
+		return fmt.Errorf("layer %s is already in the stack", layer.Name)
+	}
+	if layer.Group == "" {
+		layer.Group = defaultGroup(layer)
+	}
+	*s = append(*s, layer)
+	return nil
+}
+
+// Drops layer name, false if it is not in the stack
+func (s *Stackup) Remove(name string) bool {
+	i := s.Index(name)
+	if i < 0 {
+		return false
+	}
+	*s = slices.Delete(*s, i, i+1)
+	return true
+}
+
+// See sortLayerStackByZ
+func (s *Stackup) SortByZ() {
+	*s = sortLayerStackByZ(*s)
+}
+
+// Problems that make the stack unfit to write: layers without or with the
+// same name, colors that are not #rrggbb and unknown groups. Geometry is
+// checked by checkLayerStack, it only warns.
+func (s Stackup) Validate() error {
+	var errs []error
+	seen := map[string]bool{}
+	for i, l := range s {
+		switch {
+		case l.Name == "":
+			errs = append(errs, fmt.Errorf("layer %d has no name", i+1))
+		case seen[l.Name]:
+			errs = append(errs, fmt.Errorf("layer %s is in the stack twice", l.Name))
+		}
+		seen[l.Name] = true
+		if _, _, _, err := gds3d.ParseColor(l.Color); err != nil {
+			errs = append(errs, fmt.Errorf("layer %s: %w", l.Name, err))
+		}
+		if !isLayerGroup(l.Group) {
+			errs = append(errs, fmt.Errorf("layer %s: unknown group %q", l.Name, l.Group))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// A change to one layer, nil and "" leave the value alone
+type layerOverride struct {
+	Name      string
+	Color     string
+	Filter    *float64
+	Show      *bool
+	Metal     *bool
+	Tolerance *float64
+	Group     string
+	Source    string // the color source, with Color
+}
+
+// Applies the overrides in order, returns the ones naming no layer
+func (s Stackup) ApplyOverrides(overrides []layerOverride) []layerOverride {
+	var missing []layerOverride
+	for _, o := range overrides {
+		l := s.Find(o.Name)
+		if l == nil {
+			missing = append(missing, o)
+			continue
+		}
+		if o.Color != "" {
+			l.Color = o.Color
+			l.ColorSource = o.Source
+		}
+		if o.Filter != nil {
+			l.Filter = *o.Filter
+		}
+		if o.Show != nil {
+			l.Show = *o.Show
+		}
+		if o.Metal != nil {
+			l.Metal = btoi(*o.Metal)
+		}
+		if o.Tolerance != nil {
+			l.Tolerance = *o.Tolerance
+		}
+		if o.Group != "" {
+			l.Group = o.Group
+		}
+	}
+	return missing
+}
+
+// Indexes of the layers of a stack by name, by the name the lyp uses and
+// by GDS pair, so the inputs look their layers up instead of scanning the
+// stack for each of them. Layers that change name or GDS pair are removed
+// and added again.
+type layerRegistry struct {
+	byName map[string][]int
+	byLyp  map[string][]int
+	byGDS  map[gdsLayerKey][]int
+}
+
+func newLayerRegistry(s Stackup) *layerRegistry {
+	r := &layerRegistry{byName: map[string][]int{}, byLyp: map[string][]int{}, byGDS: map[gdsLayerKey][]int{}}
+	for i, l := range s {
+		r.add(i, l)
+	}
+	return r
+}
+
+// Indexes stay sorted, lookups return them in stack order
+func insertIndex(list []int, i int) []int {
+	at, found := slices.BinarySearch(list, i)
+	if found {
+		return list
+	}
+	return slices.Insert(list, at, i)
+}
+
+func removeIndex(list []int, i int) []int {
+	if at, found := slices.BinarySearch(list, i); found {
+		return slices.Delete(list, at, at+1)
+	}
+	return list
+}
+
+func (r *layerRegistry) add(i int, l Layer) {
+	r.byName[l.Name] = insertIndex(r.byName[l.Name], i)
+	r.byLyp[lypName(l)] = insertIndex(r.byLyp[lypName(l)], i)
+	key := gdsLayerKey{l.GDSNumber, l.GDSDatatype}
+	r.byGDS[key] = insertIndex(r.byGDS[key], i)
+}
+
+func (r *layerRegistry) remove(i int, l Layer) {
+	r.byName[l.Name] = removeIndex(r.byName[l.Name], i)
+	r.byLyp[lypName(l)] = removeIndex(r.byLyp[lypName(l)], i)
+	key := gdsLayerKey{l.GDSNumber, l.GDSDatatype}
+	r.byGDS[key] = removeIndex(r.byGDS[key], i)
+}
+
+// Layers named name
+func (r *layerRegistry) named(name string) []int { return r.byName[name] }
+
+// Layers the lyp entry name is for, see lypName
+func (r *layerRegistry) lyp(name string) []int { return r.byLyp[name] }
+
+// Layers drawn from GDS layer number and datatype
+func (r *layerRegistry) gds(number, datatype int) []int {
+	return r.byGDS[gdsLayerKey{number, datatype}]
+}
@@ -0,0 +1,306 @@
+package techgen
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testStack() Stackup {
+	return Stackup{
+		{Name: "Substrate", GDSNumber: 0, Color: "#808080", Height: -1, Thickness: 1, Group: "feol"},
+		{Name: "Metal1", GDSNumber: 8, Color: "#39bfff", Height: 0.93, Thickness: 0.4, Metal: 1, Show: true, Group: "metal"},
+		{Name: "Metal1.pin", GDSNumber: 8, GDSDatatype: 2, Color: "#39bfff", Group: "metal"},
+		{Name: "Via1", GDSNumber: 19, Color: "#cccccc", Height: 1.33, Thickness: 0.54, Group: "via"},
+		{Name: "Metal2", GDSNumber: 10, Color: "#ccccd9", Height: 1.87, Thickness: 0.45, Metal: 1, Show: true, Group: "metal"},
+	}
+}
+
+func stackNames(s []Layer) []string {
+	var names []string
+	for _, l := range s {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+func TestStackupAddLayer(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer Layer
+		group string // of the added layer, "" for an error
+		err   string
+	}{
+		{"default group", Layer{Name: "Metal3", Metal: 1}, "metal", ""},
+		{"group kept", Layer{Name: "Metal3", Metal: 1, Group: "passive"}, "passive", ""},
+		{"no name", Layer{Metal: 1}, "", "without a name"},
+		{"twice", Layer{Name: "Via1"}, "", "already in the stack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testStack()
+			err := s.AddLayer(tt.layer)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want %q", err, tt.err)
+				}
+				if len(s) != len(testStack()) {
+					t.Errorf("stack changed on error: %q", stackNames(s))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := s[len(s)-1]
+			if last.Name != tt.layer.Name || last.Group != tt.group {
+				t.Errorf("added %s in group %q, want %s in %q", last.Name, last.Group, tt.layer.Name, tt.group)
+			}
+		})
+	}
+}
+
+func TestStackupRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		ok      bool
+		remains []string
+	}{
+		{"Via1", true, []string{"Substrate", "Metal1", "Metal1.pin", "Metal2"}},
+		{"Substrate", true, []string{"Metal1", "Metal1.pin", "Via1", "Metal2"}},
+		{"Metal2", true, []string{"Substrate", "Metal1", "Metal1.pin", "Via1"}},
+		{"Metal9", false, []string{"Substrate", "Metal1", "Metal1.pin", "Via1", "Metal2"}},
+	}
+	for _, tt := range tests {
+		s := testStack()
+		if ok := s.Remove(tt.name); ok != tt.ok {
+			t.Errorf("Remove(%s) = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if got := stackNames(s); !slices.Equal(got, tt.remains) {
+			t.Errorf("Remove(%s) left %q, want %q", tt.name, got, tt.remains)
+		}
+	}
+}
+
+func TestStackupSortByZ(t *testing.T) {
+	tests := []struct {
+		name  string
+		stack Stackup
+		want  []string
+	}{
+		{"sorted", testStack(), []string{"Substrate", "Metal1", "Metal1.pin", "Via1", "Metal2"}},
+		// Layers without z move with the layer before them
+		{"reversed", Stackup{
+			{Name: "Metal2", Height: 1.87, Thickness: 0.45},
+			{Name: "Metal2.pin"},
+			{Name: "Metal1", Height: 0.93, Thickness: 0.4},
+			{Name: "Metal1.pin"},
+		}, []string{"Metal1", "Metal1.pin", "Metal2", "Metal2.pin"}},
+		{"leading without z", Stackup{
+			{Name: "Text"},
+			{Name: "Metal2", Height: 1.87, Thickness: 0.45},
+			{Name: "Metal1", Height: 0.93, Thickness: 0.4},
+		}, []string{"Text", "Metal1", "Metal2"}},
+		{"same height thinner first", Stackup{
+			{Name: "MIM", Height: 5, Thickness: 0.1},
+			{Name: "Gate", Height: 5, Thickness: 0.05},
+		}, []string{"Gate", "MIM"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.stack.SortByZ()
+			if got := stackNames(tt.stack); !slices.Equal(got, tt.want) {
+				t.Errorf("sorted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStackupFindByGDS(t *testing.T) {
+	s := testStack()
+	tests := []struct {
+		number, datatype int
+		want             string // "" for none
+	}{
+		{8, 0, "Metal1"},
+		{8, 2, "Metal1.pin"},
+		{10, 0, "Metal2"},
+		{10, 2, ""},
+		{99, 0, ""},
+	}
+	for _, tt := range tests {
+		l := s.FindByGDS(tt.number, tt.datatype)
+		got := ""
+		if l != nil {
+			got = l.Name
+		}
+		if got != tt.want {
+			t.Errorf("FindByGDS(%d, %d) = %q, want %q", tt.number, tt.datatype, got, tt.want)
+		}
+	}
+	// The layer is found in place, not copied
+	s.FindByGDS(19, 0).Color = "#000000"
+	if s.Find("Via1").Color != "#000000" {
+		t.Error("FindByGDS returned a copy")
+	}
+}
+
+func TestStackupValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(Stackup) Stackup
+		errs   []string
+	}{
+		{"valid", func(s Stackup) Stackup { return s }, nil},
+		{"no name", func(s Stackup) Stackup { s[1].Name = ""; return s }, []string{"layer 2 has no name"}},
+		{"twice", func(s Stackup) Stackup { return append(s, s[3]) }, []string{"layer Via1 is in the stack twice"}},
+		{"bad color", func(s Stackup) Stackup { s[3].Color = "#ccc"; return s }, []string{"layer Via1: bad color"}},
+		{"unknown group", func(s Stackup) Stackup { s[3].Group = "cuts"; return s }, []string{`layer Via1: unknown group "cuts"`}},
+		{"all of them", func(s Stackup) Stackup {
+			s[0].Color = "gray"
+			s[4].Group = ""
+			return append(s, s[1])
+		}, []string{"layer Substrate: bad color", `layer Metal2: unknown group ""`, "layer Metal1 is in the stack twice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.change(testStack()).Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.errs)
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.errs) {
+				t.Errorf("got %d errors %q, want %d", len(lines), lines, len(tt.errs))
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("%q does not report %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStackupApplyOverrides(t *testing.T) {
+	filter, show, metal, tolerance := 0.4, false, false, 0.02
+	s := testStack()
+	missing := s.ApplyOverrides([]layerOverride{
+		{Name: "Metal1", Color: "#ff0000", Source: "config"},
+		{Name: "Metal1", Filter: &filter, Show: &show},
+		{Name: "Metal9", Color: "#00ff00"},
+		{Name: "Metal2", Metal: &metal, Tolerance: &tolerance, Group: "passive"},
+		{Name: "Via1", Source: "ignored without a color"},
+	})
+	if len(missing) != 1 || missing[0].Name != "Metal9" {
+		t.Errorf("missing %+v, want Metal9", missing)
+	}
+	want := testStack()
+	want[1].Color, want[1].ColorSource = "#ff0000", "config"
+	want[1].Filter, want[1].Show = 0.4, false
+	want[4].Metal, want[4].Tolerance, want[4].Group = 0, 0.02, "passive"
+	for i := range want {
+		if !reflect.DeepEqual(s[i], want[i]) {
+			t.Errorf("layer %d = %+v, want %+v", i, s[i], want[i])
+		}
+	}
+}
+
+// The indexes a registry was kept up to date in are the ones of a
+// registry built from scratch
+func checkRegistry(t *testing.T, r *layerRegistry, s []Layer) {
+	t.Helper()
+	fresh := newLayerRegistry(s)
+	compact := func(m map[string][]int) map[string][]int {
+		out := map[string][]int{}
+		for k, v := range m {
+			if len(v) > 0 {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	if got, want := compact(r.byName), compact(fresh.byName); !reflect.DeepEqual(got, want) {
+		t.Errorf("by name %v, want %v", got, want)
+	}
+	if got, want := compact(r.byLyp), compact(fresh.byLyp); !reflect.DeepEqual(got, want) {
+		t.Errorf("by lyp name %v, want %v", got, want)
+	}
+	for key, want := range fresh.byGDS {
+		if got := r.gds(key.Layer, key.Datatype); !slices.Equal(got, want) {
+			t.Errorf("gds %d/%d = %v, want %v", key.Layer, key.Datatype, got, want)
+		}
+	}
+	for key, got := range r.byGDS {
+		if len(got) > 0 && len(fresh.byGDS[key]) == 0 {
+			t.Errorf("gds %d/%d = %v, want none", key.Layer, key.Datatype, got)
+		}
+	}
+}
+
+func TestLayerRegistry(t *testing.T) {
+	s := testStack()
+	r := newLayerRegistry(s)
+	checkRegistry(t, r, s)
+	if got := r.gds(8, 0); !slices.Equal(got, []int{1}) {
+		t.Errorf("gds 8/0 = %v, want [1]", got)
+	}
+	if got := r.lyp("Metal1.drawing"); !slices.Equal(got, []int{1}) {
+		t.Errorf("lyp Metal1.drawing = %v, want [1]", got)
+	}
+	if got := r.lyp("Metal1.pin"); !slices.Equal(got, []int{2}) {
+		t.Errorf("lyp Metal1.pin = %v, want [2]", got)
+	}
+
+	// Moving a layer to the GDS pair of another keeps both, in stack order
+	r.remove(4, s[4])
+	s[4].GDSNumber = 8
+	r.add(4, s[4])
+	checkRegistry(t, r, s)
+	if got := r.gds(8, 0); !slices.Equal(got, []int{1, 4}) {
+		t.Errorf("gds 8/0 = %v, want [1 4]", got)
+	}
+	// Adding twice and removing what is not there change nothing
+	r.add(4, s[4])
+	r.remove(3, s[4])
+	checkRegistry(t, r, s)
+}
+
+func TestLayerRegistryUpdates(t *testing.T) {
+	number, datatype, height := 50, 3, 2.5
+	tests := []struct {
+		name   string
+		update func(Stackup, *layerRegistry)
+		gds    [2]int // where Metal2 is drawn from afterwards
+	}{
+		{"lyp", func(s Stackup, r *layerRegistry) {
+			update_layerstack(s, r, KLayer{Name: "Metal2.drawing", Number: "50/3", Color: "#ffffff"})
+		}, [2]int{50, 3}},
+		{"lyp of another layer", func(s Stackup, r *layerRegistry) {
+			update_layerstack(s, r, KLayer{Name: "Metal1.pin", Number: "50/3", Color: "#ffffff"})
+		}, [2]int{10, 0}},
+		{"pdk", func(s Stackup, r *layerRegistry) {
+			update_layerstack_pdk(s, r, PDKLayer{Name: "Metal2", GDSNumber: &number, GDSDatatype: &datatype})
+		}, [2]int{50, 3}},
+		{"pdk without gds", func(s Stackup, r *layerRegistry) {
+			update_layerstack_pdk(s, r, PDKLayer{Name: "Metal2", Height: &height})
+		}, [2]int{10, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testStack()
+			r := newLayerRegistry(s)
+			tt.update(s, r)
+			checkRegistry(t, r, s)
+			if got := r.gds(tt.gds[0], tt.gds[1]); !slices.Equal(got, []int{4}) {
+				t.Errorf("gds %d/%d = %v, want Metal2 at [4]", tt.gds[0], tt.gds[1], got)
+			}
+		})
+	}
+}
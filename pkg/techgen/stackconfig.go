@@ -0,0 +1,317 @@
+// Stack config with the designer's per-layer overrides of the generated
+// techfile, read from -config, e.g.
+/*
+
+{
+  "process": "IHP SG13G2",
+  "author": "Analog team",
+  "substrate": { "gds_layer": 255, "gds_datatype": 0, "depth": 10.0, "margin": 2.0, "color": "#404040", "filter": 0.5 },
+  "datatypes": [ { "layer": "Metal1", "purpose": "filler" } ],
+  "merge": [ { "layer": "Metal2", "sources": ["Metal2.filler"] } ],
+  "layers": [
+    { "name": "NWell", "filter": 0.7, "depth": 1.5 },
+    { "name": "MIM", "filter": 0.0 },
+    { "name": "Active", "show": false },
+    { "name": "GatPoly", "shortkey": "p", "conformal": "Active" },
+    { "name": "ResPoly", "group": "feol" },
+    { "name": "Metal1", "tolerance": 0.04 }
+  ]
+}
+*/
+// All values except name are optional, a shortkey of "" removes the key.
+// A depth in um extends a well or implant down into the substrate, the
+// layer keeps its top; a substrate filter lets the wells show through.
+// A tolerance in um is used by the thickness corners, see variant.go.
+// Layers are planarized, at the height of the inputs, unless they are
+// conformal over another layer: then they sit on its top, e.g. poly over
+// active, after variants and via interpolation have moved it.
+// The config is applied after all input files, so it wins over lyp, LEF,
+// stackup and PDK data. Process, author and license go into the techfile
+// header. The substrate values default to the IHP ones, GDS 255/0 from
+// 10 um below the surface with the lyp color, opaque and flush with the
+// die. A thin film or SOI stack wants a shallow depth, the margin in um
+// widens the substrate beyond the die in the 3D exports. Datatypes, merge
+// and masks are described in datatypes.go, dielectrics in dielectrics.go,
+// derived in derived.go, packaging in packaging.go, backside in
+// backside.go, beol in beol.go, ild and beol_height in heights.go, mim in
+// mim.go, feol in feol.go, aliases and rename in aliases.go, extends in
+// profiles.go.
+
+package techgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+)
+
+type stackConfigLayer struct {
+	Name     string   `json:"name"`
+	Filter   *float64 `json:"filter"`
+	Show     *bool    `json:"show"`
+	Shortkey *string  `json:"shortkey"`
+	Group    string   `json:"group"`
+	Metal    *bool    `json:"metal"`
+	Depth    *float64 `json:"depth"`
+	// Thickness tolerance in um for -corner min and max
+	Tolerance *float64 `json:"tolerance"`
+	// Layer whose topology this one follows, "" for planarized
+	Conformal string `json:"conformal"`
+}
+
+type substrateConfig struct {
+	GDSNumber   *int     `json:"gds_layer"`
+	GDSDatatype *int     `json:"gds_datatype"`
+	Depth       *float64 `json:"depth"`
+	Margin      *float64 `json:"margin"`
+	Color       string   `json:"color"`
+	Filter      *float64 `json:"filter"`
+}
+
+type stackConfig struct {
+	Process     string                `json:"process"`
+	Author      string                `json:"author"`
+	License     string                `json:"license"`
+	Substrate   *substrateConfig      `json:"substrate"`
+	Datatypes   []datatypeConfig      `json:"datatypes"`
+	Merge       []mergeConfig         `json:"merge"`
+	Masks       []maskConfig          `json:"masks"`
+	Derived     []derivedConfig       `json:"derived"`
+	Dielectrics []dielectricConfig    `json:"dielectrics"`
+	Packaging   []packagingConfig     `json:"packaging"`
+	Backside    []backsideConfig      `json:"backside"`
+	BEOL        map[string]beolConfig `json:"beol"`
+	ILD         *ildConfig            `json:"ild"`
+	BEOLHeight  *beolHeightConfig     `json:"beol_height"`
+	MIM         *mimConfig            `json:"mim"`
+	FEOL        *feolConfig           `json:"feol"`
+	Aliases     map[string]string     `json:"aliases"`
+	Rename      []renameRule          `json:"rename"`
+	Layers      []stackConfigLayer    `json:"layers"`
+}
+
+func parseStackConfig(ctx context.Context, r *run, filePath string) (*stackConfig, error) {
+	profile, err := loadStackProfile(ctx, r, filePath, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	config := &stackConfig{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(config); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	if sub := config.Substrate; sub != nil {
+		if sub.Depth != nil && *sub.Depth <= 0.0 {
+			return nil, fmt.Errorf("%s: substrate depth %g, expected a positive depth in um", filePath, *sub.Depth)
+		}
+		if sub.Margin != nil && *sub.Margin < 0.0 {
+			return nil, fmt.Errorf("%s: substrate margin %g, expected a margin in um", filePath, *sub.Margin)
+		}
+		if sub.Filter != nil && (*sub.Filter < 0.0 || *sub.Filter > 1.0) {
+			return nil, fmt.Errorf("%s: substrate filter %g, expected 0.0 to 1.0", filePath, *sub.Filter)
+		}
+		if sub.Color != "" {
+			if _, _, _, err := gds3d.ParseColor(sub.Color); err != nil {
+				return nil, fmt.Errorf("%s: substrate color: %w", filePath, err)
+			}
+		}
+	}
+	for i, dt := range config.Datatypes {
+		if dt.Layer == "" || dt.Purpose == "" {
+			return nil, fmt.Errorf("%s: datatype %d needs layer and purpose", filePath, i)
+		}
+	}
+	for i, m := range config.Merge {
+		if m.Layer == "" || len(m.Sources) == 0 {
+			return nil, fmt.Errorf("%s: merge %d needs layer and sources", filePath, i)
+		}
+	}
+	if config.ILD != nil {
+		if err := config.ILD.validate(); err != nil {
+			return nil, fmt.Errorf("%s: ild %w", filePath, err)
+		}
+	}
+	if config.BEOLHeight != nil {
+		if err := config.BEOLHeight.validate(); err != nil {
+			return nil, fmt.Errorf("%s: beol_height %w", filePath, err)
+		}
+	}
+	for i := range config.Rename {
+		if err := config.Rename[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+	if config.FEOL != nil {
+		if err := config.FEOL.validate(); err != nil {
+			return nil, fmt.Errorf("%s: feol %w", filePath, err)
+		}
+	}
+	if mim := config.MIM; mim != nil && mim.Dielectric != nil && *mim.Dielectric < 0.0 {
+		return nil, fmt.Errorf("%s: mim dielectric %g, expected a thickness in um", filePath, *mim.Dielectric)
+	}
+	for i, d := range config.Dielectrics {
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("%s: dielectric %d %w", filePath, i, err)
+		}
+	}
+	for i, p := range config.Packaging {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: packaging %d %w", filePath, i, err)
+		}
+	}
+	for i, b := range config.Backside {
+		if err := b.validate(); err != nil {
+			return nil, fmt.Errorf("%s: backside %d %w", filePath, i, err)
+		}
+	}
+	for name, b := range config.BEOL {
+		if err := b.validate(name); err != nil {
+			return nil, fmt.Errorf("%s: beol %s %w", filePath, name, err)
+		}
+	}
+	for i, d := range config.Derived {
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("%s: derived %d %w", filePath, i, err)
+		}
+	}
+	for i, m := range config.Masks {
+		if m.Layer == "" || len(m.Datatypes) == 0 {
+			return nil, fmt.Errorf("%s: mask %d needs layer and datatypes", filePath, i)
+		}
+	}
+	for i, layer := range config.Layers {
+		if layer.Name == "" {
+			return nil, fmt.Errorf("%s: layer %d has no name", filePath, i)
+		}
+		if layer.Filter != nil && (*layer.Filter < 0.0 || *layer.Filter > 1.0) {
+			return nil, fmt.Errorf("%s: layer %s has filter %g, expected 0.0 to 1.0", filePath, layer.Name, *layer.Filter)
+		}
+		if layer.Group != "" && !isLayerGroup(layer.Group) {
+			return nil, fmt.Errorf("%s: layer %s has unknown group %q", filePath, layer.Name, layer.Group)
+		}
+		if layer.Depth != nil && *layer.Depth <= 0.0 {
+			return nil, fmt.Errorf("%s: layer %s has depth %g, expected a positive depth in um", filePath, layer.Name, *layer.Depth)
+		}
+		if layer.Tolerance != nil && *layer.Tolerance < 0.0 {
+			return nil, fmt.Errorf("%s: layer %s has tolerance %g, expected a tolerance in um", filePath, layer.Name, *layer.Tolerance)
+		}
+		if layer.Shortkey != nil && len(*layer.Shortkey) > 1 {
+			return nil, fmt.Errorf("%s: layer %s has shortkey %q, expected one character", filePath, layer.Name, *layer.Shortkey)
+		}
+	}
+	return config, nil
+}
+
+func update_layerstack_config(LayerStack Stackup, config *stackConfig) []Warning {
+	var warnings []Warning
+	if config.Substrate != nil {
+		update_layerstack_substrate(LayerStack, *config.Substrate)
+	}
+	regrouped := false
+	var changes []layerOverride
+	for _, layer := range config.Layers {
+		if l := LayerStack.Find(layer.Name); l != nil {
+			if layer.Group != "" && layer.Group != l.Group {
+				regrouped = true
+			}
+			if layer.Depth != nil {
+				top := l.Height + l.Thickness
+				l.Height = -*layer.Depth
+				l.Thickness = top + *layer.Depth
+				l.ZSource = fmt.Sprintf("stack config depth %g um", *layer.Depth)
+			}
+		}
+		changes = append(changes, layerOverride{
+			Name:      layer.Name,
+			Filter:    layer.Filter,
+			Show:      layer.Show,
+			Metal:     layer.Metal,
+			Tolerance: layer.Tolerance,
+			Group:     layer.Group,
+		})
+	}
+	for _, o := range LayerStack.ApplyOverrides(changes) {
+		warnings = append(warnings, warn("unknown-layer", "stack config: no layer %s in the stack", o.Name))
+	}
+
+	// Moved layers take a key from their new group's row, explicit keys
+	// from the config win over the rows
+	if regrouped {
+		assignShortkeys(LayerStack)
+	}
+	for _, layer := range config.Layers {
+		if l := LayerStack.Find(layer.Name); l != nil && layer.Shortkey != nil {
+			l.Shortkey = *layer.Shortkey
+		}
+	}
+	return warnings
+}
+
+func (config *stackConfig) headerInfo(info techFileInfo) techFileInfo {
+	if config.Process != "" {
+		info.Process = config.Process
+	}
+	if config.Author != "" {
+		info.Author = config.Author
+	}
+	if config.License != "" {
+		info.License = config.License
+	}
+	return info
+}
+
+// The GDS number and margin of the substrate apply to the written stack,
+// see run.useConfig
+func update_layerstack_substrate(LayerStack []Layer, sub substrateConfig) {
+	for i, l := range LayerStack {
+		if l.Name != "Substrate" {
+			continue
+		}
+		if sub.GDSDatatype != nil {
+			LayerStack[i].GDSDatatype = *sub.GDSDatatype
+			LayerStack[i].ColorSource = "stack config"
+		}
+		if sub.Color != "" {
+			LayerStack[i].Color = sub.Color
+			LayerStack[i].ColorSource = "stack config"
+		}
+		if sub.Filter != nil {
+			LayerStack[i].Filter = *sub.Filter
+		}
+		if sub.Depth != nil {
+			LayerStack[i].Height = -*sub.Depth
+			LayerStack[i].Thickness = *sub.Depth
+			LayerStack[i].ZSource = "stack config"
+		}
+	}
+}
+
+func update_layerstack_conformal(LayerStack []Layer, config *stackConfig) []Warning {
+	var warnings []Warning
+	if config == nil {
+		return nil
+	}
+	for _, layer := range config.Layers {
+		if layer.Conformal == "" {
+			continue
+		}
+		i, over := layerIndex(LayerStack, layer.Name), layerIndex(LayerStack, layer.Conformal)
+		if i < 0 || over < 0 {
+			warnings = append(warnings, warn("unknown-layer", "stack config: %s is conformal over %s, but one of them is not in the stack", layer.Name, layer.Conformal))
+			continue
+		}
+		LayerStack[i].Height = LayerStack[over].Height + LayerStack[over].Thickness
+		LayerStack[i].ZSource = "conformal on " + layer.Conformal
+	}
+	return warnings
+}
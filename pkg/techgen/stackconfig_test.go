@@ -0,0 +1,29 @@
+package techgen
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseStackConfigSubstrateColor(t *testing.T) {
+	tests := []struct {
+		color string
+		ok    bool
+	}{
+		{"#404040", true},
+		{"#40404", false},
+		{"#gggggg", false},
+		{"404040x", false},
+	}
+	for _, tt := range tests {
+		r, err := newRun(DefaultOptions(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.files = map[string][]byte{"stack.json": []byte(`{"substrate": {"color": "` + tt.color + `"}}`)}
+		_, err = parseStackConfig(context.Background(), r, "stack.json")
+		if (err == nil) != tt.ok {
+			t.Errorf("substrate color %s: error %v, want ok %t", tt.color, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Reader for GDS3D techfiles
+//
+// Loads the LayerStart ... LayerEnd blocks of an existing techfile back into
+// layers, so a techfile from another tool or an older run can be used as
+// the stack:
+//
+//	build_3d_techfile -from-techfile old.txt -o new.txt -export svg
+//
+// Height and Thickness are read in the -unit of the run. The comments this
+// tool writes are picked up as well: section banners give the group and the
+// "# GDS and color" line the sources, other comments are skipped.
+
+package techgen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+)
+
+func readTechFile(ctx context.Context, r *run, name string) ([]Layer, error) {
+	return decodeInput(ctx, r, name, func(file io.Reader, name string) ([]Layer, error) {
+		return decodeTechFile(file, name, r.Unit)
+	})
+}
+
+// Lengths without a unit in the file are read as unit
+func decodeTechFile(file io.Reader, name, unit string) ([]Layer, error) {
+
+	blocks, err := gds3d.Read(file, name, unit)
+	if err != nil {
+		return nil, err
+	}
+	return techFileLayers(blocks, name), nil
+}
+
+// The stack layers of the blocks of techfile name
+func techFileLayers(blocks []gds3d.Layer, name string) []Layer {
+	var LayerStack []Layer
+	group := ""
+	for _, b := range blocks {
+		colorSource, zSource := "", ""
+		for _, comment := range b.Comments {
+			for _, g := range layerGroups {
+				if comment == g.Title {
+					group = g.Name
+				}
+			}
+			if sources, ok := strings.CutPrefix(comment, "GDS and color: "); ok {
+				if i := strings.LastIndex(sources, ", z: "); i >= 0 {
+					colorSource, zSource = sources[:i], sources[i+len(", z: "):]
+				}
+			}
+		}
+		if colorSource == "" {
+			colorSource = fmt.Sprintf("techfile %s line %d", name, b.Line)
+			zSource = colorSource
+		}
+		layer := Layer{
+			Name:        b.Name,
+			altName:     b.Name,
+			GDSNumber:   b.Layer,
+			GDSDatatype: b.Datatype,
+			Color:       fmt.Sprintf("#%02x%02x%02x", colorByte(b.Red), colorByte(b.Green), colorByte(b.Blue)),
+			Height:      b.Height,
+			Thickness:   b.Thickness,
+			Metal:       b.Metal,
+			Filter:      b.Filter,
+			Show:        b.Show,
+			Shortkey:    b.Shortkey,
+			Group:       group,
+			ColorSource: colorSource,
+			ZSource:     zSource,
+		}
+		if layer.Group == "" {
+			layer.Group = defaultGroup(layer)
+		}
+		LayerStack = append(LayerStack, layer)
+	}
+	return LayerStack
+}
+
+func colorByte(v float64) int {
+	return int(math.Round(min(max(v, 0), 1) * 255))
+}
@@ -0,0 +1,124 @@
+package techgentest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lines of context around a change
+const diffContext = 2
+
+// Diff is a line diff of two techfiles, each hunk headed by its line in
+// want and the layer it is in:
+//
+//	@@ line 113, layer Metal1 @@
+//	 Datatype: 0
+//	-Height: 930
+//	+Height: 940
+//	 Thickness: 400
+func Diff(want, got []byte) string {
+	a, b := splitLines(want), splitLines(got)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs until diffContext*2 unchanged lines in a row
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				break
+			}
+			end = run
+		}
+		stop := min(end+diffContext, len(ops))
+		line := ops[start].a
+		// An added line goes after the line before it in a
+		at := ops[i].a
+		if ops[i].kind == '+' {
+			at--
+		}
+		fmt.Fprintf(&out, "@@ line %d%s @@\n", line+1, layerAt(a, at))
+		for _, op := range ops[start:stop] {
+			if op.kind == '+' {
+				fmt.Fprintf(&out, "+%s\n", b[op.b])
+			} else {
+				fmt.Fprintf(&out, "%c%s\n", op.kind, a[op.a])
+			}
+		}
+		i = stop
+	}
+	return out.String()
+}
+
+func splitLines(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// One line of the diff: ' ' in both, '-' only in a, '+' only in b. a and
+// b are the lines in either, for '+' a is where it goes in a.
+type diffOp struct {
+	kind byte
+	a, b int
+}
+
+// Longest common subsequence of the lines, techfiles are a few hundred
+// lines so the table is small
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', i, j})
+			i++
+		}
+	}
+	return ops
+}
+
+// The layer line i of a techfile is in, ", layer name" or "" outside of
+// the layers
+func layerAt(lines []string, i int) string {
+	for i = min(i, len(lines)-1); i >= 0; i-- {
+		switch line := lines[i]; {
+		case line == "LayerEnd":
+			return ""
+		case strings.HasPrefix(line, "LayerStart:"):
+			return ", layer " + strings.TrimSpace(strings.TrimPrefix(line, "LayerStart:"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package techgentest
+
+import (
+	"strings"
+	"testing"
+)
+
+const diffTechFile = `# Autogenerated GDS3D techfile
+LayerStart: Metal1
+Layer: 8
+Datatype: 0
+Height: 930
+Thickness: 400
+Red: 0.22
+Green: 0.75
+Blue: 1
+LayerEnd
+LayerStart: Metal2
+Layer: 10
+Datatype: 0
+Height: 2000
+Thickness: 450
+LayerEnd
+`
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"same", diffTechFile, ""},
+		{"changed line", replaceLine(diffTechFile, "Height: 930", "Height: 940"), `@@ line 3, layer Metal1 @@
+ Layer: 8
+ Datatype: 0
+-Height: 930
++Height: 940
+ Thickness: 400
+ Red: 0.22
+`},
+		{"added line", replaceLine(diffTechFile, "Thickness: 450", "Thickness: 450\nMetal: 1"), `@@ line 14, layer Metal2 @@
+ Height: 2000
+ Thickness: 450
++Metal: 1
+ LayerEnd
+`},
+		{"added between layers", replaceLine(diffTechFile, "LayerEnd\n", "LayerEnd\n# Metal2\n"), `@@ line 9 @@
+ Blue: 1
+ LayerEnd
++# Metal2
+ LayerStart: Metal2
+ Layer: 10
+`},
+		{"removed line", replaceLine(diffTechFile, "# Autogenerated GDS3D techfile\n", ""), `@@ line 1 @@
+-# Autogenerated GDS3D techfile
+ LayerStart: Metal1
+ Layer: 8
+`},
+		{"two hunks", replaceLine(replaceLine(diffTechFile, "Layer: 8", "Layer: 9"), "Height: 2000", "Height: 2100"), `@@ line 1, layer Metal1 @@
+ # Autogenerated GDS3D techfile
+ LayerStart: Metal1
+-Layer: 8
++Layer: 9
+ Datatype: 0
+ Height: 930
+@@ line 12, layer Metal2 @@
+ Layer: 10
+ Datatype: 0
+-Height: 2000
++Height: 2100
+ Thickness: 450
+ LayerEnd
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff([]byte(diffTechFile), []byte(tt.got)); got != tt.want {
+				t.Errorf("got diff:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func replaceLine(techFile, old, new string) string {
+	return strings.Replace(techFile, old, new, 1)
+}
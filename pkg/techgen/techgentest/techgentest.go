@@ -0,0 +1,153 @@
+// Package techgentest checks the techfiles techgen generates against
+// golden files, for the regression tests of PDK repos:
+//
+//	func TestTechFile(t *testing.T) {
+//		techgentest.Golden(t, techgen.Inputs{
+//			Lyp: "../sg13g2.lyp",
+//			LEF: "../sg13g2_tech.lef",
+//		}, "testdata/sg13g2.txt")
+//	}
+//
+// Run the test once with Update set, e.g. from an -update flag of the
+// test, to write the golden file, and commit it. From then on a change of
+// the PDK or the generator fails the test with the lines that differ and
+// the layer they are in.
+//
+// The package ships fixtures, the sg13g2 lyp/LEF pair of the repo root
+// together with its golden techfile sg13g2.txt, to check a build of the
+// generator without a PDK checkout.
+package techgentest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/techgen"
+)
+
+// Update writes the golden files instead of comparing with them
+var Update = false
+
+// Clock stamps the generated techfiles, so the Date of the header is the
+// same in every run
+var Clock techgen.Clock = techgen.FixedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+// Fixture is a lyp/LEF pair embedded in the package
+type Fixture struct {
+	Name   string // e.g. sg13g2
+	Lyp    string // file name of the lyp
+	LEF    string // file name of the LEF
+	Golden string // file name of the techfile generated from them
+}
+
+// Fixtures are the lyp/LEF pairs of the package
+var Fixtures = []Fixture{
+	{Name: "sg13g2", Lyp: "sg13g2.lyp", LEF: "sg13g2_tech.lef", Golden: "sg13g2.txt"},
+}
+
+// Inputs of techgen.Generate for f, read from the embedded files
+func (f Fixture) Inputs() techgen.Inputs {
+	return techgen.Inputs{
+		Lyp:   f.Lyp,
+		LEF:   f.LEF,
+		Clock: Clock,
+		Files: map[string][]byte{f.Lyp: f.read(f.Lyp), f.LEF: f.read(f.LEF)},
+	}
+}
+
+// Want is the golden techfile of f
+func (f Fixture) Want() []byte {
+	return f.read(f.Golden)
+}
+
+func (f Fixture) read(name string) []byte {
+	data, err := build_3d_techfile.Fixtures.ReadFile(name)
+	if err != nil {
+		panic(fmt.Sprintf("techgentest: fixture %s: %v", f.Name, err))
+	}
+	return data
+}
+
+// Generate runs techgen.Generate on inputs, with Clock unless they have a
+// clock of their own. It fails t if the generator does, its warnings are
+// logged.
+func Generate(t testing.TB, inputs techgen.Inputs) []byte {
+	t.Helper()
+	if inputs.Clock == nil {
+		inputs.Clock = Clock
+	}
+	res, err := techgen.Generate(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("generating techfile of %s and %s: %v", inputs.Lyp, inputs.LEF, err)
+	}
+	for _, w := range res.Warnings {
+		t.Log(w)
+	}
+	return res.TechFile
+}
+
+// Output generates f with the extra outputs exports, named as -export
+// takes them, and returns the output of its stack: the techfile, the
+// resolved stack and the exports by file name, e.g. sg13g2.gltf. It fails
+// t if the generator does.
+func (f Fixture) Output(t testing.TB, exports ...string) techgen.Output {
+	t.Helper()
+	inputs := f.Inputs()
+	inputs.Name = f.Golden
+	inputs.Exports = exports
+	res, err := techgen.Generate(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("generating %s of fixture %s: %v", strings.Join(exports, ", "), f.Name, err)
+	}
+	return res.Outputs[0]
+}
+
+// Golden generates the techfile of inputs and compares it with the file
+// at path, or writes it there with Update
+func Golden(t testing.TB, inputs techgen.Inputs, path string) {
+	t.Helper()
+	got := Generate(t, inputs)
+	if Update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with Update to write it): %v", err)
+	}
+	if err := Compare(want, got); err != nil {
+		t.Errorf("%s: %v", path, err)
+	}
+}
+
+// CheckFixtures generates every fixture and compares it with its golden
+// techfile
+func CheckFixtures(t testing.TB) {
+	t.Helper()
+	for _, f := range Fixtures {
+		if err := Compare(f.Want(), Generate(t, f.Inputs())); err != nil {
+			t.Errorf("fixture %s: %v", f.Name, err)
+		}
+	}
+}
+
+// Compare is nil if the techfiles are the same, an error with Diff of them
+// otherwise
+func Compare(want, got []byte) error {
+	if bytes.Equal(want, got) {
+		return nil
+	}
+	return fmt.Errorf("techfile differs from golden (-want +got):\n%s", strings.TrimSuffix(Diff(want, got), "\n"))
+}
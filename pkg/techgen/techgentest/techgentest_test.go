@@ -0,0 +1,7 @@
+package techgentest
+
+import "testing"
+
+func TestCheckFixtures(t *testing.T) {
+	CheckFixtures(t)
+}
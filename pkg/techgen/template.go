@@ -0,0 +1,107 @@
+// Generic stacks for toy PDKs, teaching and quick demos
+//
+//	build_3d_techfile -template generic4
+//	build_3d_techfile -template generic:3M+2IM+1TM
+//
+// Without real z data a template builds the stack from metal pitch
+// classes: thin (M), intermediate (IM, twice as thick) and thick top
+// metals (TM), bottom up with the dielectric gap of their class between
+// them. Thin and intermediate metals are Metal1, Metal2, ..., top metals
+// TopMetal1, ...; the vias between them are interpolated as usual and the
+// front end comes from the FEOL model. GDS numbers are generic: metal n
+// on 10+2n, the via above it on 11+2n, top metal k on 100+2k.
+
+package techgen
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type pitchClass struct {
+	Thickness float64 // um
+	Gap       float64 // um of dielectric below the metal
+	Color     string
+}
+
+var pitchClasses = map[string]pitchClass{
+	"M":  {0.35, 0.5, "#4080ff"},
+	"IM": {0.7, 0.7, "#40c0a0"},
+	"TM": {2.0, 1.0, "#e0a040"},
+}
+
+var namedTemplates = map[string]string{
+	"generic4":   "generic:4M",
+	"generic6tm": "generic:5M+1TM",
+}
+
+const templateMetal1Height = 0.8 // um, above the poly of the FEOL model
+
+var templatePart = regexp.MustCompile(`^(\d+)(M|IM|TM)$`)
+
+func templateNames() string {
+	return "generic4, generic6tm or generic:<n>M[+<n>IM][+<n>TM]"
+}
+
+func buildTemplateStack(name string) ([]Layer, error) {
+	spec := name
+	if named, ok := namedTemplates[name]; ok {
+		spec = named
+	}
+	parts, ok := strings.CutPrefix(spec, "generic:")
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q (use %s)", name, templateNames())
+	}
+
+	LayerStack := []Layer{
+		newLayer("Substrate", "Substrate", 255, 0, "#808080", -10.0, 10.0, 0),
+		newLayer("NWell", "NWell", 1, 0, "#268c6b", 0.0, 0.0, 0),
+		newLayer("Active", "Active", 2, 0, "#00c000", 0.0, 0.0, 0),
+		newLayer("GatPoly", "GatPoly", 3, 0, "#c04020", 0.0, 0.0, 0),
+		newLayer("Cont", "Cont", 4, 0, "#00ffff", 0.0, 0.0, 0),
+	}
+	metals, tops := 0, 0
+	top := templateMetal1Height
+	for i, part := range strings.Split(parts, "+") {
+		m := templatePart.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("template %s: bad part %q, expected e.g. 4M, 2IM or 1TM", name, part)
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("template %s: bad count in %q: %w", name, part, err)
+		}
+		class := pitchClasses[m[2]]
+		for k := 0; k < n; k++ {
+			height := top + class.Gap
+			if i == 0 && k == 0 {
+				height = templateMetal1Height
+			}
+			var metal, via string
+			var gds int
+			if m[2] == "TM" {
+				tops++
+				metal, via, gds = fmt.Sprintf("TopMetal%d", tops), fmt.Sprintf("TopVia%d", tops), 100+2*tops
+			} else {
+				metals++
+				metal, via, gds = fmt.Sprintf("Metal%d", metals), fmt.Sprintf("Via%d", metals-1), 10+2*metals
+			}
+			if metals+tops > 1 {
+				LayerStack = append(LayerStack, newLayer(via, via, gds-1, 0, "#c0c0c0", 0.0, 0.0, 0))
+			}
+			layer := newLayer(metal, metal, gds, 0, class.Color, height, class.Thickness, 1)
+			layer.ZSource = "template " + m[2]
+			LayerStack = append(LayerStack, layer)
+			top = height + class.Thickness
+		}
+	}
+	if metals == 0 {
+		return nil, fmt.Errorf("template %s: needs at least one thin or intermediate metal", name)
+	}
+	for i := range LayerStack {
+		LayerStack[i].ColorSource = "template"
+	}
+	return LayerStack, nil
+}
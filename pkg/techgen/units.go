@@ -0,0 +1,146 @@
+// Length units
+//
+// The stack is kept in micron. Inputs with other units are scaled when
+// they are read, the techfile is written in nanometer by default, as GDS3D
+// expects, with -unit and -precision to match other viewers.
+
+package techgen
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/stackup"
+)
+
+// Scale factors to micron, for the HFSS LengthUnit attribute and the
+// units of PDK dumps. An empty unit is micron.
+var lengthUnits = stackup.LengthUnits
+
+var techFileUnitDigits = gds3d.UnitDigits
+
+// Fixed writes every decimal, minimal drops trailing zeros (0.80 as 0.8,
+// 1.000 as 1) for parsers that want short numbers
+var techFileNumberStyles = map[string]bool{"fixed": true, "minimal": true}
+
+// The techfile format of -unit, -precision, -numbers and -format. A
+// precision below zero means the default of the unit: whole nanometer or
+// micron with three decimals.
+func (o Options) numberFormat() gds3d.Format {
+	return gds3d.Format{
+		Unit:      o.Unit,
+		Precision: o.Precision,
+		Minimal:   o.Numbers == "minimal",
+		Keys:      techFileFormats[o.Format],
+	}
+}
+
+// -z-offset and -z-scale only move the written copies, e.g. thicknesses
+// exaggerated for a presentation or the die surface at z=0, the resolved
+// stack and its IR keep the process heights
+func (o Options) zTransformed() bool {
+	return o.ZOffset != 0.0 || o.ZScale != 1.0
+}
+
+// GDS3D draws the substrate for layer 255, whatever the lyp calls it.
+// Flows that use 255 for something else set another number in the stack
+// config.
+func (r *run) gdsNumber(layer Layer) int {
+	if layer.Name == "Substrate" {
+		return r.substrateGDSNumber
+	}
+	return layer.GDSNumber
+}
+
+// Copy of the stack as written: heights scaled around z=0 then offset, and
+// the substrate on its GDS number, which its provenance then names
+func (r *run) writtenStack(LayerStack []Layer) []Layer {
+	written := slices.Clone(LayerStack)
+	for i, l := range written {
+		written[i].GDSNumber = r.gdsNumber(l)
+		if written[i].GDSNumber != l.GDSNumber {
+			written[i].ColorSource = r.substrateGDSSource + ", color " + l.ColorSource
+		}
+		if r.zTransformed() {
+			written[i].Height = l.Height*r.ZScale + r.ZOffset
+			written[i].Thickness = l.Thickness * r.ZScale
+		}
+	}
+	return written
+}
+
+// For the header, so a moved techfile is not taken for the process stack
+func (o Options) zTransformNote() string {
+	if !o.zTransformed() {
+		return ""
+	}
+	return fmt.Sprintf("heights scaled %gx, offset %g um", o.ZScale, o.ZOffset)
+}
+
+// Limits for lengths that are most likely given in the wrong unit, a
+// nanometer value read as micron or the other way round
+const (
+	suspiciousThickness = 50.0  // um, more than any BEOL layer
+	suspiciousHeight    = 100.0 // um, above any passivation
+	tinyThickness       = 0.005 // um, thinner than any drawn layer
+)
+
+func checkLengths(LayerStack []Layer, f gds3d.Format) []Warning {
+	var warnings []Warning
+	for _, l := range LayerStack {
+		if l.Name == "Substrate" {
+			continue
+		}
+		switch {
+		case l.Thickness > suspiciousThickness:
+			warnings = append(warnings, warn("units", "%s: thickness %g um is suspiciously large, nm given as um?", l.Name, l.Thickness))
+		case l.Thickness > 0.0 && l.Thickness < tinyThickness:
+			warnings = append(warnings, warn("units", "%s: thickness %g um is suspiciously small, um given as nm?", l.Name, l.Thickness))
+		}
+		if math.Abs(l.Height) > suspiciousHeight {
+			warnings = append(warnings, warn("units", "%s: height %g um is suspiciously far from the substrate, nm given as um?", l.Name, l.Height))
+		}
+	}
+	// Lengths below the output precision vanish from the techfile
+	for _, l := range LayerStack {
+		if l.Thickness > 0.0 && f.Length(l.Thickness) == f.Length(0) {
+			warnings = append(warnings, warn("rounds-to-zero", "%s: thickness %g um rounds to 0 %s, raise -precision", l.Name, l.Thickness, f.Unit))
+		}
+	}
+	return warnings
+}
+
+// What -zero-thickness does with layers that end up without thickness: no
+// LEF data and no vias to interpolate from. With -sliver, layers that are
+// not empty but thinner, e.g. a via between metals 2 nm apart, are clamped
+// to it so they neither vanish nor flicker against their neighbours.
+var zeroThicknessPolicies = map[string]bool{
+	"warn": true, // write them, they render as invisible planes
+	"drop": true, // leave them out of the techfile and the exports
+	"min":  true, // give them -min-thickness
+}
+
+func update_layerstack_zero_thickness(r *run, LayerStack []Layer) ([]Layer, []Warning) {
+	var warnings []Warning
+	var kept []Layer
+	for _, l := range LayerStack {
+		if l.Thickness <= 0.0 {
+			switch r.ZeroThickness {
+			case "drop":
+				r.progress("Dropped %s: thickness %g um", l.Name, l.Thickness)
+				continue
+			case "min":
+				l.Thickness = r.MinThickness
+				l.ZSource += ", min thickness"
+			}
+		} else if l.Thickness < r.Sliver {
+			warnings = append(warnings, note("sliver", "%s: thickness %g um is a sliver, clamped to %g um", l.Name, roundUm(l.Thickness), r.Sliver))
+			l.Thickness = r.Sliver
+			l.ZSource += ", clamped"
+		}
+		kept = append(kept, l)
+	}
+	return kept, warnings
+}
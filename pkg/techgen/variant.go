@@ -0,0 +1,166 @@
+// Stack variants generated in the same run
+//
+// A variant is a named list of edits applied to the stack after the input
+// data is merged and before vias are interpolated, e.g.
+//
+//	-variant nomim:-MIM
+//	-variant thintm2:TopMetal2.thickness=2.0,TopVia2.thickness=0
+//
+// Each variant is written next to the main techfile with a _name suffix.
+//
+// Thickness corners follow the tolerances of the stack config layers:
+// -corner min or max makes every layer thinner or thicker by its tolerance
+// and moves the layers above it along, vias are interpolated afterwards.
+// -corner all writes each variant three times, with _min, _typ and _max.
+
+package techgen
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type variantOp struct {
+	Layer string
+	Field string // "" removes the layer, else "thickness" or "height"
+	Value float64
+}
+
+type variant struct {
+	Name   string
+	Ops    []variantOp
+	Corner string // typ, min or max
+}
+
+var stackCorners = []string{"min", "typ", "max"}
+
+// The variants of a run for -corner, all multiplies them by the corners
+func cornerVariants(variants []variant, corner string) []variant {
+	var runs []variant
+	for _, v := range variants {
+		if corner != "all" {
+			v.Corner = corner
+			runs = append(runs, v)
+			continue
+		}
+		for _, c := range stackCorners {
+			cv := v
+			cv.Corner = c
+			cv.Name = strings.TrimPrefix(v.Name+"_"+c, "_")
+			runs = append(runs, cv)
+		}
+	}
+	return runs
+}
+
+// Specs of -variant, checked as they are given
+type variantList []string
+
+func (vl *variantList) String() string {
+	return strings.Join(*vl, " ")
+}
+
+func (vl *variantList) Set(spec string) error {
+	if _, err := parseVariant(spec); err != nil {
+		return err
+	}
+	*vl = append(*vl, spec)
+	return nil
+}
+
+func parseVariant(spec string) (variant, error) {
+	name, ops, ok := strings.Cut(spec, ":")
+	if !ok || name == "" {
+		return variant{}, fmt.Errorf("variant %q: expected name:op,op,...", spec)
+	}
+	v := variant{Name: name}
+	for _, op := range strings.Split(ops, ",") {
+		op = strings.TrimSpace(op)
+		if layer, found := strings.CutPrefix(op, "-"); found {
+			v.Ops = append(v.Ops, variantOp{Layer: layer})
+			continue
+		}
+		target, value, found := strings.Cut(op, "=")
+		layer, field, _ := strings.Cut(target, ".")
+		if !found || (field != "thickness" && field != "height") {
+			return variant{}, fmt.Errorf("variant %s: bad op %q, expected -Layer or Layer.thickness|height=um", name, op)
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return variant{}, fmt.Errorf("variant %s: bad value in %q", name, op)
+		}
+		v.Ops = append(v.Ops, variantOp{Layer: layer, Field: field, Value: f})
+	}
+	return v, nil
+}
+
+func (v variant) apply(LayerStack []Layer) ([]Layer, []Warning) {
+	var warnings []Warning
+	for _, op := range v.Ops {
+		found := false
+		for i := 0; i < len(LayerStack); i++ {
+			if LayerStack[i].Name != op.Layer {
+				continue
+			}
+			found = true
+			switch op.Field {
+			case "":
+				LayerStack = append(LayerStack[:i], LayerStack[i+1:]...)
+				i--
+			case "thickness":
+				LayerStack[i].Thickness = op.Value
+				LayerStack[i].ZSource = "variant " + v.Name
+			case "height":
+				LayerStack[i].Height = op.Value
+				LayerStack[i].ZSource = "variant " + v.Name
+			}
+		}
+		if !found {
+			warnings = append(warnings, warn("unknown-layer", "variant %s: no layer %s in the stack", v.Name, op.Layer))
+		}
+	}
+	if v.Corner == "min" || v.Corner == "max" {
+		applyCorner(LayerStack, v.Corner)
+	}
+	return LayerStack, warnings
+}
+
+// Bottom up, each layer with a tolerance moves everything above it
+func applyCorner(LayerStack []Layer, corner string) {
+	sign := 1.0
+	if corner == "min" {
+		sign = -1.0
+	}
+	order := make([]int, len(LayerStack))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return LayerStack[order[a]].Height < LayerStack[order[b]].Height })
+	for _, i := range order {
+		l := LayerStack[i]
+		if l.Tolerance <= 0.0 || l.Thickness <= 0.0 || l.ZFrom != "" {
+			continue
+		}
+		delta := sign * l.Tolerance
+		top := layerTop(l)
+		for j, other := range LayerStack {
+			if j != i && other.Thickness > 0.0 && roundUm(other.Height) >= top {
+				LayerStack[j].Height += delta
+			}
+		}
+		LayerStack[i].Thickness += delta
+		LayerStack[i].ZSource = fmt.Sprintf("%s, %s corner", l.ZSource, corner)
+	}
+}
+
+// Output file of a variant, sg13g2.txt -> sg13g2_nomim.txt
+func variantPath(filePath string, v variant) string {
+	if v.Name == "" {
+		return filePath
+	}
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + "_" + v.Name + ext
+}
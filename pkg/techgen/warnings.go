@@ -0,0 +1,145 @@
+// Warnings found while building the layer stack
+//
+// The parsers and the stages of the pipeline return the warnings they
+// find next to their results, Main prints them and Generate returns them.
+// Problems of the resolved geometry, that would only show as glitches in
+// GDS3D, are issues as well, listed with the check and the layers
+// involved in the JSON export for scripts:
+//
+//	overlap     two metals share a z range
+//	via-span    a via does not exactly span from its lower to its upper metal
+//	thickness   a layer has a negative thickness
+//	beol-height the top of the stack is off the expected BEOL height
+//	order       numbered layers are out of order in z, see zorder.go
+
+package techgen
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/gds3d"
+	"github.com/jorgenkraghjakobsen/build_3d_techfile/pkg/parseerr"
+)
+
+// The warnings of the pipeline are those of the parsers
+type (
+	Warning  = parseerr.Warning
+	Severity = parseerr.Severity
+)
+
+const (
+	SeverityInfo    = parseerr.SeverityInfo
+	SeverityWarning = parseerr.SeverityWarning
+	SeverityError   = parseerr.SeverityError
+)
+
+// Codes of the warnings that are issues, see above
+var issueChecks = []string{"overlap", "via-span", "thickness", "beol-height", "order"}
+
+type stackIssue struct {
+	Check   string   `json:"check"`
+	Layers  []string `json:"layers"`
+	Message string   `json:"message"`
+}
+
+// The issues among warnings
+func stackIssues(warnings []Warning) []stackIssue {
+	var issues []stackIssue
+	for _, w := range warnings {
+		if slices.Contains(issueChecks, w.Code) {
+			issues = append(issues, stackIssue{w.Code, w.Layers, w.Message})
+		}
+	}
+	return issues
+}
+
+// A geometry problem, an issue for the JSON export as well
+func issue(check string, layers []string, format string, args ...any) Warning {
+	severity := SeverityWarning
+	if check == "thickness" {
+		severity = SeverityError
+	}
+	return Warning{Code: check, Severity: severity, Message: fmt.Sprintf(format, args...), Layers: layers}
+}
+
+func warn(code, format string, args ...any) Warning {
+	return Warning{Code: code, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...)}
+}
+
+// A warning about layers of the stack
+func warnLayers(code string, layers []string, format string, args ...any) Warning {
+	return Warning{Code: code, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...), Layers: layers}
+}
+
+func note(code, format string, args ...any) Warning {
+	return Warning{Code: code, Severity: SeverityInfo, Message: fmt.Sprintf(format, args...)}
+}
+
+func printWarnings(w io.Writer, warnings []Warning) {
+	for _, warning := range warnings {
+		fmt.Fprintln(w, warning)
+	}
+}
+
+func checkLayerStack(LayerStack []Layer, f gds3d.Format) []Warning {
+	var warnings []Warning
+	shortkeys := map[string]string{}
+	for _, l := range LayerStack {
+		// Merged sources share the key of their layer on purpose
+		if other, ok := shortkeys[l.Shortkey]; ok && !l.SharedLook {
+			warnings = append(warnings, warn("shortkey", "%s: shortkey %s is already used by %s", l.Name, l.Shortkey, other))
+		} else if l.Shortkey != "" {
+			shortkeys[l.Shortkey] = l.Name
+		}
+		if l.ColorSource == "default" {
+			warnings = append(warnings, warn("default-gds", "%s: no lyp or PDK data, GDS %d/%d and color %s are defaults", l.Name, l.GDSNumber, l.GDSDatatype, l.Color))
+		}
+		if l.Thickness < 0.0 {
+			warnings = append(warnings, issue("thickness", []string{l.Name}, "%s: negative thickness %g um", l.Name, l.Thickness))
+		} else if l.Thickness == 0.0 {
+			warnings = append(warnings, warn("zero-thickness", "%s: thickness %g um, layer will not be visible", l.Name, l.Thickness))
+		}
+	}
+	warnings = append(warnings, checkLengths(LayerStack, f)...)
+	warnings = append(warnings, checkViaConnectivity(LayerStack)...)
+	return append(warnings, checkStackGeometry(LayerStack)...)
+}
+
+func checkStackGeometry(LayerStack []Layer) []Warning {
+	var warnings []Warning
+	// Metals of their own z, extra datatypes and slabs share it on purpose
+	var metals []Layer
+	for _, l := range LayerStack {
+		if l.Metal == 1 && l.ZFrom == "" && !l.Dielectric && l.Thickness > 0.0 {
+			metals = append(metals, l)
+		}
+	}
+	for i, a := range metals {
+		for _, b := range metals[i+1:] {
+			overlap := min(layerTop(a), layerTop(b)) - max(roundUm(a.Height), roundUm(b.Height))
+			if overlap > connectTolerance {
+				warnings = append(warnings, issue("overlap", []string{a.Name, b.Name}, "%s and %s overlap by %g um in z", a.Name, b.Name, roundUm(overlap)))
+			}
+		}
+	}
+
+	for i, l := range LayerStack {
+		if !isViaLayer(l) || l.Name == "Cont" || l.ZFrom != "" || l.Thickness <= 0.0 {
+			continue
+		}
+		below, above, missing := viaMetals(LayerStack, i)
+		if missing != "" || below < 0 || above < 0 {
+			continue
+		}
+		lower, upper := LayerStack[below], LayerStack[above]
+		if math.Abs(roundUm(l.Height)-layerTop(lower)) > connectTolerance ||
+			math.Abs(layerTop(l)-roundUm(upper.Height)) > connectTolerance {
+			warnings = append(warnings, issue("via-span", []string{l.Name, lower.Name, upper.Name}, "%s: %g to %g um does not span from %s at %g um to %s at %g um",
+				l.Name, roundUm(l.Height), layerTop(l), lower.Name, layerTop(lower), upper.Name, roundUm(upper.Height)))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,74 @@
+// Stack order by resolved z
+//
+// Inputs list the layers in their own order: the built-in stack by type,
+// config entries appended at the end. The stack is sorted by z before via
+// interpolation, so the nearest metals of a via are the ones next to it in
+// z, and again before the outputs are written. Layers without z data yet,
+// e.g. vias to interpolate, keep their place after the layer they follow.
+//
+// Numbered names imply an order too, Metal2 above Metal1 and TopVia2 above
+// TopVia1. Where the z data contradicts it the LEF is most likely wrong or
+// belongs to another process, this is reported as an "order" issue.
+
+package techgen
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+func sortLayerStackByZ(LayerStack []Layer) []Layer {
+	// A layer with z and the layers without z that follow it
+	var blocks [][]Layer
+	for _, l := range LayerStack {
+		if l.Thickness > 0.0 || len(blocks) == 0 {
+			blocks = append(blocks, nil)
+		}
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], l)
+	}
+	slices.SortStableFunc(blocks, func(a, b []Layer) int {
+		// Leading layers without z stay at the bottom
+		if a[0].Thickness <= 0.0 || b[0].Thickness <= 0.0 {
+			return cmp.Compare(btoi(a[0].Thickness > 0.0), btoi(b[0].Thickness > 0.0))
+		}
+		return cmp.Or(cmp.Compare(roundUm(a[0].Height), roundUm(b[0].Height)), cmp.Compare(layerTop(a[0]), layerTop(b[0])))
+	})
+	sorted := make([]Layer, 0, len(LayerStack))
+	for _, block := range blocks {
+		sorted = append(sorted, block...)
+	}
+	return sorted
+}
+
+var numberedLayer = regexp.MustCompile(`^(.*?)(\d+)$`)
+
+func checkNameOrder(LayerStack []Layer) []Warning {
+	var warnings []Warning
+	byName := map[string]Layer{}
+	for _, l := range LayerStack {
+		if l.ZFrom == "" && l.Thickness > 0.0 {
+			byName[l.Name] = l
+		}
+	}
+	for _, l := range LayerStack {
+		m := numberedLayer.FindStringSubmatch(l.Name)
+		if m == nil || byName[l.Name].Name == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		next, ok := byName[m[1]+strconv.Itoa(n+1)]
+		if !ok {
+			continue
+		}
+		if roundUm(next.Height) < roundUm(l.Height) {
+			warnings = append(warnings, issue("order", []string{l.Name, next.Name}, "%s at %g um is below %s at %g um, check the LEF and stackup files",
+				next.Name, roundUm(next.Height), l.Name, roundUm(l.Height)))
+		}
+	}
+	return warnings
+}